@@ -1,9 +1,13 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
 	"song-lib/internal/app"
 	"song-lib/internal/config"
+
+	"github.com/pkg/errors"
 )
 
 func main() {
@@ -12,8 +16,8 @@ func main() {
 		log.Fatalf("read config: %s", err)
 	}
 
-	err = app.Run(cfg)
-	if err != nil {
-		log.Fatalf("run app: %s", err)
+	err = app.RunCommand(cfg, os.Args[1:], os.Stdout)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		log.Fatal(err)
 	}
 }
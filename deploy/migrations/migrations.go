@@ -0,0 +1,11 @@
+// Package migrations embeds SQL migrations into the binary,
+// so that they are found regardless of working directory
+package migrations
+
+import "embed"
+
+//go:embed postgres/*.sql
+var Postgres embed.FS
+
+//go:embed sqlite/*.sql
+var SQLite embed.FS
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_music_groups_name_trgm;
+DROP INDEX IF EXISTS idx_songs_name_trgm;
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_songs_name_trgm ON songs USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_music_groups_name_trgm ON music_groups USING GIN (name gin_trgm_ops);
\ No newline at end of file
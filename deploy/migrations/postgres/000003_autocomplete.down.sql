@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_music_groups_name_lower_prefix;
+DROP INDEX IF EXISTS idx_songs_name_lower_prefix;
\ No newline at end of file
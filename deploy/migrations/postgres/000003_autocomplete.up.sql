@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_songs_name_lower_prefix ON songs (lower(name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_music_groups_name_lower_prefix ON music_groups (lower(name) text_pattern_ops);
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_songs_updated_at;
+DROP INDEX IF EXISTS idx_songs_created_at;
+
+ALTER TABLE songs
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE songs
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_songs_created_at ON songs (created_at);
+CREATE INDEX IF NOT EXISTS idx_songs_updated_at ON songs (updated_at);
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_songs_release_date_last_day;
+
+ALTER TABLE songs
+    DROP COLUMN IF EXISTS release_date_last_day,
+    DROP COLUMN IF EXISTS release_date_precision;
\ No newline at end of file
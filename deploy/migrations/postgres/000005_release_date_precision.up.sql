@@ -0,0 +1,15 @@
+ALTER TABLE songs
+    ADD COLUMN IF NOT EXISTS release_date_precision TEXT NOT NULL DEFAULT 'day'
+        CHECK (release_date_precision IN ('day', 'month', 'year'));
+
+-- Release date period is [release_date; release_date_last_day],
+-- so that range filters can match songs known to month or year only
+ALTER TABLE songs
+    ADD COLUMN IF NOT EXISTS release_date_last_day DATE GENERATED ALWAYS AS ((
+        release_date + CASE release_date_precision
+            WHEN 'year' THEN INTERVAL '1 year'
+            WHEN 'month' THEN INTERVAL '1 month'
+            ELSE INTERVAL '1 day'
+        END - INTERVAL '1 day')::date) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_songs_release_date_last_day ON songs (release_date_last_day);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS count_music_group_songs ON songs;
+DROP FUNCTION IF EXISTS count_music_group_songs();
+ALTER TABLE music_groups DROP COLUMN IF EXISTS song_count;
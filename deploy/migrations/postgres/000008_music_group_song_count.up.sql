@@ -0,0 +1,25 @@
+-- Number of songs is kept on music groups, so that autocomplete
+-- doesn't count songs of each candidate group on every keystroke
+
+ALTER TABLE music_groups ADD COLUMN IF NOT EXISTS song_count INT NOT NULL DEFAULT 0;
+
+UPDATE music_groups mg
+SET song_count = (SELECT COUNT(*) FROM songs s WHERE s.music_group_id = mg.id);
+
+CREATE OR REPLACE FUNCTION count_music_group_songs() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE music_groups SET song_count = song_count - 1
+        WHERE id = OLD.music_group_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE music_groups SET song_count = song_count + 1
+        WHERE id = NEW.music_group_id;
+    END IF;
+    RETURN NULL;
+END $$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS count_music_group_songs ON songs;
+CREATE TRIGGER count_music_group_songs
+AFTER INSERT OR DELETE OR UPDATE OF music_group_id ON songs
+FOR EACH ROW EXECUTE FUNCTION count_music_group_songs();
@@ -0,0 +1,3 @@
+UPDATE songs SET link = '' WHERE link IS NULL;
+
+ALTER TABLE songs ALTER COLUMN link SET NOT NULL;
@@ -0,0 +1,5 @@
+-- Songs without link have NULL one rather than empty string
+
+ALTER TABLE songs ALTER COLUMN link DROP NOT NULL;
+
+UPDATE songs SET link = NULL WHERE link = '';
@@ -0,0 +1,4 @@
+-- Key being processed is leased till locked_until, so that
+-- it can be claimed again if the process dies meanwhile
+
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ NOT NULL DEFAULT now();
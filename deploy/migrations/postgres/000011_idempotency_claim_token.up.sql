@@ -0,0 +1,3 @@
+-- Request processing the key owns it by claim_token, so that a request
+-- whose lease has been taken over can't save or release the key
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS claim_token TEXT;
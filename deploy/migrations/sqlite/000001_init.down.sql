@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS idempotency_keys;
+
+DROP TABLE IF EXISTS song_couplets_fts;
+DROP TABLE IF EXISTS songs_fts;
+DROP TABLE IF EXISTS music_groups_fts;
+
+DROP TABLE IF EXISTS song_couplets;
+DROP TABLE IF EXISTS songs;
+DROP TABLE IF EXISTS music_groups;
@@ -0,0 +1,100 @@
+-- Schema mirrors Postgres one. Dates and timestamps are UTC texts
+-- of "YYYY-MM-DD HH:MM:SS.SSSSSS" layout, so that they compare as strings
+
+CREATE TABLE IF NOT EXISTS music_groups (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS songs (
+    id TEXT PRIMARY KEY,
+    music_group_id TEXT NOT NULL REFERENCES music_groups(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    release_date DATE NOT NULL,
+    release_date_precision TEXT NOT NULL DEFAULT 'day'
+        CHECK (release_date_precision IN ('day', 'month', 'year')),
+    -- Release date period is [release_date; release_date_last_day],
+    -- so that range filters can match songs known to month or year only
+    release_date_last_day DATE GENERATED ALWAYS AS (
+        date(release_date, CASE release_date_precision
+            WHEN 'year' THEN '+1 year'
+            WHEN 'month' THEN '+1 month'
+            ELSE '+1 day'
+        END, '-1 day') || ' 00:00:00.000000') STORED,
+    link TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (music_group_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_songs_name ON songs (name);
+CREATE INDEX IF NOT EXISTS idx_songs_release_date ON songs (release_date);
+CREATE INDEX IF NOT EXISTS idx_songs_release_date_last_day ON songs (release_date_last_day);
+CREATE INDEX IF NOT EXISTS idx_songs_link ON songs (link);
+CREATE INDEX IF NOT EXISTS idx_songs_created_at ON songs (created_at);
+CREATE INDEX IF NOT EXISTS idx_songs_updated_at ON songs (updated_at);
+
+CREATE TABLE IF NOT EXISTS song_couplets (
+    song_id TEXT NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    couplet_num INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    PRIMARY KEY (song_id, couplet_num)
+);
+
+-- Trigram full-text indexes take the place of pg_trgm GIN indexes
+-- for case insensitive search of substrings 3 characters long or more
+
+CREATE VIRTUAL TABLE IF NOT EXISTS music_groups_fts USING fts5(
+    name, content = 'music_groups', tokenize = 'trigram');
+
+CREATE TRIGGER IF NOT EXISTS music_groups_fts_insert AFTER INSERT ON music_groups BEGIN
+    INSERT INTO music_groups_fts (rowid, name) VALUES (new.rowid, new.name);
+END;
+CREATE TRIGGER IF NOT EXISTS music_groups_fts_delete AFTER DELETE ON music_groups BEGIN
+    INSERT INTO music_groups_fts (music_groups_fts, rowid, name) VALUES ('delete', old.rowid, old.name);
+END;
+CREATE TRIGGER IF NOT EXISTS music_groups_fts_update AFTER UPDATE OF name ON music_groups BEGIN
+    INSERT INTO music_groups_fts (music_groups_fts, rowid, name) VALUES ('delete', old.rowid, old.name);
+    INSERT INTO music_groups_fts (rowid, name) VALUES (new.rowid, new.name);
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS songs_fts USING fts5(
+    name, content = 'songs', tokenize = 'trigram');
+
+CREATE TRIGGER IF NOT EXISTS songs_fts_insert AFTER INSERT ON songs BEGIN
+    INSERT INTO songs_fts (rowid, name) VALUES (new.rowid, new.name);
+END;
+CREATE TRIGGER IF NOT EXISTS songs_fts_delete AFTER DELETE ON songs BEGIN
+    INSERT INTO songs_fts (songs_fts, rowid, name) VALUES ('delete', old.rowid, old.name);
+END;
+CREATE TRIGGER IF NOT EXISTS songs_fts_update AFTER UPDATE OF name ON songs BEGIN
+    INSERT INTO songs_fts (songs_fts, rowid, name) VALUES ('delete', old.rowid, old.name);
+    INSERT INTO songs_fts (rowid, name) VALUES (new.rowid, new.name);
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS song_couplets_fts USING fts5(
+    text, content = 'song_couplets', tokenize = 'trigram');
+
+CREATE TRIGGER IF NOT EXISTS song_couplets_fts_insert AFTER INSERT ON song_couplets BEGIN
+    INSERT INTO song_couplets_fts (rowid, text) VALUES (new.rowid, new.text);
+END;
+CREATE TRIGGER IF NOT EXISTS song_couplets_fts_delete AFTER DELETE ON song_couplets BEGIN
+    INSERT INTO song_couplets_fts (song_couplets_fts, rowid, text) VALUES ('delete', old.rowid, old.text);
+END;
+CREATE TRIGGER IF NOT EXISTS song_couplets_fts_update AFTER UPDATE OF text ON song_couplets BEGIN
+    INSERT INTO song_couplets_fts (song_couplets_fts, rowid, text) VALUES ('delete', old.rowid, old.text);
+    INSERT INTO song_couplets_fts (rowid, text) VALUES (new.rowid, new.text);
+END;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    -- Response is NULL while the first request is being processed
+    response_status INTEGER,
+    response_headers BLOB,
+    response_body BLOB,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys (created_at);
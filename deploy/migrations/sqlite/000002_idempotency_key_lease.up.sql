@@ -0,0 +1,5 @@
+-- Key being processed is leased till locked_until, so that it can be
+-- claimed again if the process dies meanwhile. Keys claimed before
+-- have no lease, so they can be claimed again right away
+
+ALTER TABLE idempotency_keys ADD COLUMN locked_until TIMESTAMP;
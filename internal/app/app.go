@@ -2,27 +2,23 @@ package app
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"song-lib/internal/config"
-	"song-lib/internal/db/postgres"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	healthcontroller "song-lib/internal/controllers/health"
 	"song-lib/internal/domain"
 	"song-lib/internal/integrations/songinfo"
-	"song-lib/internal/repos"
 	slogutils "song-lib/internal/utils/slog-utils"
 
 	_ "song-lib/internal/controllers/v1"
+	autocompletecontroller "song-lib/internal/controllers/v1/autocomplete"
 	songcontroller "song-lib/internal/controllers/v1/song"
 	"syscall"
 	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/segmentio/ksuid"
@@ -39,42 +35,56 @@ var Tracer = otel.Tracer("gin-server")
 //	@version		1.0
 //	@description	Library of song texts and metadata
 
-// @BasePath	/api/v1
+//	@BasePath	/api/v1
 func Run(cfg config.Config) error {
-	var logLevel slog.Level
-	err := logLevel.UnmarshalText([]byte(cfg.LogLevel))
-	if err != nil {
-		return errors.Wrap(err, "parse log level")
-	}
-	logger, err := newLogger(cfg.Env, logLevel)
+	err := setDefaultLogger(cfg)
 	if err != nil {
 		return err
 	}
-	slog.SetDefault(logger)
 
-	migrator, err := migrate.New(
-		"file://./deploy/migrations/postgres",
-		fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-			cfg.DBConfig.Username, cfg.DBConfig.Password,
-			cfg.DBConfig.Host, cfg.DBConfig.Port,
-			cfg.DBConfig.DBName, cfg.DBConfig.SSLMode))
+	storage, err := newStorage(cfg, cfg.DBConfig.MigrateOnServe)
 	if err != nil {
-		return errors.Wrap(err, "create migrator")
-	}
-	if err := migrator.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return errors.Wrap(err, "migrate")
-	}
-
-	postgresClient, err := postgres.NewClient(cfg.DBConfig)
-	if err != nil {
-		return errors.Wrap(err, "initialize Postgres client")
+		return err
 	}
+	defer storage.close()
 
-	songRepository := repos.NewSongRepository(postgresClient)
 	songInfoIntegration := songinfo.NewSongInfoIntegration(cfg.SongInfoIntegrationAPI)
-	songService := domain.NewSongService(songRepository, songInfoIntegration)
-
-	songController := songcontroller.NewSongController(songService)
+	songService := domain.NewSongService(
+		storage.songRepository, storage.txManager, songInfoIntegration,
+		cfg.Batch.IntegrationConcurrency)
+
+	songController := songcontroller.NewSongController(
+		songService,
+		songcontroller.Options{
+			RequireIfMatch: cfg.HTTPServer.RequireIfMatch,
+			Idempotency: ginutils.CreateIdempotencyMiddleware(
+				storage.idempotencyStore, cfg.Idempotency.KeyTTL, cfg.Idempotency.Lease),
+			MaxBatchOperations: cfg.Batch.MaxOperations,
+			MaxBatchBodyBytes:  cfg.Batch.MaxBodyBytes,
+			MaxImportBodyBytes: cfg.Import.MaxBodyBytes,
+		})
+	autocompleteController := autocompletecontroller.NewAutocompleteController(
+		songService, cfg.Search.AutocompleteTimeout)
+	healthChecks := []healthcontroller.Check{{
+		Name:     "database",
+		Timeout:  cfg.Health.CheckTimeout,
+		Critical: true,
+		Run:      storage.ping,
+	}}
+	if storage.checkMigrations != nil {
+		healthChecks = append(healthChecks, healthcontroller.Check{
+			Name:     "migrations",
+			Timeout:  cfg.Health.CheckTimeout,
+			Critical: true,
+			Run:      storage.checkMigrations,
+		})
+	}
+	healthChecks = append(healthChecks, healthcontroller.Check{
+		Name:    "songInfo",
+		Timeout: cfg.Health.UpstreamCheckTimeout,
+		Run:     songInfoIntegration.Ping,
+	})
+	healthController := healthcontroller.NewHealthController(healthChecks)
 
 	switch cfg.Env {
 	case config.EnvLocal:
@@ -87,17 +97,25 @@ func Run(cfg config.Config) error {
 	engine.Use(setRequestIDMiddleware(), setLoggerMiddleware())
 	engine.GET("api/v1/swagger/*any", ginswagger.WrapHandler(swaggerfiles.Handler))
 	songController.RegisterRoutes(engine)
+	autocompleteController.RegisterRoutes(engine)
+	healthController.RegisterRoutes(engine)
 
 	srv := &http.Server{
 		Addr:    cfg.HTTPServer.Host + ":" + cfg.HTTPServer.Port,
 		Handler: engine.Handler(),
 	}
-	runServer(srv)
+	stopCleanup := runIdempotencyKeysCleanup(storage.idempotencyStore, cfg.Idempotency)
+	defer stopCleanup()
+	runServer(srv, healthController, cfg.HTTPServer.ShutdownDrainDelay)
 
 	return nil
 }
 
-func runServer(srv *http.Server) {
+func runServer(
+	srv *http.Server,
+	healthController *healthcontroller.HealthController,
+	drainDelay time.Duration,
+) {
 	slog.Info("starting server...")
 	defer slog.Info("exited")
 
@@ -114,6 +132,14 @@ func runServer(srv *http.Server) {
 
 	select {
 	case <-quit:
+		// Requests are still served while the orchestrator
+		// notices that the server is not ready anymore
+		healthController.MarkShuttingDown()
+		if drainDelay > 0 {
+			slog.Info("draining requests...", "delay", drainDelay.String())
+			time.Sleep(drainDelay)
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), gracefulServerShutdownTimeout)
 		defer cancel()
 		slog.Info("shutting down server gracefully...", "timeout", gracefulServerShutdownTimeout.String())
@@ -126,6 +152,40 @@ func runServer(srv *http.Server) {
 	}
 }
 
+// runIdempotencyKeysCleanup periodically deletes expired idempotency
+// keys in background till the returned function is called
+func runIdempotencyKeysCleanup(
+	store idempotencyStore, cfg config.IdempotencyConfig,
+) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(cfg.CleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			deleted, err := store.DeleteExpiredIdempotencyKeys(ctx, cfg.KeyTTL)
+			if err != nil {
+				if ctx.Err() == nil {
+					slogutils.Error(ctx, "delete expired idempotency keys:", err)
+				}
+				continue
+			}
+			slog.Debug("expired idempotency keys deleted", "count", deleted)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
 func setRequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := ksuid.New().String()
@@ -149,6 +209,21 @@ func setLoggerMiddleware() gin.HandlerFunc {
 	}
 }
 
+func setDefaultLogger(cfg config.Config) error {
+	var logLevel slog.Level
+	err := logLevel.UnmarshalText([]byte(cfg.LogLevel))
+	if err != nil {
+		return errors.Wrap(err, "parse log level")
+	}
+	logger, err := newLogger(cfg.Env, logLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	return nil
+}
+
 func newLogger(env config.Env, logLevel slog.Level) (logger *slog.Logger, err error) {
 	switch env {
 	case config.EnvLocal:
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"song-lib/internal/backup"
+	"song-lib/internal/config"
+	"song-lib/internal/db/postgres"
+	"song-lib/internal/domain"
+	"song-lib/internal/repos"
+
+	"github.com/pkg/errors"
+)
+
+// RunBackup writes backup archive of the library to the file given
+// in args. Archive is written to a temporary file first, so that
+// an existing backup is not replaced with an incomplete one
+func RunBackup(cfg config.Config, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: song-lib backup FILE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("exactly one file is required")
+	}
+	path := flags.Arg(0)
+
+	err := requireStorage(cfg, config.StoragePostgres)
+	if err != nil {
+		return err
+	}
+	err = setDefaultLogger(cfg)
+	if err != nil {
+		return err
+	}
+	postgresClient, err := postgres.NewClient(cfg.DBConfig)
+	if err != nil {
+		return errors.Wrap(err, "initialize Postgres client")
+	}
+	defer postgresClient.Close()
+	songRepository := repos.NewSongRepository(
+		postgresClient, nil, cfg.Search.SimilarityThreshold)
+
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return errors.Wrap(err, "create file")
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	err = backup.Write(context.Background(), songRepository, file)
+	if err != nil {
+		return errors.Wrap(err, "write backup")
+	}
+	if err = file.Sync(); err != nil {
+		return errors.Wrap(err, "sync file")
+	}
+	if err = file.Close(); err != nil {
+		return errors.Wrap(err, "close file")
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return errors.Wrap(err, "rename file")
+	}
+
+	fmt.Fprintf(out, "backup is written to %s\n", path)
+	return nil
+}
+
+// RunRestore restores the library from backup archive given in args
+// in a single transaction. Report is written to out
+func RunRestore(cfg config.Config, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	onConflict := flags.String("on-conflict", string(domain.RestoreConflictFail),
+		"what to do with songs having the same ID or name and group "+
+			"as existing ones: fail, skip or overwrite")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: song-lib restore [flags] FILE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("exactly one file is required")
+	}
+	policy := domain.RestoreConflictPolicy(*onConflict)
+	switch policy {
+	case domain.RestoreConflictFail, domain.RestoreConflictSkip,
+		domain.RestoreConflictOverwrite:
+	default:
+		return errors.Errorf("conflict policy \"%s\" is unknown", *onConflict)
+	}
+	if err := requireStorage(cfg, config.StoragePostgres); err != nil {
+		return err
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return errors.Wrap(err, "open file")
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "stat file")
+	}
+
+	err = setDefaultLogger(cfg)
+	if err != nil {
+		return err
+	}
+	postgresClient, err := postgres.NewClient(cfg.DBConfig)
+	if err != nil {
+		return errors.Wrap(err, "initialize Postgres client")
+	}
+	defer postgresClient.Close()
+	songRepository := repos.NewSongRepository(
+		postgresClient, nil, cfg.Search.SimilarityThreshold)
+
+	report, err := backup.Restore(context.Background(), songRepository,
+		file, fileInfo.Size(), policy)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%d music groups created, %d songs restored, "+
+		"%d overwritten, %d skipped\n",
+		report.MusicGroups, report.Songs, report.Overwritten, report.Skipped)
+	return nil
+}
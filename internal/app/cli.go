@@ -0,0 +1,159 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"song-lib/internal/config"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// command is a CLI command, commands having subcommands
+// dispatch to them by the first argument instead of running
+type command struct {
+	name        string
+	description string
+	run         func(cfg config.Config, args []string, out io.Writer) error
+	subcommands []*command
+}
+
+var rootCommand = &command{
+	name: "song-lib",
+	subcommands: []*command{
+		{
+			name:        "serve",
+			description: "run HTTP server, default if no command is given",
+			run:         runServe,
+		},
+		{
+			name:        "migrate",
+			description: "manage database schema",
+			subcommands: []*command{
+				{
+					name:        "up",
+					description: "apply all or [N] migrations",
+					run:         runMigrateUp,
+				},
+				{
+					name:        "down",
+					description: "revert N migrations",
+					run:         runMigrateDown,
+				},
+				{
+					name:        "status",
+					description: "print schema version",
+					run:         runMigrateStatus,
+				},
+				{
+					name:        "force",
+					description: "set schema version VERSION without migrating, clears dirty state",
+					run:         runMigrateForce,
+				},
+			},
+		},
+		{
+			name:        "songs",
+			description: "manage songs",
+			subcommands: []*command{
+				{name: "list", description: "list songs", run: runSongsList},
+				{name: "get", description: "print song by ID", run: runSongsGet},
+				{name: "create", description: "create song", run: runSongsCreate},
+				{name: "delete", description: "delete song by ID", run: runSongsDelete},
+			},
+		},
+		{
+			name:        "import",
+			description: "import songs from CSV or JSON Lines",
+			run:         RunImport,
+		},
+		{
+			name:        "backup",
+			description: "write backup archive of the library",
+			run:         RunBackup,
+		},
+		{
+			name:        "restore",
+			description: "restore the library from backup archive",
+			run:         RunRestore,
+		},
+		{
+			name:        "config",
+			description: "inspect configuration",
+			subcommands: []*command{
+				{
+					name:        "print",
+					description: "print configuration as env variables, secrets are hidden",
+					run:         runConfigPrint,
+				},
+			},
+		},
+	},
+}
+
+// RunCommand runs the command given in args, which
+// don't include program name. Server is run if args are empty.
+// flag.ErrHelp is returned if usage is printed on request
+func RunCommand(cfg config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return runServe(cfg, args, out)
+	}
+	return rootCommand.dispatch(cfg, rootCommand.name, args, out)
+}
+
+func (c *command) dispatch(
+	cfg config.Config, path string, args []string, out io.Writer,
+) error {
+	if c.run != nil {
+		err := c.run(cfg, args, out)
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			return errors.Wrap(err, path)
+		}
+		return err
+	}
+
+	if len(args) == 0 {
+		c.printUsage(out, path)
+		return errors.Errorf("%s: command is required", path)
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		c.printUsage(out, path)
+		return flag.ErrHelp
+	}
+	for _, subcommand := range c.subcommands {
+		if subcommand.name == args[0] {
+			return subcommand.dispatch(
+				cfg, path+" "+subcommand.name, args[1:], out)
+		}
+	}
+
+	c.printUsage(out, path)
+	return errors.Errorf("%s: command \"%s\" is unknown", path, args[0])
+}
+
+func (c *command) printUsage(out io.Writer, path string) {
+	fmt.Fprintf(out, "Usage: %s COMMAND [args]\n\nCommands:\n", path)
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, subcommand := range c.subcommands {
+		fmt.Fprintf(writer, "  %s\t%s\n", subcommand.name,
+			strings.TrimSpace(subcommand.description))
+	}
+	writer.Flush()
+}
+
+func runServe(cfg config.Config, args []string, _ io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	return Run(cfg)
+}
+
+func runConfigPrint(cfg config.Config, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	return cfg.Fprint(out)
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"song-lib/internal/config"
+	"song-lib/internal/domain"
+	"song-lib/internal/songimport"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RunImport imports songs from the file given in args,
+// "-" stands for stdin. Report is written to out
+func RunImport(cfg config.Config, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "",
+		"source format, csv or jsonl, taken from file extension if not set")
+	dryRun := flags.Bool("dry-run", false, "validate rows without saving them")
+	onDuplicate := flags.String("on-duplicate", string(domain.DuplicatePolicySkip),
+		"what to do with existing songs: skip, update or fail")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: song-lib import [flags] FILE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("exactly one file is required")
+	}
+	switch domain.DuplicatePolicy(*onDuplicate) {
+	case domain.DuplicatePolicySkip, domain.DuplicatePolicyUpdate,
+		domain.DuplicatePolicyFail:
+	default:
+		return errors.Errorf("duplicate policy \"%s\" is unknown", *onDuplicate)
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		file, err = os.Open(path)
+		if err != nil {
+			return errors.Wrap(err, "open file")
+		}
+		defer file.Close()
+	}
+
+	songService, closeService, err := newSongService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	source, err := songimport.NewSource(songimport.Format(*format), file)
+	if err != nil {
+		return errors.Wrap(err, "read source")
+	}
+	report, importErr := songService.ImportSongs(
+		context.Background(), source,
+		domain.SongImportOptions{
+			DryRun:      *dryRun,
+			OnDuplicate: domain.DuplicatePolicy(*onDuplicate),
+		})
+	if report != nil {
+		printImportReport(out, report, *dryRun)
+	}
+	switch {
+	case importErr != nil:
+		return importErr
+	case report.Failed > 0:
+		return errors.Errorf("%d rows failed", report.Failed)
+	}
+
+	return nil
+}
+
+func printImportReport(
+	out io.Writer, report *domain.SongImportReport, dryRun bool,
+) {
+	for _, row := range report.Rows {
+		fmt.Fprintf(out, "line %d: %s", row.Line, row.Status)
+		if row.Err != nil {
+			fmt.Fprintf(out, ": %s", row.Err)
+		}
+		fmt.Fprintln(out)
+	}
+
+	if dryRun {
+		fmt.Fprint(out, "dry run: ")
+	}
+	fmt.Fprintf(out, "%d created, %d updated, %d skipped, %d failed\n",
+		report.Created, report.Updated, report.Skipped, report.Failed)
+}
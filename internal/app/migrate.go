@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"song-lib/internal/config"
+	"song-lib/internal/db/postgres"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/pkg/errors"
+)
+
+// runMigrator runs migrator holding the migration lock
+func runMigrator(
+	cfg config.Config, run func(migrator *migrate.Migrate) error,
+) error {
+	if err := requireStorage(cfg, config.StoragePostgres); err != nil {
+		return err
+	}
+	if err := setDefaultLogger(cfg); err != nil {
+		return err
+	}
+	postgresClient, err := postgres.NewClient(cfg.DBConfig)
+	if err != nil {
+		return errors.Wrap(err, "initialize Postgres client")
+	}
+	defer postgresClient.Close()
+
+	return postgres.RunMigrator(context.Background(), postgresClient, run)
+}
+
+// parseSteps parses optional number of migrations
+// to apply, ok is false if it's not given
+func parseSteps(args []string) (steps int, ok bool, err error) {
+	switch len(args) {
+	case 0:
+		return 0, false, nil
+	case 1:
+		steps, err = strconv.Atoi(args[0])
+		if err != nil || steps <= 0 {
+			return 0, false, errors.Errorf(
+				"number of migrations \"%s\" should be positive", args[0])
+		}
+		return steps, true, nil
+	default:
+		return 0, false, errors.New("too many arguments")
+	}
+}
+
+func runMigrateUp(cfg config.Config, args []string, out io.Writer) error {
+	steps, ok, err := parseSteps(args)
+	if err != nil {
+		return err
+	}
+	return runMigrator(cfg, func(migrator *migrate.Migrate) error {
+		if ok {
+			err = migrator.Steps(steps)
+		} else {
+			err = migrator.Up()
+		}
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Fprintln(out, "no change")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return printMigrationVersion(migrator, out)
+	})
+}
+
+func runMigrateDown(cfg config.Config, args []string, out io.Writer) error {
+	steps, ok, err := parseSteps(args)
+	if err != nil {
+		return err
+	}
+	// Reverting every migration drops all the data,
+	// so the number of migrations is required
+	if !ok {
+		return errors.New("number of migrations to revert is required")
+	}
+	return runMigrator(cfg, func(migrator *migrate.Migrate) error {
+		err = migrator.Steps(-steps)
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Fprintln(out, "no change")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return printMigrationVersion(migrator, out)
+	})
+}
+
+func runMigrateStatus(cfg config.Config, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	return runMigrator(cfg, func(migrator *migrate.Migrate) error {
+		return printMigrationVersion(migrator, out)
+	})
+}
+
+func runMigrateForce(cfg config.Config, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("exactly one version is required")
+	}
+	version, err := strconv.Atoi(args[0])
+	if err != nil || version < -1 {
+		return errors.Errorf("version \"%s\" is invalid", args[0])
+	}
+	return runMigrator(cfg, func(migrator *migrate.Migrate) error {
+		if err := migrator.Force(version); err != nil {
+			return err
+		}
+		return printMigrationVersion(migrator, out)
+	})
+}
+
+func printMigrationVersion(migrator *migrate.Migrate, out io.Writer) error {
+	version, dirty, err := migrator.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Fprintln(out, "no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "get version")
+	}
+
+	fmt.Fprintf(out, "version %d", version)
+	if dirty {
+		fmt.Fprint(out, " (dirty, fix the schema and force the version)")
+	}
+	fmt.Fprintln(out)
+	return nil
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"song-lib/internal/config"
+	"song-lib/internal/domain"
+	"song-lib/internal/integrations/songinfo"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+// newSongService connects to the storage, close
+// releases the connection once the service is not needed.
+// Memory storage is refused as changes would be lost on exit
+func newSongService(
+	cfg config.Config,
+) (songService *domain.SongService, close func(), err error) {
+	if cfg.Storage == config.StorageMemory {
+		return nil, nil, errors.New(
+			"memory storage is not shared between processes")
+	}
+	if err = setDefaultLogger(cfg); err != nil {
+		return nil, nil, err
+	}
+	storage, err := newStorage(cfg, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	songService = domain.NewSongService(
+		storage.songRepository, storage.txManager,
+		songinfo.NewSongInfoIntegration(cfg.SongInfoIntegrationAPI),
+		cfg.Batch.IntegrationConcurrency)
+	return songService, storage.close, nil
+}
+
+func runSongsList(cfg config.Config, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("songs list", flag.ContinueOnError)
+	songName := flags.String("song", "", "filter for song name")
+	groupName := flags.String("group", "", "filter for music group name")
+	match := flags.String("match", string(domain.MatchModeExact),
+		"matching mode for song and group filters: exact, prefix or fuzzy")
+	page := flags.Int("page", 1, "page number")
+	perPage := flags.Int("per-page", 20, "songs per page")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: song-lib songs list [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("too many arguments")
+	}
+	switch domain.MatchMode(*match) {
+	case domain.MatchModeExact, domain.MatchModePrefix, domain.MatchModeFuzzy:
+	default:
+		return errors.Errorf("matching mode \"%s\" is unknown", *match)
+	}
+	if *page < 1 || *perPage < 1 {
+		return errors.New("page and per-page should be positive")
+	}
+
+	filters := domain.SongFilters{NameMatchMode: domain.MatchMode(*match)}
+	if *songName != "" {
+		filters.SongName = songName
+	}
+	if *groupName != "" {
+		filters.MusicGroupName = groupName
+	}
+
+	songService, closeService, err := newSongService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	songsPage, err := songService.GetSongsFilteredPaginated(
+		context.Background(), &filters, nil,
+		domain.Pagination{
+			Page:    *page - 1,
+			PerPage: *perPage,
+			Count:   domain.CountModeExact,
+		},
+		domain.SongFieldSet{
+			domain.SongFieldName:        true,
+			domain.SongFieldGroup:       true,
+			domain.SongFieldReleaseDate: true,
+		})
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tGROUP\tSONG\tRELEASED")
+	for _, song := range songsPage.Songs {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n",
+			song.ID, song.MusicGroup.Name, song.Name,
+			domain.ReleaseDateLayouts.Format(song.ReleaseDate))
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "page %d, %d songs total\n", *page, *songsPage.Total)
+
+	return nil
+}
+
+func runSongsGet(cfg config.Config, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("exactly one song ID is required")
+	}
+	songID, err := ksuid.Parse(args[0])
+	if err != nil {
+		return errors.Errorf("song ID \"%s\" is invalid", args[0])
+	}
+
+	songService, closeService, err := newSongService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	song, err := songService.GetSong(context.Background(), songID)
+	if err != nil {
+		return err
+	}
+	printSong(out, song)
+
+	return nil
+}
+
+func runSongsCreate(cfg config.Config, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("songs create", flag.ContinueOnError)
+	songName := flags.String("song", "", "song name")
+	groupName := flags.String("group", "", "music group name")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(),
+			"Usage: song-lib songs create -song NAME -group NAME")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("too many arguments")
+	}
+
+	songService, closeService, err := newSongService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	song, err := songService.CreateSong(context.Background(),
+		&domain.CreateSongDTO{SongName: *songName, MusicGroupName: *groupName})
+	if err != nil {
+		return err
+	}
+	printSong(out, song)
+
+	return nil
+}
+
+func runSongsDelete(cfg config.Config, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("songs delete", flag.ContinueOnError)
+	version := flags.Int64("version", 0,
+		"delete only if song has this version, 0 skips the check")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: song-lib songs delete [flags] ID")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("exactly one song ID is required")
+	}
+	songID, err := ksuid.Parse(flags.Arg(0))
+	if err != nil {
+		return errors.Errorf("song ID \"%s\" is invalid", flags.Arg(0))
+	}
+	var expectedVersion *int64
+	if *version != 0 {
+		expectedVersion = version
+	}
+
+	songService, closeService, err := newSongService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	err = songService.DeleteSong(context.Background(), songID, expectedVersion)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "song %s is deleted\n", songID)
+
+	return nil
+}
+
+func printSong(out io.Writer, song *domain.Song) {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "ID:\t%s\n", song.ID)
+	fmt.Fprintf(writer, "Group:\t%s\n", song.MusicGroup.Name)
+	fmt.Fprintf(writer, "Song:\t%s\n", song.Name)
+	fmt.Fprintf(writer, "Released:\t%s\n",
+		domain.ReleaseDateLayouts.Format(song.ReleaseDate))
+	fmt.Fprintf(writer, "Link:\t%s\n", song.Link)
+	fmt.Fprintf(writer, "Version:\t%d\n", song.Version)
+	fmt.Fprintf(writer, "Created:\t%s\n", song.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(writer, "Updated:\t%s\n", song.UpdatedAt.Format(time.RFC3339))
+	writer.Flush()
+
+	if len(song.Couplets) > 0 {
+		fmt.Fprintf(out, "\n%s\n", strings.Join(song.Couplets, "\n\n"))
+	}
+}
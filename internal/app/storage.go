@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"song-lib/internal/config"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/db/postgres"
+	"song-lib/internal/db/sqlite"
+	"song-lib/internal/domain"
+	"song-lib/internal/repos"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// storage has repositories of the configured backend
+type storage struct {
+	songRepository   domain.SongRepository
+	txManager        domain.TxManager
+	idempotencyStore idempotencyStore
+	// ping fails if the database can't be reached
+	ping func(ctx context.Context) error
+	// checkMigrations fails if the schema is behind the
+	// migrations, it is nil if schema is always migrated
+	checkMigrations func(ctx context.Context) error
+	// close releases connections once repositories are not needed
+	close func()
+}
+
+// idempotencyStore stores idempotency keys and deletes expired ones
+type idempotencyStore interface {
+	ginutils.IdempotencyStore
+	DeleteExpiredIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error)
+}
+
+// newStorage creates repositories of the configured backend,
+// migrations are applied if migrate is set. SQLite database
+// is always migrated as it is local to the process
+func newStorage(cfg config.Config, migrate bool) (*storage, error) {
+	switch cfg.Storage {
+	case config.StoragePostgres:
+		postgresClient, err := postgres.NewClient(cfg.DBConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "initialize Postgres client")
+		}
+		if migrate {
+			err = postgres.MigrateUp(context.Background(), postgresClient)
+			if err != nil {
+				postgresClient.Close()
+				return nil, errors.Wrap(err, "migrate")
+			}
+		}
+		readRouter, err := postgres.NewReadRouter(postgresClient, cfg.DBConfig)
+		if err != nil {
+			postgresClient.Close()
+			return nil, errors.Wrap(err, "initialize read router")
+		}
+		poolMonitor, err := postgres.NewPoolMonitor(
+			readRouter.Pools(), cfg.DBConfig.PoolStatsInterval)
+		if err != nil {
+			readRouter.Close()
+			postgresClient.Close()
+			return nil, errors.Wrap(err, "initialize pool monitor")
+		}
+		return &storage{
+			songRepository: repos.NewSongRepository(
+				postgresClient, readRouter, cfg.Search.SimilarityThreshold),
+			txManager: repos.NewTxManager(
+				postgresClient, readRouter, domain.TxIsolation(cfg.Tx.Isolation),
+				cfg.Tx.MaxRetries, cfg.Tx.RetryDelay),
+			idempotencyStore: repos.NewIdempotencyRepository(postgresClient),
+			ping:             postgresClient.PingContext,
+			checkMigrations: func(ctx context.Context) error {
+				return postgres.CheckMigrations(ctx, postgresClient)
+			},
+			close: func() {
+				poolMonitor.Close()
+				readRouter.Close()
+				postgresClient.Close()
+			},
+		}, nil
+	case config.StorageSQLite:
+		sqliteClient, err := sqlite.NewClient(cfg.SQLite)
+		if err != nil {
+			return nil, errors.Wrap(err, "initialize SQLite client")
+		}
+		err = sqlite.MigrateUp(sqliteClient)
+		if err != nil {
+			sqliteClient.Close()
+			return nil, errors.Wrap(err, "migrate")
+		}
+		return &storage{
+			songRepository: repos.NewSQLiteSongRepository(
+				sqliteClient, cfg.Search.SimilarityThreshold),
+			txManager: repos.NewTxManager(
+				sqliteClient, nil, domain.TxIsolation(cfg.Tx.Isolation),
+				cfg.Tx.MaxRetries, cfg.Tx.RetryDelay),
+			idempotencyStore: repos.NewSQLiteIdempotencyRepository(sqliteClient),
+			ping:             sqliteClient.PingContext,
+			close:            func() { sqliteClient.Close() },
+		}, nil
+	case config.StorageMemory:
+		return &storage{
+			songRepository: repos.NewMemorySongRepository(
+				cfg.Search.SimilarityThreshold),
+			txManager:        repos.NewMemoryTxManager(),
+			idempotencyStore: repos.NewMemoryIdempotencyRepository(),
+			ping:             func(context.Context) error { return nil },
+			close:            func() {},
+		}, nil
+	default:
+		return nil, errors.Errorf("storage \"%s\" is unknown", cfg.Storage)
+	}
+}
+
+// requireStorage fails commands which work
+// with the given storage backend only
+func requireStorage(cfg config.Config, storage config.Storage) error {
+	if cfg.Storage != storage {
+		return errors.Errorf("command requires %s storage, STORAGE is %s",
+			storage, cfg.Storage)
+	}
+	return nil
+}
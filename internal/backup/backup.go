@@ -0,0 +1,236 @@
+// Package backup writes and restores versioned ZIP archives of the
+// library. Archive has music_groups.jsonl and songs.jsonl files with
+// one record per line and manifest.json, which is written last and
+// lists format version along with SHA-256 checksums of the files
+package backup
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"song-lib/internal/domain"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+// FormatVersion is incremented on incompatible archive changes
+const FormatVersion = 1
+
+const (
+	manifestFile    = "manifest.json"
+	musicGroupsFile = "music_groups.jsonl"
+	songsFile       = "songs.jsonl"
+)
+
+type Repository interface {
+	Backup(ctx context.Context, sink domain.BackupSink) error
+	Restore(
+		ctx context.Context, source domain.RestoreSource,
+		policy domain.RestoreConflictPolicy,
+	) (*domain.RestoreReport, error)
+}
+
+type manifest struct {
+	FormatVersion int                  `json:"formatVersion"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	Files         map[string]fileEntry `json:"files"`
+}
+
+type fileEntry struct {
+	SHA256  string `json:"sha256"`
+	Records int    `json:"records"`
+}
+
+type musicGroupRecord struct {
+	ID   ksuid.KSUID `json:"id"`
+	Name string      `json:"name"`
+}
+
+type songRecord struct {
+	ID    ksuid.KSUID      `json:"id"`
+	Group musicGroupRecord `json:"group"`
+	Name  string           `json:"name"`
+	// ReleaseDate is YYYY-MM-DD, only the part
+	// up to ReleaseDatePrecision is meaningful
+	ReleaseDate          string               `json:"releaseDate"`
+	ReleaseDatePrecision domain.DatePrecision `json:"releaseDatePrecision"`
+	Link                 string               `json:"link"`
+	Couplets             []string             `json:"couplets"`
+	CreatedAt            time.Time            `json:"createdAt"`
+	UpdatedAt            time.Time            `json:"updatedAt"`
+	Version              int64                `json:"version"`
+}
+
+func newSongRecord(song *domain.Song) *songRecord {
+	return &songRecord{
+		ID: song.ID,
+		Group: musicGroupRecord{
+			ID:   song.MusicGroup.ID,
+			Name: song.MusicGroup.Name,
+		},
+		Name:                 song.Name,
+		ReleaseDate:          song.ReleaseDate.Date.Format(time.DateOnly),
+		ReleaseDatePrecision: song.ReleaseDate.Precision,
+		Link:                 song.Link,
+		Couplets:             song.Couplets,
+		CreatedAt:            song.CreatedAt,
+		UpdatedAt:            song.UpdatedAt,
+		Version:              song.Version,
+	}
+}
+
+func (r *songRecord) toEntity() (*domain.Song, error) {
+	releaseDate, err := time.Parse(time.DateOnly, r.ReleaseDate)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse release date")
+	}
+	switch r.ReleaseDatePrecision {
+	case domain.DatePrecisionDay, domain.DatePrecisionMonth,
+		domain.DatePrecisionYear:
+	default:
+		return nil, errors.Errorf(
+			"release date precision \"%s\" is unknown", r.ReleaseDatePrecision)
+	}
+
+	return &domain.Song{
+		ID:   r.ID,
+		Name: r.Name,
+		MusicGroup: domain.MusicGroup{
+			ID:   r.Group.ID,
+			Name: r.Group.Name,
+		},
+		Couplets: r.Couplets,
+		ReleaseDate: domain.PartialDate{
+			Date:      releaseDate,
+			Precision: r.ReleaseDatePrecision,
+		},
+		Link:      r.Link,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+		Version:   r.Version,
+	}, nil
+}
+
+// Write writes backup archive of the whole library to w
+func Write(ctx context.Context, repo Repository, w io.Writer) error {
+	sink := newArchiveSink(zip.NewWriter(w))
+	err := repo.Backup(ctx, sink)
+	if err != nil {
+		return err
+	}
+	return sink.close()
+}
+
+// archiveSink writes records to archive files as they come, hashing
+// them on the way. Zip entries are written one at a time, so that songs
+// file is started only after the last music group
+type archiveSink struct {
+	writer   *zip.Writer
+	manifest manifest
+
+	fileName string
+	encoder  *json.Encoder
+	hash     hash.Hash
+	records  int
+}
+
+func newArchiveSink(w *zip.Writer) *archiveSink {
+	return &archiveSink{
+		writer: w,
+		manifest: manifest{
+			FormatVersion: FormatVersion,
+			CreatedAt:     time.Now().UTC(),
+			Files:         make(map[string]fileEntry),
+		},
+	}
+}
+
+func (s *archiveSink) WriteMusicGroup(group *domain.MusicGroup) error {
+	if err := s.startFile(musicGroupsFile); err != nil {
+		return err
+	}
+	s.records++
+	return s.encoder.Encode(musicGroupRecord{ID: group.ID, Name: group.Name})
+}
+
+func (s *archiveSink) WriteSong(song *domain.Song) error {
+	if err := s.startFile(songsFile); err != nil {
+		return err
+	}
+	s.records++
+	return s.encoder.Encode(newSongRecord(song))
+}
+
+// startFile finishes the current file and creates
+// the named one if it's not created already
+func (s *archiveSink) startFile(name string) error {
+	if s.fileName == name {
+		return nil
+	}
+	if _, ok := s.manifest.Files[name]; ok {
+		return errors.Errorf("%s is already written", name)
+	}
+	s.finishFile()
+
+	file, err := s.writer.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: s.manifest.CreatedAt,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "create %s", name)
+	}
+	s.fileName = name
+	s.hash = sha256.New()
+	s.encoder = json.NewEncoder(io.MultiWriter(file, s.hash))
+	s.records = 0
+
+	return nil
+}
+
+func (s *archiveSink) finishFile() {
+	if s.fileName == "" {
+		return
+	}
+	s.manifest.Files[s.fileName] = fileEntry{
+		SHA256:  hex.EncodeToString(s.hash.Sum(nil)),
+		Records: s.records,
+	}
+	s.fileName = ""
+}
+
+// close creates empty files for missing
+// records, writes manifest and closes archive
+func (s *archiveSink) close() error {
+	for _, name := range []string{musicGroupsFile, songsFile} {
+		if _, ok := s.manifest.Files[name]; ok || s.fileName == name {
+			continue
+		}
+		if err := s.startFile(name); err != nil {
+			return err
+		}
+	}
+	s.finishFile()
+
+	file, err := s.writer.CreateHeader(&zip.FileHeader{
+		Name:     manifestFile,
+		Method:   zip.Deflate,
+		Modified: s.manifest.CreatedAt,
+	})
+	if err != nil {
+		return errors.Wrap(err, "create manifest")
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(s.manifest); err != nil {
+		return errors.Wrap(err, "write manifest")
+	}
+
+	return errors.Wrap(s.writer.Close(), "close archive")
+}
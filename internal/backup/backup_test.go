@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"song-lib/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRepository struct {
+	musicGroups []*domain.MusicGroup
+	songs       []*domain.Song
+}
+
+func (r *fakeRepository) Backup(
+	_ context.Context, sink domain.BackupSink,
+) error {
+	for _, group := range r.musicGroups {
+		if err := sink.WriteMusicGroup(group); err != nil {
+			return err
+		}
+	}
+	for _, song := range r.songs {
+		if err := sink.WriteSong(song); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) Restore(
+	_ context.Context, source domain.RestoreSource,
+	_ domain.RestoreConflictPolicy,
+) (*domain.RestoreReport, error) {
+	var report domain.RestoreReport
+	for {
+		group, err := source.NextMusicGroup()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.musicGroups = append(r.musicGroups, group)
+		report.MusicGroups++
+	}
+	for {
+		song, err := source.NextSong()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.songs = append(r.songs, song)
+		report.Songs++
+	}
+	return &report, nil
+}
+
+func TestWriteRestore(t *testing.T) {
+	group := &domain.MusicGroup{ID: ksuid.New(), Name: "Muse"}
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeRepository{
+		musicGroups: []*domain.MusicGroup{group},
+		songs: []*domain.Song{{
+			ID:         ksuid.New(),
+			Name:       "Uprising",
+			MusicGroup: *group,
+			Couplets:   []string{"Paranoia is in bloom", "They will not force us"},
+			ReleaseDate: domain.PartialDate{
+				Date:      time.Date(2009, 9, 1, 0, 0, 0, 0, time.UTC),
+				Precision: domain.DatePrecisionMonth,
+			},
+			Link:      "https://example.com/uprising",
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt.Add(time.Hour),
+			Version:   3,
+		}},
+	}
+
+	var archive bytes.Buffer
+	require.NoError(t, Write(context.Background(), source, &archive))
+
+	target := &fakeRepository{}
+	report, err := Restore(context.Background(), target,
+		bytes.NewReader(archive.Bytes()), int64(archive.Len()),
+		domain.RestoreConflictFail)
+	require.NoError(t, err)
+	require.Equal(t, &domain.RestoreReport{MusicGroups: 1, Songs: 1}, report)
+	require.Equal(t, source, target)
+
+	// Archive with a file changed after its checksum was taken
+	var tampered bytes.Buffer
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	require.NoError(t, err)
+	writer := zip.NewWriter(&tampered)
+	for _, file := range reader.File {
+		w, err := writer.Create(file.Name)
+		require.NoError(t, err)
+		r, err := file.Open()
+		require.NoError(t, err)
+		_, err = io.Copy(w, r)
+		require.NoError(t, err)
+		if file.Name == songsFile {
+			_, err = io.WriteString(w, "{}\n")
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, writer.Close())
+
+	_, err = Restore(context.Background(), &fakeRepository{},
+		bytes.NewReader(tampered.Bytes()), int64(tampered.Len()),
+		domain.RestoreConflictFail)
+	require.ErrorIs(t, err, ErrInvalidArchive)
+}
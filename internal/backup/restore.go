@@ -0,0 +1,164 @@
+package backup
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"song-lib/internal/domain"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidArchive is returned for archives having unsupported
+// format version, missing files or checksum mismatches
+var ErrInvalidArchive = errors.New("backup archive is invalid")
+
+// Restore verifies the archive and restores it with the repository.
+// Nothing is restored if the archive is invalid
+func Restore(
+	ctx context.Context, repo Repository, r io.ReaderAt, size int64,
+	policy domain.RestoreConflictPolicy,
+) (*domain.RestoreReport, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	files, err := verify(archive)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+
+	source, err := newArchiveSource(files)
+	if err != nil {
+		return nil, err
+	}
+	defer source.close()
+
+	return repo.Restore(ctx, source, policy)
+}
+
+// verify checks manifest and checksums of the files it lists,
+// archive files are returned by name
+func verify(archive *zip.Reader) (map[string]*zip.File, error) {
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	manifestZipFile, ok := files[manifestFile]
+	if !ok {
+		return nil, errors.Errorf("%s is missing", manifestFile)
+	}
+	manifestReader, err := manifestZipFile.Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", manifestFile)
+	}
+	defer manifestReader.Close()
+	var archiveManifest manifest
+	err = json.NewDecoder(manifestReader).Decode(&archiveManifest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse %s", manifestFile)
+	}
+	if archiveManifest.FormatVersion != FormatVersion {
+		return nil, errors.Errorf("format version %d is not supported",
+			archiveManifest.FormatVersion)
+	}
+
+	for _, name := range []string{musicGroupsFile, songsFile} {
+		entry, ok := archiveManifest.Files[name]
+		if !ok {
+			return nil, errors.Errorf("%s is missing in manifest", name)
+		}
+		file, ok := files[name]
+		if !ok {
+			return nil, errors.Errorf("%s is missing", name)
+		}
+		checksum, err := fileChecksum(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", name)
+		}
+		if checksum != entry.SHA256 {
+			return nil, errors.Errorf("%s checksum mismatch", name)
+		}
+	}
+
+	return files, nil
+}
+
+func fileChecksum(file *zip.File) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// archiveSource decodes records of verified archive files
+type archiveSource struct {
+	musicGroupsReader io.ReadCloser
+	songsReader       io.ReadCloser
+	musicGroups       *json.Decoder
+	songs             *json.Decoder
+}
+
+func newArchiveSource(files map[string]*zip.File) (*archiveSource, error) {
+	musicGroupsReader, err := files[musicGroupsFile].Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", musicGroupsFile)
+	}
+	songsReader, err := files[songsFile].Open()
+	if err != nil {
+		musicGroupsReader.Close()
+		return nil, errors.Wrapf(err, "open %s", songsFile)
+	}
+
+	return &archiveSource{
+		musicGroupsReader: musicGroupsReader,
+		songsReader:       songsReader,
+		musicGroups:       json.NewDecoder(bufio.NewReader(musicGroupsReader)),
+		songs:             json.NewDecoder(bufio.NewReader(songsReader)),
+	}, nil
+}
+
+func (s *archiveSource) NextMusicGroup() (*domain.MusicGroup, error) {
+	var record musicGroupRecord
+	if err := s.musicGroups.Decode(&record); err != nil {
+		return nil, decodeError(err, musicGroupsFile)
+	}
+	return &domain.MusicGroup{ID: record.ID, Name: record.Name}, nil
+}
+
+func (s *archiveSource) NextSong() (*domain.Song, error) {
+	var record songRecord
+	if err := s.songs.Decode(&record); err != nil {
+		return nil, decodeError(err, songsFile)
+	}
+	song, err := record.toEntity()
+	if err != nil {
+		return nil, errors.Wrapf(err, "song %s", record.ID)
+	}
+	return song, nil
+}
+
+func (s *archiveSource) close() {
+	s.musicGroupsReader.Close()
+	s.songsReader.Close()
+}
+
+func decodeError(err error, fileName string) error {
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+	return errors.Wrapf(err, "decode %s", fileName)
+}
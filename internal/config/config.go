@@ -5,14 +5,23 @@ import (
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/pkg/errors"
 )
 
 type Config struct {
 	Env                    Env                          `env:"ENV" env-required:"true"`
 	LogLevel               string                       `env:"LOG_LEVEL" env-default:"warn"`
+	Storage                Storage                      `env:"STORAGE" env-default:"postgres"`
 	DBConfig               DBConfig                     `env-prefix:"DB_"`
+	SQLite                 SQLiteConfig                 `env-prefix:"SQLITE_"`
+	Tx                     TxConfig                     `env-prefix:"TX_"`
 	HTTPServer             HTTPServerConfig             `env-prefix:"HTTP_SERVER_"`
 	SongInfoIntegrationAPI SongInfoIntegrationAPIConfig `env-prefix:"SONG_INFO_INTEGRATION_API_"`
+	Search                 SearchConfig                 `env-prefix:"SEARCH_"`
+	Idempotency            IdempotencyConfig            `env-prefix:"IDEMPOTENCY_"`
+	Batch                  BatchConfig                  `env-prefix:"BATCH_"`
+	Import                 ImportConfig                 `env-prefix:"IMPORT_"`
+	Health                 HealthConfig                 `env-prefix:"HEALTH_"`
 }
 
 type Env string
@@ -22,19 +31,84 @@ const (
 	EnvProd  Env = "prod"
 )
 
+// Storage is the backend songs are kept in
+type Storage string
+
+const (
+	StoragePostgres Storage = "postgres"
+	// StorageMemory keeps songs in process memory,
+	// they are lost on restart
+	StorageMemory Storage = "memory"
+	// StorageSQLite keeps songs in a local database file
+	StorageSQLite Storage = "sqlite"
+)
+
 type HTTPServerConfig struct {
 	Host    string        `env:"HOST" env-required:"true"`
 	Port    string        `env:"PORT" env-required:"true"`
 	Timeout time.Duration `env:"TIMEOUT" env-default:"4s"`
+	// RequireIfMatch makes song changes without If-Match header fail
+	RequireIfMatch bool `env:"REQUIRE_IF_MATCH" env-default:"false"`
+	// ShutdownDrainDelay is how long the server reports not being
+	// ready before shutting down, so that it stops getting requests
+	ShutdownDrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" env-default:"5s"`
 }
 
+// DBConfig is required for postgres storage only
 type DBConfig struct {
-	Host     string `env:"HOST" env-required:"true"`
-	Port     string `env:"PORT" env-required:"true"`
-	DBName   string `env:"NAME" env-required:"true"`
-	SSLMode  string `env:"SSL_MODE" env-required:"true"`
-	Username string `env:"USERNAME" env-required:"true"`
-	Password string `env:"PASSWORD" env-required:"true"`
+	Host     string `env:"HOST"`
+	Port     string `env:"PORT"`
+	DBName   string `env:"NAME"`
+	SSLMode  string `env:"SSL_MODE"`
+	Username string `env:"USERNAME"`
+	Password string `env:"PASSWORD" secret:"true"`
+	// MigrateOnServe applies migrations on server start, should be
+	// disabled if migrations are run as a separate deployment step
+	MigrateOnServe bool `env:"MIGRATE_ON_SERVE" env-default:"true"`
+	// Pool settings apply to the primary and each replica,
+	// zero MaxIdleConns keeps no idle connections
+	MaxOpenConns    int           `env:"MAX_OPEN_CONNS" env-default:"20"`
+	MaxIdleConns    int           `env:"MAX_IDLE_CONNS" env-default:"10"`
+	ConnMaxLifetime time.Duration `env:"CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `env:"CONN_MAX_IDLE_TIME" env-default:"5m"`
+	// ConnectTimeout limits how long connecting on start is retried
+	ConnectTimeout time.Duration `env:"CONNECT_TIMEOUT" env-default:"30s"`
+	// PoolStatsInterval is how often pool stats are logged,
+	// zero disables logging
+	PoolStatsInterval time.Duration `env:"POOL_STATS_INTERVAL" env-default:"1m"`
+	// ReplicaDSNs are comma separated connection strings of read
+	// replicas, song lists, couplets and existence checks are read
+	// from them if they are set
+	ReplicaDSNs []string `env:"REPLICA_DSNS" env-separator:"," secret:"true"`
+	// ReplicaHealthCheckInterval is how often replicas are checked
+	ReplicaHealthCheckInterval time.Duration `env:"REPLICA_HEALTH_CHECK_INTERVAL" env-default:"5s"`
+	// ReplicaMaxLag is how far replica can lag behind the primary,
+	// replicas lagging more get no reads till they catch up
+	ReplicaMaxLag time.Duration `env:"REPLICA_MAX_LAG" env-default:"2s"`
+	// ReadYourWritesWindow is how long reads go to the primary after
+	// a write of the process, it should be at least ReplicaMaxLag.
+	// Writes are tracked per process, so reads of all callers go to
+	// the primary after any write, while callers whose writes are
+	// made by another instance may read stale data from replicas
+	ReadYourWritesWindow time.Duration `env:"READ_YOUR_WRITES_WINDOW" env-default:"2s"`
+}
+
+// SQLiteConfig is used by sqlite storage, its
+// migrations are applied whenever database is opened
+type SQLiteConfig struct {
+	Path string `env:"PATH" env-default:"song-lib.db"`
+}
+
+// TxConfig is used by transactions of
+// postgres and sqlite storages
+type TxConfig struct {
+	// Isolation is one of "read committed", "repeatable read" and
+	// "serializable", used unless transaction asks for another one
+	Isolation string `env:"ISOLATION" env-default:"read committed"`
+	// MaxRetries limits how many times Postgres transaction
+	// failed on serialization is run again
+	MaxRetries int           `env:"MAX_RETRIES" env-default:"3"`
+	RetryDelay time.Duration `env:"RETRY_DELAY" env-default:"20ms"`
 }
 
 type SongInfoIntegrationAPIConfig struct {
@@ -43,6 +117,40 @@ type SongInfoIntegrationAPIConfig struct {
 	SongInfoPath string `env:"SONG_INFO_PATH" env-required:"true"`
 }
 
+type SearchConfig struct {
+	SimilarityThreshold float64       `env:"SIMILARITY_THRESHOLD" env-default:"0.3"`
+	AutocompleteTimeout time.Duration `env:"AUTOCOMPLETE_TIMEOUT" env-default:"200ms"`
+}
+
+type IdempotencyConfig struct {
+	KeyTTL time.Duration `env:"KEY_TTL" env-default:"24h"`
+	// Lease is how long a key stays claimed by a request without response,
+	// it should exceed the longest request, as the key can be claimed by
+	// a retry once it passes in case the process has died meanwhile
+	Lease time.Duration `env:"LEASE" env-default:"5m"`
+	// CleanupInterval is how often keys older than KeyTTL are deleted
+	CleanupInterval time.Duration `env:"CLEANUP_INTERVAL" env-default:"1h"`
+}
+
+type BatchConfig struct {
+	MaxOperations int   `env:"MAX_OPERATIONS" env-default:"100"`
+	MaxBodyBytes  int64 `env:"MAX_BODY_BYTES" env-default:"1048576"`
+	// IntegrationConcurrency limits concurrent song info
+	// integration calls made for a single batch
+	IntegrationConcurrency int `env:"INTEGRATION_CONCURRENCY" env-default:"4"`
+}
+
+type ImportConfig struct {
+	MaxBodyBytes int64 `env:"MAX_BODY_BYTES" env-default:"67108864"`
+}
+
+type HealthConfig struct {
+	// CheckTimeout limits each readiness check of the database
+	CheckTimeout time.Duration `env:"CHECK_TIMEOUT" env-default:"1s"`
+	// UpstreamCheckTimeout limits readiness check of the song info API
+	UpstreamCheckTimeout time.Duration `env:"UPSTREAM_CHECK_TIMEOUT" env-default:"2s"`
+}
+
 var (
 	once sync.Once
 	cfg  Config
@@ -52,7 +160,67 @@ func New() (Config, error) {
 	var err error
 	once.Do(func() {
 		err = cleanenv.ReadEnv(&cfg)
+		if err == nil {
+			err = cfg.validate()
+		}
 	})
 
 	return cfg, err
 }
+
+func (c Config) validate() error {
+	switch c.Storage {
+	case StoragePostgres:
+		required := []struct{ env, value string }{
+			{"DB_HOST", c.DBConfig.Host},
+			{"DB_PORT", c.DBConfig.Port},
+			{"DB_NAME", c.DBConfig.DBName},
+			{"DB_SSL_MODE", c.DBConfig.SSLMode},
+			{"DB_USERNAME", c.DBConfig.Username},
+			{"DB_PASSWORD", c.DBConfig.Password},
+		}
+		for _, field := range required {
+			if field.value == "" {
+				return errors.Errorf(
+					"%s is required for %s storage", field.env, c.Storage)
+			}
+		}
+	case StorageSQLite:
+		if c.SQLite.Path == "" {
+			return errors.Errorf("SQLITE_PATH is required for %s storage", c.Storage)
+		}
+	case StorageMemory:
+	default:
+		return errors.Errorf("storage \"%s\" is unknown", c.Storage)
+	}
+
+	switch c.Tx.Isolation {
+	case "read committed", "repeatable read", "serializable":
+	default:
+		return errors.Errorf("TX_ISOLATION \"%s\" is unknown", c.Tx.Isolation)
+	}
+
+	// Durations used as intervals, leases and timeouts must be positive
+	type durationField struct {
+		env   string
+		value time.Duration
+	}
+	positive := []durationField{
+		{"IDEMPOTENCY_KEY_TTL", c.Idempotency.KeyTTL},
+		{"IDEMPOTENCY_LEASE", c.Idempotency.Lease},
+		{"IDEMPOTENCY_CLEANUP_INTERVAL", c.Idempotency.CleanupInterval},
+		{"HEALTH_CHECK_TIMEOUT", c.Health.CheckTimeout},
+		{"HEALTH_UPSTREAM_CHECK_TIMEOUT", c.Health.UpstreamCheckTimeout},
+	}
+	if c.Storage == StoragePostgres && len(c.DBConfig.ReplicaDSNs) > 0 {
+		positive = append(positive, durationField{
+			"DB_REPLICA_HEALTH_CHECK_INTERVAL", c.DBConfig.ReplicaHealthCheckInterval})
+	}
+	for _, field := range positive {
+		if field.value <= 0 {
+			return errors.Errorf("%s should be positive", field.env)
+		}
+	}
+
+	return nil
+}
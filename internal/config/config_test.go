@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{
+		Storage: StorageMemory,
+		Tx:      TxConfig{Isolation: "read committed"},
+		Idempotency: IdempotencyConfig{
+			KeyTTL:          24 * time.Hour,
+			Lease:           5 * time.Minute,
+			CleanupInterval: time.Hour,
+		},
+		Health: HealthConfig{
+			CheckTimeout:         time.Second,
+			UpstreamCheckTimeout: 2 * time.Second,
+		},
+	}
+	require.NoError(t, valid.validate())
+
+	cfg := valid
+	cfg.Idempotency.CleanupInterval = 0
+	require.ErrorContains(t, cfg.validate(), "IDEMPOTENCY_CLEANUP_INTERVAL")
+
+	cfg = valid
+	cfg.Idempotency.Lease = -time.Second
+	require.ErrorContains(t, cfg.validate(), "IDEMPOTENCY_LEASE")
+
+	cfg = valid
+	cfg.Health.UpstreamCheckTimeout = 0
+	require.ErrorContains(t, cfg.validate(), "HEALTH_UPSTREAM_CHECK_TIMEOUT")
+
+	// Replica check interval matters only if replicas are set
+	cfg = valid
+	cfg.Storage = StoragePostgres
+	cfg.DBConfig = DBConfig{Host: "db", Port: "5432", DBName: "songs",
+		SSLMode: "disable", Username: "user", Password: "password"}
+	require.NoError(t, cfg.validate())
+	cfg.DBConfig.ReplicaDSNs = []string{"host=replica"}
+	require.ErrorContains(t, cfg.validate(), "DB_REPLICA_HEALTH_CHECK_INTERVAL")
+	cfg.DBConfig.ReplicaHealthCheckInterval = 5 * time.Second
+	require.NoError(t, cfg.validate())
+}
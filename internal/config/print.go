@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Fprint writes config as env variables, one per line. Values
+// of fields tagged with secret are replaced with asterisks
+func (c Config) Fprint(w io.Writer) error {
+	return fprintEnv(w, "", reflect.ValueOf(c))
+}
+
+func fprintEnv(w io.Writer, prefix string, value reflect.Value) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if envPrefix, ok := field.Tag.Lookup("env-prefix"); ok {
+			err := fprintEnv(w, prefix+envPrefix, value.Field(i))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		env, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+
+		fieldValue := fmt.Sprint(value.Field(i).Interface())
+		if _, secret := field.Tag.Lookup("secret"); secret && fieldValue != "" {
+			fieldValue = "********"
+		}
+		_, err := fmt.Fprintf(w, "%s%s=%s\n", prefix, env, fieldValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFprint(t *testing.T) {
+	cfg := Config{
+		Env:      EnvLocal,
+		DBConfig: DBConfig{Host: "db", Password: "secret"},
+		Search:   SearchConfig{AutocompleteTimeout: 200 * time.Millisecond},
+	}
+
+	var out strings.Builder
+	require.NoError(t, cfg.Fprint(&out))
+	require.Contains(t, out.String(), "ENV=local\n")
+	require.Contains(t, out.String(), "DB_HOST=db\n")
+	require.Contains(t, out.String(), "DB_PASSWORD=********\n")
+	require.Contains(t, out.String(), "SEARCH_AUTOCOMPLETE_TIMEOUT=200ms\n")
+	require.NotContains(t, out.String(), "secret")
+}
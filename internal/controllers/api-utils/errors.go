@@ -9,3 +9,14 @@ var (
 type HTTPError struct {
 	Message string `json:"error,omitempty"`
 }
+
+// ValidationHTTPError lists invalid fields of request body
+type ValidationHTTPError struct {
+	Message string       `json:"error,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field" example:"couplets[1]"`
+	Message string `json:"message" example:"should not be empty"`
+}
@@ -0,0 +1,16 @@
+package ginutils
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBodyLimitMiddleware makes reading request body
+// longer than maxBytes fail with *http.MaxBytesError
+func CreateBodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
@@ -17,6 +17,10 @@ func BadRequest(ctx *gin.Context, err error) {
 }
 
 func BindJSONError(ctx *gin.Context, err error) {
+	if IsBodyTooLarge(err) {
+		RequestEntityTooLarge(ctx, err)
+		return
+	}
 	BadRequest(ctx, errors.Wrap(err, "parse and validate JSON body"))
 }
 
@@ -43,3 +47,33 @@ func ConflictError(ctx *gin.Context, err error) {
 func BadGateway(ctx *gin.Context) {
 	Error(ctx, http.StatusBadGateway, errors.New(""))
 }
+
+func ServiceUnavailable(ctx *gin.Context, err error) {
+	Error(ctx, http.StatusServiceUnavailable, err)
+}
+
+func ValidationError(ctx *gin.Context, err error, fields []apiutils.FieldError) {
+	ctx.JSON(http.StatusUnprocessableEntity, apiutils.ValidationHTTPError{
+		Message: err.Error(),
+		Fields:  fields,
+	})
+}
+
+func PreconditionFailed(ctx *gin.Context, err error) {
+	Error(ctx, http.StatusPreconditionFailed, err)
+}
+
+func PreconditionRequired(ctx *gin.Context, err error) {
+	Error(ctx, http.StatusPreconditionRequired, err)
+}
+
+func RequestEntityTooLarge(ctx *gin.Context, err error) {
+	Error(ctx, http.StatusRequestEntityTooLarge, err)
+}
+
+// IsBodyTooLarge tells whether reading body failed
+// on the limit set by CreateBodyLimitMiddleware
+func IsBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
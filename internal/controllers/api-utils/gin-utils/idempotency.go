@@ -0,0 +1,224 @@
+package ginutils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"song-lib/internal/domain"
+	"song-lib/internal/utils"
+	slogutils "song-lib/internal/utils/slog-utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen     = 255
+	idempotencyStoreTimeout  = 5 * time.Second
+)
+
+// replayedResponseHeaders are stored with responses to replay them
+var replayedResponseHeaders = []string{"Content-Type", "ETag", "Location"}
+
+type IdempotencyStore interface {
+	// ClaimIdempotencyKey returns nil if the key is claimed by token
+	// for lease, or the record of the key otherwise. Records older
+	// than ttl and pending ones of the same request whose lease
+	// has expired are claimed again
+	ClaimIdempotencyKey(
+		ctx context.Context, key, fingerprint, token string,
+		ttl, lease time.Duration,
+	) (*domain.IdempotencyRecord, error)
+	// RenewIdempotencyKey, SaveIdempotentResponse and ReleaseIdempotencyKey
+	// fail with domain.ErrIdempotencyKeyLost unless key is claimed by token
+	RenewIdempotencyKey(
+		ctx context.Context, key, token string, lease time.Duration,
+	) error
+	SaveIdempotentResponse(
+		ctx context.Context, key, token string,
+		response *domain.IdempotentResponse,
+	) error
+	ReleaseIdempotencyKey(ctx context.Context, key, token string) error
+}
+
+// CreateIdempotencyMiddleware makes requests with Idempotency-Key header
+// processed once, repeated requests get the stored response. Key can't be
+// reused for different request till it expires in ttl. Keys of requests
+// failed with server errors are released, so that they can be retried.
+// Lease of the key is renewed while the request is processed, keys of
+// requests without response are claimable again once it expires, so
+// that requests of a process died meanwhile can be retried
+func CreateIdempotencyMiddleware(
+	store IdempotencyStore, ttl, lease time.Duration,
+) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			BadRequest(c, errors.Errorf(
+				"%s should be at most %d characters long",
+				IdempotencyKeyHeader, maxIdempotencyKeyLen))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if IsBodyTooLarge(err) {
+			RequestEntityTooLarge(c, err)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			BadRequest(c, errors.Wrap(err, "read body"))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(c.Request, body)
+		token := ksuid.New().String()
+		ctx := utils.PassContextLogger(c, context.Background())
+		record, err := store.ClaimIdempotencyKey(
+			ctx, key, fingerprint, token, ttl, lease)
+		switch {
+		case err != nil:
+			slogutils.Error(ctx, "claim idempotency key:", err)
+			InternalError(c)
+			c.Abort()
+			return
+		case record == nil:
+			processIdempotentRequest(c, ctx, store, key, token, lease)
+			return
+		case record.Fingerprint != fingerprint:
+			Error(c, http.StatusUnprocessableEntity, errors.Errorf(
+				"%s is already used for another request", IdempotencyKeyHeader))
+		case record.Response == nil:
+			ConflictError(c, errors.Errorf(
+				"request with the same %s is being processed", IdempotencyKeyHeader))
+		default:
+			for name, value := range record.Response.Headers {
+				c.Header(name, value)
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.Response.StatusCode,
+				record.Response.Headers["Content-Type"], record.Response.Body)
+		}
+		c.Abort()
+	}
+}
+
+func processIdempotentRequest(
+	c *gin.Context, ctx context.Context,
+	store IdempotencyStore, key, token string, lease time.Duration,
+) {
+	writer := &bodyCapturingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	stopRenewal := renewIdempotencyKey(ctx, store, key, token, lease)
+	c.Next()
+	stopRenewal()
+
+	// Response is stored even if request is cancelled
+	storeCtx, cancel := context.WithTimeout(ctx, idempotencyStoreTimeout)
+	defer cancel()
+
+	if writer.Status() >= http.StatusInternalServerError {
+		err := store.ReleaseIdempotencyKey(storeCtx, key, token)
+		logIdempotencyStoreError(ctx, "release idempotency key:", err)
+		return
+	}
+
+	response := domain.IdempotentResponse{
+		StatusCode: writer.Status(),
+		Headers:    make(map[string]string),
+		Body:       writer.body.Bytes(),
+	}
+	for _, name := range replayedResponseHeaders {
+		if value := writer.Header().Get(name); value != "" {
+			response.Headers[name] = value
+		}
+	}
+	err := store.SaveIdempotentResponse(storeCtx, key, token, &response)
+	logIdempotencyStoreError(ctx, "save idempotent response:", err)
+}
+
+// renewIdempotencyKey renews lease of the key in background
+// till the returned function is called or the key is lost
+func renewIdempotencyKey(
+	ctx context.Context, store IdempotencyStore,
+	key, token string, lease time.Duration,
+) (stop func()) {
+	renewCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Lease is renewed a few times before it
+		// expires, so that a failed renewal is retried
+		ticker := time.NewTicker(max(lease/3, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := store.RenewIdempotencyKey(renewCtx, key, token, lease)
+			if renewCtx.Err() != nil {
+				return
+			}
+			logIdempotencyStoreError(ctx, "renew idempotency key:", err)
+			if errors.Is(err, domain.ErrIdempotencyKeyLost) {
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// logIdempotencyStoreError logs lost key as warning, it's lost if the
+// lease wasn't renewed in time and another request has claimed it
+func logIdempotencyStoreError(ctx context.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrIdempotencyKeyLost):
+		utils.ContextLogger(ctx).Warn(msg, "error", err.Error())
+	case err != nil:
+		slogutils.Error(ctx, msg, err)
+	}
+}
+
+// requestFingerprint identifies request by method, path and body
+func requestFingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type bodyCapturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyCapturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyCapturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,115 @@
+package ginutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"song-lib/internal/domain"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryIdempotencyStore struct {
+	records  map[string]*domain.IdempotencyRecord
+	renewals atomic.Int32
+}
+
+func (s *memoryIdempotencyStore) ClaimIdempotencyKey(
+	_ context.Context, key, fingerprint, _ string, _, _ time.Duration,
+) (*domain.IdempotencyRecord, error) {
+	if record, ok := s.records[key]; ok {
+		return record, nil
+	}
+	s.records[key] = &domain.IdempotencyRecord{Key: key, Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) RenewIdempotencyKey(
+	_ context.Context, _, _ string, _ time.Duration,
+) error {
+	s.renewals.Add(1)
+	return nil
+}
+
+func (s *memoryIdempotencyStore) SaveIdempotentResponse(
+	_ context.Context, key, _ string, response *domain.IdempotentResponse,
+) error {
+	s.records[key].Response = response
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotencyKey(
+	_ context.Context, key, _ string,
+) error {
+	delete(s.records, key)
+	return nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: make(map[string]*domain.IdempotencyRecord)}
+	calls := 0
+	engine := gin.New()
+	engine.POST("/songs", CreateIdempotencyMiddleware(store, time.Hour, time.Minute),
+		func(c *gin.Context) {
+			calls++
+			if calls == 1 {
+				InternalError(c)
+				return
+			}
+			c.Header("ETag", `"1"`)
+			c.JSON(http.StatusCreated, gin.H{"call": calls})
+		})
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/songs", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		res := httptest.NewRecorder()
+		engine.ServeHTTP(res, req)
+		return res
+	}
+
+	// Server error releases the key
+	require.Equal(t, http.StatusInternalServerError, post("key", `{"song":"a"}`).Code)
+
+	first := post("key", `{"song":"a"}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	replayed := post("key", `{"song":"a"}`)
+	require.Equal(t, http.StatusCreated, replayed.Code)
+	require.Equal(t, first.Body.String(), replayed.Body.String())
+	require.Equal(t, `"1"`, replayed.Header().Get("ETag"))
+	require.Equal(t, "true", replayed.Header().Get(IdempotentReplayedHeader))
+	require.Equal(t, 2, calls)
+
+	require.Equal(t, http.StatusUnprocessableEntity, post("key", `{"song":"b"}`).Code)
+}
+
+func TestIdempotencyMiddlewareRenewsLease(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: make(map[string]*domain.IdempotencyRecord)}
+	engine := gin.New()
+	engine.POST("/songs:import",
+		CreateIdempotencyMiddleware(store, time.Hour, 3*time.Millisecond),
+		func(c *gin.Context) {
+			// Request takes longer than the lease
+			time.Sleep(20 * time.Millisecond)
+			c.JSON(http.StatusOK, gin.H{})
+		})
+
+	req := httptest.NewRequest(http.MethodPost, "/songs:import", strings.NewReader("{}"))
+	req.Header.Set(IdempotencyKeyHeader, "key")
+	res := httptest.NewRecorder()
+	engine.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Positive(t, store.renewals.Load())
+
+	renewals := store.renewals.Load()
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, renewals, store.renewals.Load())
+}
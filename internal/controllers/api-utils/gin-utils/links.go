@@ -0,0 +1,40 @@
+package ginutils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Link is an RFC 8288 link to the requested resource
+// with query params changed by Params, nil value removes param
+type Link struct {
+	Rel    string
+	Params map[string]*string
+}
+
+func SetLinkHeader(ctx *gin.Context, links ...Link) {
+	if len(links) == 0 {
+		return
+	}
+
+	headerValues := make([]string, 0, len(links))
+	for _, link := range links {
+		query := ctx.Request.URL.Query()
+		for param, value := range link.Params {
+			if value == nil {
+				query.Del(param)
+			} else {
+				query.Set(param, *value)
+			}
+		}
+
+		target := *ctx.Request.URL
+		target.RawQuery = query.Encode()
+		headerValues = append(headerValues,
+			fmt.Sprintf(`<%s>; rel="%s"`, target.RequestURI(), link.Rel))
+	}
+
+	ctx.Header("Link", strings.Join(headerValues, ", "))
+}
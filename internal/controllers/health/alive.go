@@ -0,0 +1,18 @@
+package healthcontroller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type aliveResponseBody struct {
+	Status string `json:"status"`
+}
+
+// alive responds as long as the process serves requests,
+// dependencies are not checked, so that their failures
+// don't make the orchestrator restart the server
+func (ctr *HealthController) alive(c *gin.Context) {
+	c.JSON(http.StatusOK, aliveResponseBody{Status: "alive"})
+}
@@ -0,0 +1,44 @@
+package healthcontroller
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	checks []Check
+	// shuttingDown makes the server not ready, so that
+	// requests are not routed to it while it shuts down
+	shuttingDown atomic.Bool
+}
+
+// Check is a readiness check of a dependency,
+// it fails if it doesn't pass within Timeout
+type Check struct {
+	Name    string
+	Timeout time.Duration
+	// Critical check makes the server not ready if it fails, failures
+	// of other ones are only reported, as the server is of use without
+	// their dependencies, and restarts wouldn't bring them back
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+func NewHealthController(checks []Check) *HealthController {
+	return &HealthController{checks: checks}
+}
+
+// MarkShuttingDown makes the server not ready till it exits
+func (c *HealthController) MarkShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// RegisterRoutes registers probes outside of the
+// API, as they are for the orchestrator only
+func (c *HealthController) RegisterRoutes(engine *gin.Engine) {
+	engine.GET("healthz", c.alive)
+	engine.GET("readyz", c.ready)
+}
@@ -0,0 +1,94 @@
+package healthcontroller
+
+import (
+	"context"
+	"net/http"
+	"song-lib/internal/utils"
+	slogutils "song-lib/internal/utils/slog-utils"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	statusReady        = "ready"
+	statusNotReady     = "not ready"
+	statusShuttingDown = "shutting down"
+
+	checkStatusOK       = "ok"
+	checkStatusFailed   = "failed"
+	checkStatusTimedOut = "timed out"
+)
+
+type readyResponseBody struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// checkResult has no error details as they may reveal
+// internals, failed checks are logged instead
+type checkResult struct {
+	Status     string `json:"status"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// ready runs all checks at once and responds with 503 if any critical
+// one fails or the server shuts down, so that the orchestrator doesn't
+// route requests to the server meanwhile
+func (ctr *HealthController) ready(c *gin.Context) {
+	if ctr.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable,
+			readyResponseBody{Status: statusShuttingDown})
+		return
+	}
+
+	ctx := utils.PassContextLogger(c, c.Request.Context())
+	results := make([]checkResult, len(ctr.checks))
+	var wg sync.WaitGroup
+	for i, check := range ctr.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	respBody := readyResponseBody{
+		Status: statusReady,
+		Checks: make(map[string]checkResult, len(ctr.checks)),
+	}
+	for i, check := range ctr.checks {
+		respBody.Checks[check.Name] = results[i]
+		if check.Critical && results[i].Status != checkStatusOK {
+			status = http.StatusServiceUnavailable
+			respBody.Status = statusNotReady
+		}
+	}
+	c.JSON(status, respBody)
+}
+
+func runCheck(ctx context.Context, check Check) checkResult {
+	checkCtx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(checkCtx)
+	result := checkResult{
+		Status:     checkStatusOK,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		slogutils.Error(ctx, "readiness:",
+			errors.Wrapf(err, "check %s", check.Name))
+		result.Status = checkStatusFailed
+		if errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+			result.Status = checkStatusTimedOut
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,71 @@
+package healthcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var dbErr error
+	controller := NewHealthController([]Check{
+		{
+			Name:     "database",
+			Timeout:  time.Second,
+			Critical: true,
+			Run: func(context.Context) error {
+				return dbErr
+			},
+		},
+		{
+			Name:    "upstream",
+			Timeout: time.Millisecond,
+			Run: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+	})
+	engine := gin.New()
+	controller.RegisterRoutes(engine)
+
+	get := func(path string) (int, readyResponseBody) {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var respBody readyResponseBody
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &respBody))
+		return rec.Code, respBody
+	}
+
+	code, _ := get("/healthz")
+	require.Equal(t, http.StatusOK, code)
+
+	dbErr = errors.New("connection refused")
+	code, respBody := get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, statusNotReady, respBody.Status)
+	require.Equal(t, checkStatusFailed, respBody.Checks["database"].Status)
+	require.Equal(t, checkStatusTimedOut, respBody.Checks["upstream"].Status)
+
+	// Failed upstream is reported without making the server not ready
+	dbErr = nil
+	code, respBody = get("/readyz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, statusReady, respBody.Status)
+	require.Equal(t, checkStatusOK, respBody.Checks["database"].Status)
+	require.Equal(t, checkStatusTimedOut, respBody.Checks["upstream"].Status)
+
+	controller.MarkShuttingDown()
+	code, respBody = get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, statusShuttingDown, respBody.Status)
+	require.Empty(t, respBody.Checks)
+}
@@ -0,0 +1,108 @@
+package autocompletecontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/domain"
+	"song-lib/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSuggestionsLimit = 10
+	maxSuggestionsLimit     = 50
+)
+
+type autocompleteRequestQuery struct {
+	Query string  `form:"q" binding:"required"`
+	Kind  *string `form:"kind"`
+	Limit *int    `form:"limit"`
+}
+
+type autocompleteResponseBody struct {
+	Suggestions []suggestionDTO `json:"suggestions"`
+}
+
+type suggestionDTO struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	MusicGroupName string `json:"group,omitempty"`
+}
+
+//	@Summary		Autocomplete song or music group names
+//	@Description	Lightweight name suggestions for type-ahead, prefix matches go first, then more popular ones
+//	@Tags			autocomplete
+//	@Produce		json
+//	@Param			q		query		string						true	"Beginning or part of the name"
+//	@Param			kind	query		string						false	"What to suggest"						Enums(song, group)	default(song)
+//	@Param			limit	query		int							false	"Maximum number of suggestions (1-50)"	default(10)
+//	@Success		200		{object}	autocompleteResponseBody	"Success"
+//	@Failure		400		{object}	apiutils.HTTPError			"Invalid query params"
+//	@Failure		503		{object}	apiutils.HTTPError			"Suggestions took too long"
+//	@Failure		500		{object}	apiutils.HTTPError			"Internal server error"
+//	@Router			/autocomplete [get]
+func (ctr *AutocompleteController) autocomplete(c *gin.Context) {
+	var reqQuery autocompleteRequestQuery
+	if err := c.ShouldBindQuery(&reqQuery); err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+	if err := reqQuery.validate(); err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+
+	kind := domain.AutocompleteKindSong
+	if reqQuery.Kind != nil {
+		kind = domain.AutocompleteKind(*reqQuery.Kind)
+	}
+	limit := defaultSuggestionsLimit
+	if reqQuery.Limit != nil {
+		limit = *reqQuery.Limit
+	}
+
+	ctx, cancel := context.WithTimeout(
+		utils.PassContextLogger(c, context.Background()), ctr.timeout)
+	defer cancel()
+	suggestions, err := ctr.songService.Autocomplete(
+		ctx, reqQuery.Query, kind, limit)
+	switch {
+	case errors.Is(err, domain.ErrTimeout):
+		ginutils.ServiceUnavailable(c, err)
+		return
+	case err != nil:
+		ginutils.InternalError(c)
+		return
+	}
+
+	suggestionDTOs := make([]suggestionDTO, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		suggestionDTOs = append(suggestionDTOs, suggestionDTO{
+			ID:             suggestion.ID.String(),
+			Name:           suggestion.Name,
+			MusicGroupName: suggestion.MusicGroupName,
+		})
+	}
+	c.JSON(http.StatusOK, autocompleteResponseBody{
+		Suggestions: suggestionDTOs,
+	})
+}
+
+func (q *autocompleteRequestQuery) validate() error {
+	if q.Kind != nil {
+		switch domain.AutocompleteKind(*q.Kind) {
+		case domain.AutocompleteKindSong, domain.AutocompleteKindGroup:
+		default:
+			return fmt.Errorf("kind value \"%s\" is unknown", *q.Kind)
+		}
+	}
+	if q.Limit != nil && (*q.Limit < 1 || *q.Limit > maxSuggestionsLimit) {
+		return fmt.Errorf("limit value is not in range [1;%d]", maxSuggestionsLimit)
+	}
+
+	return nil
+}
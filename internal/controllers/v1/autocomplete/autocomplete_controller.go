@@ -0,0 +1,38 @@
+package autocompletecontroller
+
+import (
+	"context"
+	controllers "song-lib/internal/controllers"
+	"song-lib/internal/domain"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AutocompleteController struct {
+	songService SongService
+	timeout     time.Duration
+}
+
+type SongService interface {
+	Autocomplete(
+		ctx context.Context,
+		query string,
+		kind domain.AutocompleteKind,
+		limit int,
+	) ([]domain.AutocompleteSuggestion, error)
+}
+
+func NewAutocompleteController(
+	songService SongService,
+	timeout time.Duration,
+) controllers.Controller {
+	return &AutocompleteController{
+		songService: songService,
+		timeout:     timeout,
+	}
+}
+
+func (c *AutocompleteController) RegisterRoutes(engine *gin.Engine) {
+	engine.GET("api/v1/autocomplete", c.autocomplete)
+}
@@ -15,6 +15,185 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/autocomplete": {
+            "get": {
+                "description": "Lightweight name suggestions for type-ahead, prefix matches go first, then more popular ones",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "autocomplete"
+                ],
+                "summary": "Autocomplete song or music group names",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Beginning or part of the name",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "song",
+                            "group"
+                        ],
+                        "type": "string",
+                        "default": "song",
+                        "description": "What to suggest",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Maximum number of suggestions (1-50)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "$ref": "#/definitions/autocompletecontroller.autocompleteResponseBody"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query params",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Suggestions took too long",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/export": {
+            "get": {
+                "description": "Stream all filtered songs ordered by ID as JSON Lines of songs, CSV or ZIP of plain text lyrics in group/song.txt files. JSON Lines and CSV are gzip encoded if client accepts it",
+                "produces": [
+                    "application/jsonl",
+                    "text/csv",
+                    "application/zip"
+                ],
+                "tags": [
+                    "song"
+                ],
+                "summary": "Export songs",
+                "parameters": [
+                    {
+                        "enum": [
+                            "jsonl",
+                            "csv",
+                            "zip"
+                        ],
+                        "type": "string",
+                        "default": "jsonl",
+                        "description": "Export format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter for name, see match",
+                        "name": "song",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter for music group name, see match",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "exact",
+                            "prefix",
+                            "fuzzy"
+                        ],
+                        "type": "string",
+                        "default": "exact",
+                        "description": "Matching mode for song and group filters",
+                        "name": "match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Equality filter for link",
+                        "name": "link",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "'in' filter for text",
+                        "name": "text_contains",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "'in range' filter for release date, see GET /songs",
+                        "name": "release_date_range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter for release year",
+                        "name": "release_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter for release year, month or day e.g., 2001-05",
+                        "name": "released",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression, see GET /songs",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "gzip to compress JSON Lines and CSV",
+                        "name": "Accept-Encoding",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Songs",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query params",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
         "/songs": {
             "get": {
                 "produces": [
@@ -27,30 +206,52 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Number of page to return",
+                        "description": "Number of page to return, required unless limit is set",
                         "name": "page",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Number of items per returned page",
+                        "description": "Number of items per returned page, required unless limit is set",
                         "name": "per_page",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of items per returned page for cursor pagination",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "nextCursor or prevCursor from previous response, requires limit and the same sort",
+                        "name": "cursor",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Equality filter for name",
+                        "description": "Filter for name, see match",
                         "name": "song",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Equality filter for music group name",
+                        "description": "Filter for music group name, see match",
                         "name": "group",
                         "in": "query"
                     },
+                    {
+                        "enum": [
+                            "exact",
+                            "prefix",
+                            "fuzzy"
+                        ],
+                        "type": "string",
+                        "default": "exact",
+                        "description": "Matching mode for song and group filters",
+                        "name": "match",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Equality filter for link",
@@ -65,9 +266,57 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "'in range' filter for release data e.g., [12-03-2001;21-11-2024]",
+                        "description": "'in range' filter for release date e.g., [2001-03-12;2024-11-21]. Parentheses exclude bounds, bounds can be omitted e.g., (2010-01-01;]. Relative ranges ending today: last_N_days, last_N_weeks, last_N_months, last_N_years",
                         "name": "release_date_range",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter expression combined with other filters, e.g., group:\\",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter for release year",
+                        "name": "release_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter for release year, month or day e.g., 2001-05",
+                        "name": "released",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "exact",
+                            "estimated",
+                            "none"
+                        ],
+                        "type": "string",
+                        "default": "exact",
+                        "description": "How to count total, estimated count is faster for large result sets. Defaults to none if cursor is set",
+                        "name": "count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort keys, '-' prefix for descending order: name, group, releaseDate, createdAt, updatedAt, relevance e.g., -releaseDate,name",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated song fields to return besides id: name, group, releaseDate, link, createdAt, updatedAt. All of them by default",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated relations to return: couplets. Couplets are returned by default only if neither fields nor include is set",
+                        "name": "include",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -75,6 +324,18 @@ const docTemplate = `{
                         "description": "Success",
                         "schema": {
                             "$ref": "#/definitions/songcontroller.getSongsResponseBody"
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "RFC 8288 links to first, prev, next and last pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query params",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
                         }
                     },
                     "500": {
@@ -104,6 +365,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/songcontroller.createSongRequestBody"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key of the request, repeated requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -111,14 +378,30 @@ const docTemplate = `{
                         "description": "Success",
                         "schema": {
                             "$ref": "#/definitions/songcontroller.songDTO"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version tag"
+                            },
+                            "Idempotent-Replayed": {
+                                "type": "string",
+                                "description": "true if response is replayed for repeated request"
+                            }
                         }
                     },
                     "409": {
-                        "description": "Song already exists",
+                        "description": "Song already exists or request with the same Idempotency-Key is being processed",
                         "schema": {
                             "$ref": "#/definitions/apiutils.HTTPError"
                         }
                     },
+                    "422": {
+                        "description": "Invalid song details or Idempotency-Key is used for another request",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.ValidationHTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -135,15 +418,14 @@ const docTemplate = `{
             }
         },
         "/songs/{songID}": {
-            "put": {
-                "description": "Update song by passing fields to be updated",
-                "consumes": [
+            "get": {
+                "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "song"
                 ],
-                "summary": "Update song",
+                "summary": "Get song",
                 "parameters": [
                     {
                         "type": "string",
@@ -153,18 +435,27 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Song updating details",
-                        "name": "update_info",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/songcontroller.updateSongRequestBody"
-                        }
+                        "type": "string",
+                        "description": "ETag of cached song",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "Success",
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.songDTO"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version tag"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Song has not changed",
                         "schema": {
                             "type": "nil"
                         }
@@ -183,11 +474,15 @@ const docTemplate = `{
                     }
                 }
             },
-            "delete": {
+            "put": {
+                "description": "Update song by passing fields to be updated",
+                "consumes": [
+                    "application/json"
+                ],
                 "tags": [
                     "song"
                 ],
-                "summary": "Delete song",
+                "summary": "Update song",
                 "parameters": [
                     {
                         "type": "string",
@@ -195,6 +490,21 @@ const docTemplate = `{
                         "name": "songID",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Song updating details",
+                        "name": "update_info",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.updateSongRequestBody"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of song to update, required if server is configured so",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -202,6 +512,12 @@ const docTemplate = `{
                         "description": "Success",
                         "schema": {
                             "type": "nil"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version tag"
+                            }
                         }
                     },
                     "404": {
@@ -210,6 +526,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/apiutils.HTTPError"
                         }
                     },
+                    "412": {
+                        "description": "Song version doesn't match If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid song fields",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.ValidationHTTPError"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -217,18 +551,12 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/songs/{songID}/couplets": {
-            "get": {
-                "description": "Get song text with optional pagination by couplets",
-                "produces": [
-                    "application/json"
-                ],
+            },
+            "delete": {
                 "tags": [
                     "song"
                 ],
-                "summary": "Get song text",
+                "summary": "Delete song",
                 "parameters": [
                     {
                         "type": "string",
@@ -238,25 +566,17 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Number of page with couplets to return",
-                        "name": "couplets_page",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Number of couplets per page to return",
-                        "name": "couplets_per_page",
-                        "in": "query",
-                        "required": true
+                        "type": "string",
+                        "description": "ETag of song to delete, required if server is configured so",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "Success",
                         "schema": {
-                            "$ref": "#/definitions/songcontroller.getSongCoupletsResponseBody"
+                            "type": "nil"
                         }
                     },
                     "404": {
@@ -265,17 +585,390 @@ const docTemplate = `{
                             "$ref": "#/definitions/apiutils.HTTPError"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "412": {
+                        "description": "Song version doesn't match If-Match",
                         "schema": {
                             "$ref": "#/definitions/apiutils.HTTPError"
                         }
-                    }
-                }
-            }
-        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Patch song with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) applied to {\"name\", \"releaseDate\", \"link\", \"couplets\"} document. Couplets can be edited by index e.g., /couplets/1. Link is removed by setting it to null",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "song"
+                ],
+                "summary": "Patch song",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Song ID",
+                        "name": "songID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch object or JSON Patch operations array",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of song to patch, required if server is configured so",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.songDTO"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Song version tag"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed patch",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "JSON Patch test operation failed",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "412": {
+                        "description": "Song version doesn't match If-Match",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported patch format",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Patch can't be applied or patched song is invalid",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.ValidationHTTPError"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is required",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{songID}/couplets": {
+            "get": {
+                "description": "Get song text with optional pagination by couplets",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "song"
+                ],
+                "summary": "Get song text",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Song ID",
+                        "name": "songID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of page with couplets to return, required unless limit is set",
+                        "name": "couplets_page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of couplets per page to return, required unless limit is set",
+                        "name": "couplets_per_page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of couplets per page for cursor pagination",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "nextCursor or prevCursor from previous response, requires limit",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "exact",
+                            "none"
+                        ],
+                        "type": "string",
+                        "default": "exact",
+                        "description": "Whether to count total. Defaults to none if cursor is set",
+                        "name": "count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.getSongCoupletsResponseBody"
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "RFC 8288 links to first, prev, next and last pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query params",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs:batch": {
+            "post": {
+                "description": "Create, update and delete songs in a single request. Atomic batch is applied only if every operation succeeds",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "song"
+                ],
+                "summary": "Apply batch of song operations",
+                "parameters": [
+                    {
+                        "description": "Operations",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.batchSongsRequestBody"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key of the request, repeated requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Results of operations in request order",
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.batchSongsResponseBody"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid operations",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "413": {
+                        "description": "Too many operations or too large body",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "428": {
+                        "description": "ifMatch is required",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs:import": {
+            "post": {
+                "description": "Import songs from CSV with header or JSON Lines. Rows have song and group fields and optional text, releaseDate (YYYY, YYYY-MM or YYYY-MM-DD) and link ones. Missing optional fields are taken from the song info service",
+                "consumes": [
+                    "text/csv",
+                    "application/jsonl",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "song"
+                ],
+                "summary": "Import songs",
+                "parameters": [
+                    {
+                        "description": "Songs to import",
+                        "name": "songs",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Validate rows without saving them",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "skip",
+                            "update",
+                            "fail"
+                        ],
+                        "type": "string",
+                        "default": "skip",
+                        "description": "What to do with existing songs",
+                        "name": "on_duplicate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key of the request, repeated requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Import report",
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.importReportDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed source, rows before the malformed one are imported",
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.importReportDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Request with the same Idempotency-Key is being processed",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "413": {
+                        "description": "Source is too large, rows read before the limit are imported",
+                        "schema": {
+                            "$ref": "#/definitions/songcontroller.importReportDTO"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported source format",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key is used for another request",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apiutils.HTTPError"
+                        }
+                    }
+                }
+            }
+        }
     },
     "definitions": {
+        "apiutils.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "couplets[1]"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "should not be empty"
+                }
+            }
+        },
         "apiutils.HTTPError": {
             "type": "object",
             "properties": {
@@ -284,6 +977,145 @@ const docTemplate = `{
                 }
             }
         },
+        "apiutils.ValidationHTTPError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apiutils.FieldError"
+                    }
+                }
+            }
+        },
+        "autocompletecontroller.autocompleteResponseBody": {
+            "type": "object",
+            "properties": {
+                "suggestions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/autocompletecontroller.suggestionDTO"
+                    }
+                }
+            }
+        },
+        "autocompletecontroller.suggestionDTO": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "songcontroller.batchOperationRequest": {
+            "type": "object",
+            "required": [
+                "op"
+            ],
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID of song to update or delete",
+                    "type": "string"
+                },
+                "ifMatch": {
+                    "description": "IfMatch is ETag of song to update or delete,\nrequired if server is configured so",
+                    "type": "string",
+                    "example": "\"3\""
+                },
+                "op": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete"
+                    ]
+                },
+                "song": {
+                    "description": "Song and Group are required for creation",
+                    "type": "string"
+                },
+                "update": {
+                    "description": "Update is required for update",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/songcontroller.updateSongRequestBody"
+                        }
+                    ]
+                }
+            }
+        },
+        "songcontroller.batchOperationResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error is set for failed operations, operations of failed\natomic batch that are not applied have status 424",
+                    "type": "string"
+                },
+                "etag": {
+                    "type": "string",
+                    "example": "\"1\""
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apiutils.FieldError"
+                    }
+                },
+                "song": {
+                    "$ref": "#/definitions/songcontroller.songDTO"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 201
+                }
+            }
+        },
+        "songcontroller.batchSongsRequestBody": {
+            "type": "object",
+            "required": [
+                "operations"
+            ],
+            "properties": {
+                "atomic": {
+                    "description": "Atomic batch is applied only if every operation succeeds,\notherwise operations are applied independently",
+                    "type": "boolean"
+                },
+                "operations": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/songcontroller.batchOperationRequest"
+                    }
+                }
+            }
+        },
+        "songcontroller.batchSongsResponseBody": {
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "description": "Committed is false if atomic batch was rolled back",
+                    "type": "boolean"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/songcontroller.batchOperationResult"
+                    }
+                }
+            }
+        },
         "songcontroller.createSongRequestBody": {
             "type": "object",
             "required": [
@@ -302,22 +1134,125 @@ const docTemplate = `{
         "songcontroller.getSongCoupletsResponseBody": {
             "type": "object",
             "properties": {
+                "nextCursor": {
+                    "type": "string"
+                },
+                "page": {
+                    "description": "Page and TotalPages are not set for cursor pagination",
+                    "type": "integer"
+                },
+                "perPage": {
+                    "type": "integer"
+                },
+                "prevCursor": {
+                    "type": "string"
+                },
                 "songCouplets": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "totalEstimated": {
+                    "type": "boolean"
+                },
+                "totalPages": {
+                    "type": "integer"
                 }
             }
         },
         "songcontroller.getSongsResponseBody": {
             "type": "object",
             "properties": {
+                "didYouMean": {
+                    "$ref": "#/definitions/songcontroller.songSearchSuggestionDTO"
+                },
+                "nextCursor": {
+                    "type": "string"
+                },
+                "page": {
+                    "description": "Page and TotalPages are not set for cursor pagination",
+                    "type": "integer"
+                },
+                "perPage": {
+                    "type": "integer"
+                },
+                "prevCursor": {
+                    "type": "string"
+                },
                 "songs": {
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/songcontroller.songDTO"
                     }
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "totalEstimated": {
+                    "type": "boolean"
+                },
+                "totalPages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "songcontroller.importReportDTO": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "description": "Error is set if import is stopped at unreadable row,\nrows before it are imported",
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "description": "Rows lists skipped and failed rows",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/songcontroller.importRowReportDTO"
+                    }
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "songcontroller.importRowReportDTO": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apiutils.FieldError"
+                    }
+                },
+                "line": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "skipped",
+                        "failed"
+                    ]
                 }
             }
         },
@@ -341,6 +1276,9 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "createdAt": {
+                    "type": "string"
+                },
                 "group": {
                     "$ref": "#/definitions/songcontroller.musicGroupDTO"
                 },
@@ -348,12 +1286,32 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "link": {
+                    "description": "Link is null if song has none",
                     "type": "string"
                 },
                 "name": {
                     "type": "string"
                 },
                 "releaseDate": {
+                    "description": "ReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD\ndepending on how precisely it is known",
+                    "type": "string",
+                    "example": "2001-05"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "songcontroller.songSearchSuggestionDTO": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "song": {
                     "type": "string"
                 }
             }
@@ -374,7 +1332,9 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "releaseDate": {
-                    "type": "string"
+                    "description": "ReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD",
+                    "type": "string",
+                    "example": "2001-05"
                 }
             }
         }
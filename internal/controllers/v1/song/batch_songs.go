@@ -0,0 +1,234 @@
+package songcontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	apiutils "song-lib/internal/controllers/api-utils"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/domain"
+	"song-lib/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+type batchSongsRequestBody struct {
+	// Atomic batch is applied only if every operation succeeds,
+	// otherwise operations are applied independently
+	Atomic     bool                    `json:"atomic"`
+	Operations []batchOperationRequest `json:"operations" binding:"required,min=1,dive"`
+}
+
+type batchOperationRequest struct {
+	Op string `json:"op" binding:"required,oneof=create update delete" enums:"create,update,delete"`
+	// ID of song to update or delete
+	ID *string `json:"id"`
+	// IfMatch is ETag of song to update or delete,
+	// required if server is configured so
+	IfMatch *string `json:"ifMatch" example:"\"3\""`
+	// Song and Group are required for creation
+	Song  *string `json:"song"`
+	Group *string `json:"group"`
+	// Update is required for update
+	Update *updateSongRequestBody `json:"update"`
+}
+
+type batchSongsResponseBody struct {
+	// Committed is false if atomic batch was rolled back
+	Committed bool                   `json:"committed"`
+	Results   []batchOperationResult `json:"results"`
+}
+
+// batchOperationResult has status and body of response
+// the operation would get as a separate request
+type batchOperationResult struct {
+	Status int      `json:"status" example:"201"`
+	Song   *songDTO `json:"song,omitempty"`
+	ETag   string   `json:"etag,omitempty" example:"\"1\""`
+	// Error is set for failed operations, operations of failed
+	// atomic batch that are not applied have status 424
+	Error  string                `json:"error,omitempty"`
+	Fields []apiutils.FieldError `json:"fields,omitempty"`
+}
+
+//	@Summary		Apply batch of song operations
+//	@Description	Create, update and delete songs in a single request. Atomic batch is applied only if every operation succeeds
+//	@Tags			song
+//	@Accept			json
+//	@Produce		json
+//	@Param			batch			body		batchSongsRequestBody	true	"Operations"
+//	@Param			Idempotency-Key	header		string					false	"Unique key of the request, repeated requests with the same key get the first response"
+//	@Success		200				{object}	batchSongsResponseBody	"Results of operations in request order"
+//	@Failure		400				{object}	apiutils.HTTPError		"Invalid operations"
+//	@Failure		413				{object}	apiutils.HTTPError		"Too many operations or too large body"
+//	@Failure		428				{object}	apiutils.HTTPError		"ifMatch is required"
+//	@Failure		500				{object}	apiutils.HTTPError		"Internal server error"
+//	@Router			/songs:batch [post]
+func (ctr *SongController) batchSongs(c *gin.Context) {
+	var reqBody batchSongsRequestBody
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		ginutils.BindJSONError(c, err)
+		return
+	}
+	if len(reqBody.Operations) > ctr.options.MaxBatchOperations {
+		ginutils.RequestEntityTooLarge(c, errors.Errorf(
+			"batch should have at most %d operations",
+			ctr.options.MaxBatchOperations))
+		return
+	}
+
+	operations := make([]domain.SongBatchOperation, 0, len(reqBody.Operations))
+	for i, opReq := range reqBody.Operations {
+		op, ok := ctr.toSongBatchOperation(c, i, &opReq)
+		if !ok {
+			return
+		}
+		operations = append(operations, op)
+	}
+
+	ctx := utils.PassContextLogger(c, context.Background())
+	batchResult, err := ctr.songService.ApplySongBatch(ctx, operations, reqBody.Atomic)
+	if err != nil {
+		ginutils.InternalError(c)
+		return
+	}
+
+	resBody := batchSongsResponseBody{
+		Committed: batchResult.Committed,
+		Results:   make([]batchOperationResult, 0, len(batchResult.Items)),
+	}
+	for i, item := range batchResult.Items {
+		resBody.Results = append(resBody.Results,
+			newBatchOperationResult(operations[i].Kind, &item))
+	}
+	c.JSON(http.StatusOK, resBody)
+}
+
+// toSongBatchOperation writes error response
+// and returns false if operation is invalid
+func (ctr *SongController) toSongBatchOperation(
+	c *gin.Context, i int, opReq *batchOperationRequest,
+) (domain.SongBatchOperation, bool) {
+	op := domain.SongBatchOperation{Kind: domain.SongBatchOperationKind(opReq.Op)}
+	badRequest := func(format string, args ...any) (domain.SongBatchOperation, bool) {
+		ginutils.BadRequest(c, fmt.Errorf("operations[%d]: "+format, append([]any{i}, args...)...))
+		return op, false
+	}
+
+	if op.Kind == domain.SongBatchOperationCreate {
+		if opReq.Song == nil || opReq.Group == nil {
+			return badRequest("song and group are required for creation")
+		}
+		op.Create = &domain.CreateSongDTO{
+			SongName:       *opReq.Song,
+			MusicGroupName: *opReq.Group,
+		}
+		return op, true
+	}
+
+	if opReq.ID == nil {
+		return badRequest("id is required for %s", op.Kind)
+	}
+	songID, err := ksuid.Parse(*opReq.ID)
+	if err != nil {
+		return badRequest("parse id: %s", err)
+	}
+	op.SongID = songID
+
+	switch {
+	case opReq.IfMatch == nil && ctr.options.RequireIfMatch:
+		ginutils.PreconditionRequired(c, fmt.Errorf(
+			"operations[%d]: ifMatch is required for %s", i, op.Kind))
+		return op, false
+	case opReq.IfMatch != nil && *opReq.IfMatch != "*":
+		versions, err := parseIfMatchVersions(*opReq.IfMatch)
+		switch {
+		case err != nil:
+			return badRequest("parse ifMatch: %s", err)
+		case len(versions) > 1:
+			return badRequest("ifMatch should be a single entity tag")
+		case len(versions) == 0:
+			// Weak and unknown tags match no version
+			versions = append(versions, noSongVersion)
+		}
+		op.Version = &versions[0]
+	}
+
+	if op.Kind == domain.SongBatchOperationDelete {
+		return op, true
+	}
+	if opReq.Update == nil {
+		return badRequest("update is required for update")
+	}
+	if err := opReq.Update.validate(); err != nil {
+		return badRequest("%s", err)
+	}
+	songUpdate, validationErr := opReq.Update.toSongUpdate()
+	if validationErr != nil {
+		// Operation fails alone, as it would fail validation
+		op.Err = validationErr
+		return op, true
+	}
+	op.Update = songUpdate
+
+	return op, true
+}
+
+func newBatchOperationResult(
+	kind domain.SongBatchOperationKind, item *domain.SongBatchItemResult,
+) batchOperationResult {
+	if item.Err != nil {
+		return newFailedBatchOperationResult(kind, item.Err)
+	}
+
+	switch kind {
+	case domain.SongBatchOperationCreate:
+		return batchOperationResult{
+			Status: http.StatusCreated,
+			Song:   newSongDTOFromEntity(item.Song),
+			ETag:   songETag(item.Song),
+		}
+	case domain.SongBatchOperationUpdate:
+		return batchOperationResult{
+			Status: http.StatusOK,
+			Song:   newSongDTOFromEntity(item.Song),
+			ETag:   songETag(item.Song),
+		}
+	default:
+		return batchOperationResult{Status: http.StatusNoContent}
+	}
+}
+
+// newFailedBatchOperationResult has the same status and error
+// message as the operation would get as a separate request
+func newFailedBatchOperationResult(
+	kind domain.SongBatchOperationKind, err error,
+) batchOperationResult {
+	var validationErr *domain.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		fieldNames := map[domain.SongField]string(nil)
+		if kind == domain.SongBatchOperationCreate {
+			fieldNames = createSongRequestFields
+		}
+		return batchOperationResult{
+			Status: http.StatusUnprocessableEntity,
+			Error:  err.Error(),
+			Fields: newFieldErrors(validationErr, fieldNames),
+		}
+	case errors.Is(err, domain.ErrSongNotFound):
+		return batchOperationResult{Status: http.StatusNotFound, Error: err.Error()}
+	case errors.Is(err, domain.ErrSongAlreadyExists):
+		return batchOperationResult{Status: http.StatusConflict, Error: err.Error()}
+	case errors.Is(err, domain.ErrVersionMismatch):
+		return batchOperationResult{Status: http.StatusPreconditionFailed, Error: err.Error()}
+	case errors.Is(err, domain.ErrBatchAborted):
+		return batchOperationResult{Status: http.StatusFailedDependency, Error: err.Error()}
+	case errors.Is(err, domain.ErrIntegration):
+		return batchOperationResult{Status: http.StatusBadGateway}
+	default:
+		return batchOperationResult{Status: http.StatusInternalServerError}
+	}
+}
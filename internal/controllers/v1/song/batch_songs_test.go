@@ -0,0 +1,97 @@
+package songcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"song-lib/internal/domain"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+)
+
+type batchSongService struct {
+	SongService
+	operations []domain.SongBatchOperation
+}
+
+func (s *batchSongService) ApplySongBatch(
+	_ context.Context, operations []domain.SongBatchOperation, atomic bool,
+) (*domain.SongBatchResult, error) {
+	s.operations = operations
+	return &domain.SongBatchResult{
+		Committed: !atomic,
+		Items: []domain.SongBatchItemResult{
+			{Song: &domain.Song{Name: "Uprising", Version: 1}},
+			{Err: domain.ErrVersionMismatch},
+			{},
+		},
+	}, nil
+}
+
+func TestBatchSongs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := &batchSongService{}
+	engine := gin.New()
+	NewSongController(service, Options{
+		MaxBatchOperations: 3,
+		MaxBatchBodyBytes:  1024,
+	}).RegisterRoutes(engine)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		res := httptest.NewRecorder()
+		engine.ServeHTTP(res, req)
+		return res
+	}
+
+	songID := ksuid.New().String()
+	res := post("/api/v1/songs:batch", `{"operations": [
+		{"op": "create", "song": "Uprising", "group": "Muse"},
+		{"op": "update", "id": "`+songID+`", "ifMatch": "\"2\"", "update": {"name": "Resistance"}},
+		{"op": "delete", "id": "`+songID+`"}
+	]}`)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Len(t, service.operations, 3)
+	require.Equal(t, int64(2), *service.operations[1].Version)
+	require.Nil(t, service.operations[2].Version)
+
+	var resBody batchSongsResponseBody
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &resBody))
+	require.True(t, resBody.Committed)
+	require.Equal(t, http.StatusCreated, resBody.Results[0].Status)
+	require.Equal(t, `"1"`, resBody.Results[0].ETag)
+	require.Equal(t, http.StatusPreconditionFailed, resBody.Results[1].Status)
+	require.Equal(t, http.StatusNoContent, resBody.Results[2].Status)
+
+	// Invalid update fails alone and is still passed to service
+	res = post("/api/v1/songs:batch", `{"operations": [
+		{"op": "create", "song": "Uprising", "group": "Muse"},
+		{"op": "update", "id": "`+songID+`", "update": {"releaseDate": "2009-13"}},
+		{"op": "delete", "id": "`+songID+`"}
+	]}`)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Len(t, service.operations, 3)
+	require.ErrorAs(t, service.operations[1].Err, new(*domain.ValidationError))
+	require.Nil(t, service.operations[1].Update)
+
+	res = post("/api/v1/songs:batch",
+		`{"operations": [{"op": "update", "update": {"name": "a"}}]}`)
+	require.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = post("/api/v1/songs:batch", `{"operations": [`+
+		strings.Repeat(`{"op": "delete", "id": "`+songID+`"},`, 3)+
+		`{"op": "delete", "id": "`+songID+`"}]}`)
+	require.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+
+	res = post("/api/v1/songs:batch", `{"operations": [{"op": "create", "song": "`+
+		strings.Repeat("a", 1024)+`", "group": "Muse"}]}`)
+	require.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+
+	res = post("/api/v1/songs:unknown", `{}`)
+	require.Equal(t, http.StatusNotFound, res.Code)
+}
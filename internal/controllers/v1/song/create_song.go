@@ -12,6 +12,13 @@ import (
 	"go.opentelemetry.io/otel"
 )
 
+// createSongRequestFields are names of request body
+// fields that differ from song field names
+var createSongRequestFields = map[domain.SongField]string{
+	domain.SongFieldName:  "song",
+	domain.SongFieldGroup: "group",
+}
+
 type createSongRequestBody struct {
 	SongName       string `json:"song" binding:"required"`
 	MusicGroupName string `json:"group" binding:"required"`
@@ -21,11 +28,15 @@ type createSongRequestBody struct {
 //	@Tags		song
 //	@Accept		json
 //	@Produce	json
-//	@Param		song_details	body		createSongRequestBody	yes	"Song details"
-//	@Success	201				{object}	songDTO					"Success"
-//	@Failure	409				{object}	apiutils.HTTPError		"Song already exists"
-//	@Failure	502				{object}	apiutils.HTTPError		"Error from upstream service"
-//	@Failure	500				{object}	apiutils.HTTPError		"Internal server error"
+//	@Param		song_details	body		createSongRequestBody			yes		"Song details"
+//	@Param		Idempotency-Key	header		string							false	"Unique key of the request, repeated requests with the same key get the first response"
+//	@Success	201				{object}	songDTO							"Success"
+//	@Header		201				{string}	ETag							"Song version tag"
+//	@Header		201				{string}	Idempotent-Replayed				"true if response is replayed for repeated request"
+//	@Failure	409				{object}	apiutils.HTTPError				"Song already exists or request with the same Idempotency-Key is being processed"
+//	@Failure	422				{object}	apiutils.ValidationHTTPError	"Invalid song details or Idempotency-Key is used for another request"
+//	@Failure	502				{object}	apiutils.HTTPError				"Error from upstream service"
+//	@Failure	500				{object}	apiutils.HTTPError				"Internal server error"
 //	@Router		/songs [post]
 func (ctr *SongController) createSong(c *gin.Context) {
 	_, span := otel.Tracer("gin-server").Start(c.Request.Context(), "create song")
@@ -41,7 +52,12 @@ func (ctr *SongController) createSong(c *gin.Context) {
 	createSongDTO := domain.CreateSongDTO(reqBody)
 	ctx := utils.PassContextLogger(c, context.Background())
 	song, err := ctr.songService.CreateSong(ctx, &createSongDTO)
+	var validationErr *domain.ValidationError
 	switch {
+	case errors.As(err, &validationErr):
+		ginutils.ValidationError(c, err,
+			newFieldErrors(validationErr, createSongRequestFields))
+		return
 	case errors.Is(err, domain.ErrSongAlreadyExists):
 		ginutils.ConflictError(c, err)
 		return
@@ -53,6 +69,7 @@ func (ctr *SongController) createSong(c *gin.Context) {
 		return
 	}
 
+	setSongETag(c, song)
 	c.JSON(http.StatusCreated, newSongDTOFromEntity(song))
 
 }
@@ -0,0 +1,133 @@
+package songcontroller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"song-lib/internal/domain"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+// cursorPayload is what opaque cursor strings are made of.
+// Sort is kept to reject cursors issued for another sort order
+type cursorPayload struct {
+	Sort     string            `json:"s,omitempty"`
+	Keys     []json.RawMessage `json:"k,omitempty"`
+	Backward bool              `json:"b,omitempty"`
+}
+
+func encodeCursor(cursor *domain.Cursor, sort string) (*string, error) {
+	if cursor == nil {
+		return nil, nil
+	}
+
+	payload := cursorPayload{
+		Sort:     sort,
+		Keys:     make([]json.RawMessage, 0, len(cursor.Keys)),
+		Backward: cursor.Backward,
+	}
+	for _, key := range cursor.Keys {
+		rawKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal cursor key")
+		}
+		payload.Keys = append(payload.Keys, rawKey)
+	}
+
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal cursor")
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(rawPayload)
+
+	return &encoded, nil
+}
+
+func decodeCursorPayload(encoded string) (*cursorPayload, error) {
+	rawPayload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(rawPayload, &payload); err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+
+	return &payload, nil
+}
+
+func decodeSongsCursor(
+	encoded string, songSort []domain.SongSortOption,
+) (*domain.Cursor, error) {
+	payload, err := decodeCursorPayload(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Sort != formatSongSort(songSort) {
+		return nil, fmt.Errorf(
+			"cursor was issued for sort \"%s\"", payload.Sort)
+	}
+	// Keys are values of sort fields followed by song ID
+	if len(payload.Keys) != 0 && len(payload.Keys) != len(songSort)+1 {
+		return nil, errors.New("cursor is malformed")
+	}
+
+	cursor := &domain.Cursor{
+		Keys:     make([]any, 0, len(payload.Keys)),
+		Backward: payload.Backward,
+	}
+	for i, rawKey := range payload.Keys {
+		var key any
+		if i == len(songSort) {
+			key, err = decodeCursorKey[ksuid.KSUID](rawKey)
+		} else {
+			switch songSort[i].Field {
+			case domain.SongSortFieldName, domain.SongSortFieldGroupName:
+				key, err = decodeCursorKey[string](rawKey)
+			case domain.SongSortFieldRelevance:
+				key, err = decodeCursorKey[float64](rawKey)
+			default:
+				key, err = decodeCursorKey[time.Time](rawKey)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		cursor.Keys = append(cursor.Keys, key)
+	}
+
+	return cursor, nil
+}
+
+func decodeCoupletsCursor(encoded string) (*domain.Cursor, error) {
+	payload, err := decodeCursorPayload(encoded)
+	if err != nil {
+		return nil, err
+	}
+	// The only key is couplet number
+	if payload.Sort != "" || len(payload.Keys) > 1 {
+		return nil, errors.New("cursor is malformed")
+	}
+
+	cursor := &domain.Cursor{Backward: payload.Backward}
+	if len(payload.Keys) == 1 {
+		coupletNum, err := decodeCursorKey[int](payload.Keys[0])
+		if err != nil {
+			return nil, err
+		}
+		cursor.Keys = []any{coupletNum}
+	}
+
+	return cursor, nil
+}
+
+func decodeCursorKey[T any](rawKey json.RawMessage) (any, error) {
+	var key T
+	if err := json.Unmarshal(rawKey, &key); err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+	return key, nil
+}
@@ -0,0 +1,38 @@
+package songcontroller
+
+import (
+	"song-lib/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSongsCursorRoundTrip(t *testing.T) {
+	songSort := []domain.SongSortOption{
+		{Field: domain.SongSortFieldReleaseDate, Descending: true},
+		{Field: domain.SongSortFieldName},
+		{Field: domain.SongSortFieldRelevance},
+	}
+	cursor := &domain.Cursor{
+		Keys: []any{
+			time.Date(2006, 7, 16, 0, 0, 0, 0, time.UTC),
+			"XLR8",
+			0.3333333432674408,
+			ksuid.New(),
+		},
+		Backward: true,
+	}
+
+	encoded, err := encodeCursor(cursor, formatSongSort(songSort))
+	require.NoError(t, err)
+	decoded, err := decodeSongsCursor(*encoded, songSort)
+	require.NoError(t, err)
+	require.Equal(t, cursor, decoded)
+
+	_, err = decodeSongsCursor(*encoded, songSort[:2])
+	require.Error(t, err)
+	_, err = decodeSongsCursor("not a cursor", songSort)
+	require.Error(t, err)
+}
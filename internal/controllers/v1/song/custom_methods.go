@@ -0,0 +1,35 @@
+package songcontroller
+
+import (
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// customMethodRoutes map custom methods like ":batch" in /songs:batch
+// to their handlers. Gin can't route literal colon, so all custom
+// methods share a single route with param prefixed by colon
+type customMethodRoutes map[string]gin.HandlersChain
+
+// handlers of the shared route run the handlers
+// of the requested custom method only
+func (r customMethodRoutes) handlers() gin.HandlersChain {
+	chain := gin.HandlersChain{func(c *gin.Context) {
+		if _, ok := r[c.Param("customMethod")]; !ok {
+			ginutils.NotFoundError(c, errors.Errorf(
+				"%s is not found", c.Request.URL.Path))
+			c.Abort()
+		}
+	}}
+	for method, handlers := range r {
+		for _, handler := range handlers {
+			chain = append(chain, func(c *gin.Context) {
+				if c.Param("customMethod") == method {
+					handler(c)
+				}
+			})
+		}
+	}
+	return chain
+}
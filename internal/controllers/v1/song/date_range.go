@@ -0,0 +1,114 @@
+package songcontroller
+
+import (
+	"fmt"
+	"regexp"
+	"song-lib/internal/domain"
+	"strconv"
+	"time"
+)
+
+var (
+	dateRangeRegexp = regexp.MustCompile(
+		`^([\[(])(\d{4}-\d{2}-\d{2})?;(\d{4}-\d{2}-\d{2})?([\])])$`)
+	relativeDateRangeRegexp = regexp.MustCompile(
+		`^last_(\d+)_(days|weeks|months|years)$`)
+)
+
+// parseDateRange parses either range with optional bounds like
+// [2001-03-12;2024-11-21), where parentheses exclude the bound,
+// or relative range ending today like last_30_days
+func parseDateRange(dateRange string, now time.Time) (domain.TimeRange, error) {
+	if matches := relativeDateRangeRegexp.FindStringSubmatch(dateRange); matches != nil {
+		return parseRelativeDateRange(matches[1], matches[2], now)
+	}
+
+	matches := dateRangeRegexp.FindStringSubmatch(dateRange)
+	if matches == nil {
+		return domain.TimeRange{}, fmt.Errorf("date range \"%s\" has invalid format", dateRange)
+	}
+
+	timeRange := domain.TimeRange{
+		StartTimeExclusive: matches[1] == "(",
+		EndTimeExclusive:   matches[4] == ")",
+	}
+	if matches[2] != "" {
+		startDate, err := time.Parse(DateLayout, matches[2])
+		if err != nil {
+			return domain.TimeRange{}, fmt.Errorf("start date \"%s\" has invalid format", matches[2])
+		}
+		timeRange.StartTime = &startDate
+	}
+	if matches[3] != "" {
+		endDate, err := time.Parse(DateLayout, matches[3])
+		if err != nil {
+			return domain.TimeRange{}, fmt.Errorf("end date \"%s\" has invalid format", matches[3])
+		}
+		timeRange.EndTime = &endDate
+	}
+
+	if timeRange.StartTime == nil && timeRange.EndTime == nil {
+		return domain.TimeRange{}, fmt.Errorf("date range \"%s\" has no bounds", dateRange)
+	}
+	if timeRange.StartTime != nil && timeRange.EndTime != nil &&
+		timeRange.StartTime.After(*timeRange.EndTime) {
+		return domain.TimeRange{}, fmt.Errorf("date range start date %s is after end date %s", timeRange.StartTime.Format(DateLayout), timeRange.EndTime.Format(DateLayout))
+	}
+
+	return timeRange, nil
+}
+
+func parseRelativeDateRange(
+	count, unit string, now time.Time,
+) (domain.TimeRange, error) {
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return domain.TimeRange{}, fmt.Errorf("relative date range length \"%s\" is invalid", count)
+	}
+
+	// Both bounds are inclusive, so the range starts the day
+	// after the date n units ago to cover n units of days
+	endDate := truncateToDate(now)
+	var startDate time.Time
+	switch unit {
+	case "days":
+		startDate = endDate.AddDate(0, 0, -n+1)
+	case "weeks":
+		startDate = endDate.AddDate(0, 0, -7*n+1)
+	case "months":
+		startDate = subtractMonths(endDate, n).AddDate(0, 0, 1)
+	case "years":
+		startDate = subtractMonths(endDate, 12*n).AddDate(0, 0, 1)
+	}
+
+	return domain.TimeRange{StartTime: &startDate, EndTime: &endDate}, nil
+}
+
+// parsePartialDate parses year, year-month or full date
+// into range of days covered by it
+func parsePartialDate(date string) (domain.TimeRange, error) {
+	partialDate, err := domain.ReleaseDateLayouts.Parse(date)
+	if err != nil {
+		return domain.TimeRange{}, err
+	}
+
+	lastDay := partialDate.LastDay()
+	return domain.TimeRange{
+		StartTime: &partialDate.Date,
+		EndTime:   &lastDay,
+	}, nil
+}
+
+// subtractMonths clamps the day to the last one of the month,
+// so that a month before March 31 is February 29, not March 2
+func subtractMonths(date time.Time, months int) time.Time {
+	year, month, day := date.Date()
+	firstDay := time.Date(year, month-time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstDay.AddDate(0, 1, -1).Day()
+	return firstDay.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+func truncateToDate(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
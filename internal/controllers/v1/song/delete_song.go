@@ -11,22 +11,32 @@ import (
 	"github.com/segmentio/ksuid"
 )
 
-// @Summary	Delete song
-// @Tags		song
-// @Param		songID	path		string				true	"Song ID"
-// @Success	200		{nil}		nil					"Success"
-// @Failure	404		{object}	apiutils.HTTPError	"Song not found"
-// @Failure	500		{object}	apiutils.HTTPError	"Internal server error"
-// @Router		/songs/{songID} [delete]
+//	@Summary	Delete song
+//	@Tags		song
+//	@Param		songID		path		string				true	"Song ID"
+//	@Param		If-Match	header		string				false	"ETag of song to delete, required if server is configured so"
+//	@Success	200			{nil}		nil					"Success"
+//	@Failure	404			{object}	apiutils.HTTPError	"Song not found"
+//	@Failure	412			{object}	apiutils.HTTPError	"Song version doesn't match If-Match"
+//	@Failure	428			{object}	apiutils.HTTPError	"If-Match is required"
+//	@Failure	500			{object}	apiutils.HTTPError	"Internal server error"
+//	@Router		/songs/{songID} [delete]
 func (ctr *SongController) deleteSong(c *gin.Context) {
 	songID := c.MustGet("songID").(ksuid.KSUID)
+	version, ok := ctr.ifMatchVersion(c, songID)
+	if !ok {
+		return
+	}
 
 	ctx := utils.PassContextLogger(c, context.Background())
-	err := ctr.songService.DeleteSong(ctx, songID)
+	err := ctr.songService.DeleteSong(ctx, songID, version)
 	switch {
 	case errors.Is(err, domain.ErrSongNotFound):
 		ginutils.NotFoundError(c, err)
 		return
+	case errors.Is(err, domain.ErrVersionMismatch):
+		ginutils.PreconditionFailed(c, err)
+		return
 	case err != nil:
 		ginutils.InternalError(c)
 		return
@@ -0,0 +1,169 @@
+package songcontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/domain"
+	"song-lib/internal/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+var errIfMatchRequired = errors.New("If-Match header is required")
+
+// noSongVersion matches no song, as versions start from 1
+const noSongVersion int64 = 0
+
+// songETag is strong entity tag of song representation,
+// it changes with song version
+func songETag(song *domain.Song) string {
+	return fmt.Sprintf(`"%d"`, song.Version)
+}
+
+func setSongETag(c *gin.Context, song *domain.Song) {
+	c.Header("ETag", songETag(song))
+}
+
+// ifMatchVersion returns song version from If-Match header,
+// nil version means any one. It writes error response and returns
+// false if header is malformed, matches no version of the song
+// or is missing but required. Song is read to pick the version
+// of its current tag only if there are several candidates
+func (ctr *SongController) ifMatchVersion(
+	c *gin.Context, songID ksuid.KSUID,
+) (*int64, bool) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	switch {
+	case ifMatch == "" && ctr.options.RequireIfMatch:
+		ginutils.PreconditionRequired(c, errIfMatchRequired)
+		return nil, false
+	case ifMatch == "", ifMatch == "*":
+		return nil, true
+	}
+
+	versions, err := parseIfMatchVersions(ifMatch)
+	if err != nil {
+		ginutils.BadRequest(c, errors.Wrap(err, "parse If-Match header"))
+		return nil, false
+	}
+	switch len(versions) {
+	case 0:
+		ginutils.PreconditionFailed(c, domain.ErrVersionMismatch)
+		return nil, false
+	case 1:
+		return &versions[0], true
+	}
+
+	ctx := utils.PassContextLogger(c, context.Background())
+	song, err := ctr.songService.GetSong(ctx, songID)
+	switch {
+	case errors.Is(err, domain.ErrSongNotFound):
+		ginutils.NotFoundError(c, err)
+		return nil, false
+	case err != nil:
+		ginutils.InternalError(c)
+		return nil, false
+	case !slices.Contains(versions, song.Version):
+		ginutils.PreconditionFailed(c, domain.ErrVersionMismatch)
+		return nil, false
+	}
+	// Song may change since it is read, so the version is still checked
+	return &song.Version, true
+}
+
+// parseIfMatchVersions parses list of entity tags (RFC 9110) and
+// returns versions of song tags in it. Weak and unknown tags are
+// well-formed but skipped, as they never match song tags
+// with strong comparison If-Match uses
+func parseIfMatchVersions(ifMatch string) ([]int64, error) {
+	var (
+		versions []int64
+		tagCount int
+	)
+	rest := ifMatch
+	for {
+		rest = strings.TrimLeft(rest, " \t")
+		if after, ok := strings.CutPrefix(rest, ","); ok {
+			rest = after
+			continue
+		}
+		if rest == "" {
+			break
+		}
+
+		weak := false
+		if after, ok := strings.CutPrefix(rest, "W/"); ok {
+			rest, weak = after, true
+		}
+		opaqueTag, after, err := cutOpaqueTag(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = strings.TrimLeft(after, " \t")
+		if rest != "" && !strings.HasPrefix(rest, ",") {
+			return nil, fmt.Errorf("entity tags should be separated with commas")
+		}
+
+		tagCount++
+		if weak {
+			continue
+		}
+		version, err := strconv.ParseInt(opaqueTag, 10, 64)
+		if err == nil {
+			versions = append(versions, version)
+		}
+	}
+	if tagCount == 0 {
+		return nil, fmt.Errorf("entity tag list is empty")
+	}
+
+	return versions, nil
+}
+
+// cutOpaqueTag cuts quoted opaque tag from the start of s
+func cutOpaqueTag(s string) (opaqueTag, rest string, err error) {
+	quoted, ok := strings.CutPrefix(s, `"`)
+	if !ok {
+		return "", "", fmt.Errorf("entity tag should be quoted")
+	}
+	end := strings.IndexByte(quoted, '"')
+	if end < 0 {
+		return "", "", fmt.Errorf("entity tag is unterminated")
+	}
+
+	opaqueTag = quoted[:end]
+	for i := 0; i < len(opaqueTag); i++ {
+		// etagc is %x21 / %x23-7E / obs-text
+		if b := opaqueTag[i]; b < 0x21 || b == 0x7F {
+			return "", "", fmt.Errorf("entity tag has invalid character")
+		}
+	}
+	return opaqueTag, quoted[end+1:], nil
+}
+
+// notModified writes 304 response if If-None-Match
+// header matches song entity tag using weak comparison
+func notModified(c *gin.Context, song *domain.Song) bool {
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag := songETag(song)
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			setSongETag(c, song)
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
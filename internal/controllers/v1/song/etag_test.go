@@ -0,0 +1,27 @@
+package songcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIfMatchVersions(t *testing.T) {
+	tests := map[string][]int64{
+		`"42"`:               {42},
+		`"42", W/"43", "44"`: {42, 44},
+		`W/"42"`:             nil,
+		`"v42"`:              nil,
+		`"a,b" ,, "7"`:       {7},
+	}
+	for ifMatch, expected := range tests {
+		versions, err := parseIfMatchVersions(ifMatch)
+		require.NoError(t, err, ifMatch)
+		require.Equal(t, expected, versions, ifMatch)
+	}
+
+	for _, ifMatch := range []string{`42`, `"42`, `"42" "43"`, `,`, `W/42`} {
+		_, err := parseIfMatchVersions(ifMatch)
+		require.Error(t, err, ifMatch)
+	}
+}
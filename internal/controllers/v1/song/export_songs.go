@@ -0,0 +1,265 @@
+package songcontroller
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/domain"
+	"song-lib/internal/utils"
+	slogutils "song-lib/internal/utils/slog-utils"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type exportFormat string
+
+const (
+	exportFormatJSONL exportFormat = "jsonl"
+	exportFormatCSV   exportFormat = "csv"
+	exportFormatZIP   exportFormat = "zip"
+)
+
+var exportContentTypes = map[exportFormat]string{
+	exportFormatJSONL: "application/jsonl",
+	exportFormatCSV:   "text/csv; charset=utf-8",
+	exportFormatZIP:   "application/zip",
+}
+
+var exportCSVHeader = []string{
+	"id", "song", "group", "releaseDate",
+	"link", "text", "createdAt", "updatedAt",
+}
+
+type exportSongsQuery struct {
+	songFiltersQuery
+	Format string `form:"format,default=jsonl" binding:"oneof=jsonl csv zip"`
+}
+
+// songExporter writes songs one by one, close
+// finishes the output but doesn't close the writer
+type songExporter interface {
+	writeSong(song *domain.Song) error
+	close() error
+}
+
+//	@Summary		Export songs
+//	@Description	Stream all filtered songs ordered by ID as JSON Lines of songs, CSV or ZIP of plain text lyrics in group/song.txt files. JSON Lines and CSV are gzip encoded if client accepts it
+//	@Tags			song
+//	@Produce		application/jsonl,text/csv,application/zip
+//	@Param			format				query		string				false	"Export format"	Enums(jsonl, csv, zip)	default(jsonl)
+//	@Param			song				query		string				false	"Filter for name, see match"
+//	@Param			group				query		string				false	"Filter for music group name, see match"
+//	@Param			match				query		string				false	"Matching mode for song and group filters"	Enums(exact, prefix, fuzzy)	default(exact)
+//	@Param			link				query		string				false	"Equality filter for link"
+//	@Param			text_contains		query		string				false	"'in' filter for text"
+//	@Param			release_date_range	query		string				false	"'in range' filter for release date, see GET /songs"
+//	@Param			release_year		query		int					false	"Filter for release year"
+//	@Param			released			query		string				false	"Filter for release year, month or day e.g., 2001-05"
+//	@Param			filter				query		string				false	"Filter expression, see GET /songs"
+//	@Param			Accept-Encoding		header		string				false	"gzip to compress JSON Lines and CSV"
+//	@Success		200					{file}		file				"Songs"
+//	@Failure		400					{object}	apiutils.HTTPError	"Invalid query params"
+//	@Failure		500					{object}	apiutils.HTTPError	"Internal server error"
+//	@Router			/export [get]
+func (ctr *SongController) exportSongs(c *gin.Context) {
+	var reqQuery exportSongsQuery
+	if err := c.ShouldBindQuery(&reqQuery); err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+	if err := reqQuery.validate(); err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+	songFilters, err := reqQuery.toSongFilters()
+	if err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+
+	format := exportFormat(reqQuery.Format)
+	c.Header("Content-Type", exportContentTypes[format])
+	c.Header("Content-Disposition", `attachment; filename="songs.`+string(format)+`"`)
+	var (
+		out        io.Writer = c.Writer
+		gzipWriter *gzip.Writer
+	)
+	// ZIP is compressed already
+	if format != exportFormatZIP {
+		c.Header("Vary", "Accept-Encoding")
+		if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Header("Content-Encoding", "gzip")
+			gzipWriter = gzip.NewWriter(c.Writer)
+			out = gzipWriter
+		}
+	}
+	exporter := newSongExporter(format, out)
+
+	ctx := utils.PassContextLogger(c, context.Background())
+	err = ctr.songService.ExportSongs(ctx, songFilters, exporter.writeSong)
+	if err == nil {
+		err = exporter.close()
+	}
+	if err == nil && gzipWriter != nil {
+		err = gzipWriter.Close()
+	}
+	switch {
+	case err != nil && !c.Writer.Written():
+		// Error is sent as JSON instead of the export
+		for _, header := range []string{
+			"Content-Type", "Content-Disposition", "Content-Encoding", "Vary",
+		} {
+			c.Header(header, "")
+		}
+		ginutils.InternalError(c)
+	case err != nil:
+		// Response is partially sent, so it's left
+		// incomplete for client to notice the error
+		slogutils.Error(ctx, "export songs:", err)
+		c.Abort()
+	}
+}
+
+func newSongExporter(format exportFormat, w io.Writer) songExporter {
+	switch format {
+	case exportFormatCSV:
+		return newCSVSongExporter(w)
+	case exportFormatZIP:
+		return &zipSongExporter{writer: zip.NewWriter(w)}
+	default:
+		return &jsonlSongExporter{encoder: json.NewEncoder(w)}
+	}
+}
+
+// acceptsGzip tells if Accept-Encoding header
+// lists gzip with non zero quality
+func acceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		quality, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		q, err := strconv.ParseFloat(quality, 64)
+		return err == nil && q > 0
+	}
+	return false
+}
+
+type jsonlSongExporter struct {
+	encoder *json.Encoder
+}
+
+func (e *jsonlSongExporter) writeSong(song *domain.Song) error {
+	return e.encoder.Encode(newSongDTOFromEntity(song))
+}
+
+func (e *jsonlSongExporter) close() error {
+	return nil
+}
+
+type csvSongExporter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func newCSVSongExporter(w io.Writer) *csvSongExporter {
+	return &csvSongExporter{writer: csv.NewWriter(w)}
+}
+
+func (e *csvSongExporter) writeSong(song *domain.Song) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.writer.Write([]string{
+		song.ID.String(),
+		song.Name,
+		song.MusicGroup.Name,
+		domain.ReleaseDateLayouts.Format(song.ReleaseDate),
+		song.Link,
+		strings.Join(song.Couplets, "\n\n"),
+		song.CreatedAt.Format(time.RFC3339),
+		song.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+// writeHeader is deferred till the first song, so that
+// response is not started if songs can't be read
+func (e *csvSongExporter) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	return e.writer.Write(exportCSVHeader)
+}
+
+func (e *csvSongExporter) close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type zipSongExporter struct {
+	writer *zip.Writer
+}
+
+func (e *zipSongExporter) writeSong(song *domain.Song) error {
+	file, err := e.writer.CreateHeader(&zip.FileHeader{
+		Name:     lyricsFilePath(song),
+		Method:   zip.Deflate,
+		Modified: song.UpdatedAt,
+	})
+	if err != nil {
+		return errors.Wrap(err, "create file")
+	}
+
+	_, err = io.WriteString(file, strings.Join(song.Couplets, "\n\n")+"\n")
+	return err
+}
+
+func (e *zipSongExporter) close() error {
+	return e.writer.Close()
+}
+
+// lyricsFilePath is group/song.txt. Names are unique within
+// a group, song ID is appended if names have to be changed
+// to be valid file names, so that paths stay unique
+func lyricsFilePath(song *domain.Song) string {
+	group, groupChanged := fileName(song.MusicGroup.Name)
+	name, nameChanged := fileName(song.Name)
+	if groupChanged || nameChanged {
+		name += " " + song.ID.String()
+	}
+	return group + "/" + name + ".txt"
+}
+
+// fileName replaces path separators and control characters,
+// returns true if name is changed
+func fileName(name string) (string, bool) {
+	changed := false
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			changed = true
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "." || name == ".." {
+		return "_", true
+	}
+	return name, changed
+}
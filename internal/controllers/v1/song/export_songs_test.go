@@ -0,0 +1,113 @@
+package songcontroller
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"song-lib/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+)
+
+type exportSongService struct {
+	SongService
+	songs []domain.Song
+	err   error
+}
+
+func (s *exportSongService) ExportSongs(
+	_ context.Context, _ *domain.SongFilters,
+	export func(song *domain.Song) error,
+) error {
+	if s.err != nil {
+		return s.err
+	}
+	for i := range s.songs {
+		if err := export(&s.songs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportSongs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	releaseDate := domain.PartialDate{
+		Date:      time.Date(2009, 9, 1, 0, 0, 0, 0, time.UTC),
+		Precision: domain.DatePrecisionMonth,
+	}
+	service := &exportSongService{songs: []domain.Song{
+		{
+			ID: ksuid.New(), Name: "Uprising", MusicGroup: domain.MusicGroup{Name: "Muse"},
+			ReleaseDate: releaseDate, Couplets: []string{"first", "second"},
+		},
+		{
+			ID: ksuid.New(), Name: "AC/DC", MusicGroup: domain.MusicGroup{Name: "Muse"},
+			ReleaseDate: releaseDate, Couplets: []string{"only"},
+		},
+	}}
+	engine := gin.New()
+	NewSongController(service, Options{}).RegisterRoutes(engine)
+
+	get := func(query, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export"+query, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		res := httptest.NewRecorder()
+		engine.ServeHTTP(res, req)
+		return res
+	}
+
+	res := get("", "br, gzip;q=0.5")
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+	gzipReader, err := gzip.NewReader(res.Body)
+	require.NoError(t, err)
+	decoder := json.NewDecoder(gzipReader)
+	var dto songDTO
+	require.NoError(t, decoder.Decode(&dto))
+	require.Equal(t, "Uprising", *dto.Name)
+	require.Equal(t, "2009-09", *dto.ReleaseDate)
+	require.NoError(t, decoder.Decode(&dto))
+	require.Equal(t, io.EOF, decoder.Decode(&dto))
+
+	res = get("?format=csv", "gzip;q=0")
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Empty(t, res.Header().Get("Content-Encoding"))
+	records, err := csv.NewReader(res.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, exportCSVHeader, records[0])
+	require.Equal(t, "first\n\nsecond", records[1][5])
+
+	res = get("?format=zip", "gzip")
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Empty(t, res.Header().Get("Content-Encoding"))
+	zipReader, err := zip.NewReader(bytes.NewReader(res.Body.Bytes()), int64(res.Body.Len()))
+	require.NoError(t, err)
+	require.Len(t, zipReader.File, 2)
+	require.Equal(t, "Muse/Uprising.txt", zipReader.File[0].Name)
+	require.Equal(t, "Muse/AC_DC "+service.songs[1].ID.String()+".txt", zipReader.File[1].Name)
+
+	res = get("?format=xml", "")
+	require.Equal(t, http.StatusBadRequest, res.Code)
+
+	// Export failed before any song is written gets JSON error
+	service.err = errors.New("connection refused")
+	res = get("?format=csv", "gzip")
+	require.Equal(t, http.StatusInternalServerError, res.Code)
+	require.Contains(t, res.Header().Get("Content-Type"), "application/json")
+	require.Empty(t, res.Header().Get("Content-Disposition"))
+	require.Empty(t, res.Header().Get("Content-Encoding"))
+	require.Empty(t, res.Header().Get("Vary"))
+}
@@ -0,0 +1,45 @@
+package songcontroller
+
+import (
+	"context"
+	"net/http"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/domain"
+	"song-lib/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+//	@Summary	Get song
+//	@Tags		song
+//	@Produce	json
+//	@Param		songID			path		string				true	"Song ID"
+//	@Param		If-None-Match	header		string				false	"ETag of cached song"
+//	@Success	200				{object}	songDTO				"Success"
+//	@Header		200				{string}	ETag				"Song version tag"
+//	@Success	304				{nil}		nil					"Song has not changed"
+//	@Failure	404				{object}	apiutils.HTTPError	"Song not found"
+//	@Failure	500				{object}	apiutils.HTTPError	"Internal server error"
+//	@Router		/songs/{songID} [get]
+func (ctr *SongController) getSong(c *gin.Context) {
+	songID := c.MustGet("songID").(ksuid.KSUID)
+
+	ctx := utils.PassContextLogger(c, context.Background())
+	song, err := ctr.songService.GetSong(ctx, songID)
+	switch {
+	case errors.Is(err, domain.ErrSongNotFound):
+		ginutils.NotFoundError(c, err)
+		return
+	case err != nil:
+		ginutils.InternalError(c)
+		return
+	}
+
+	if notModified(c, song) {
+		return
+	}
+	setSongETag(c, song)
+	c.JSON(http.StatusOK, newSongDTOFromEntity(song))
+}
@@ -8,18 +8,25 @@ import (
 	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
 	"song-lib/internal/domain"
 	"song-lib/internal/utils"
+	slogutils "song-lib/internal/utils/slog-utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/segmentio/ksuid"
 )
 
 type getSongCoupletsRequestQuery struct {
-	Page    int `form:"couplets_page" binding:"required"`
-	PerPage int `form:"couplets_per_page" binding:"required"`
+	Page    *int    `form:"couplets_page"`
+	PerPage *int    `form:"couplets_per_page"`
+	Cursor  *string `form:"cursor"`
+	Limit   *int    `form:"limit"`
+	Count   *string `form:"count"`
 }
 
 type getSongCoupletsResponseBody struct {
+	paginationDTO
 	SongCouplets []string `json:"songCouplets"`
+	NextCursor   *string  `json:"nextCursor,omitempty"`
+	PrevCursor   *string  `json:"prevCursor,omitempty"`
 }
 
 //	@Summary		Get song text
@@ -27,9 +34,14 @@ type getSongCoupletsResponseBody struct {
 //	@Tags			song
 //	@Produce		json
 //	@Param			songID				path		string						true	"Song ID"
-//	@Param			couplets_page		query		int							true	"Number of page with couplets to return"
-//	@Param			couplets_per_page	query		int							true	"Number of couplets per page to return"
+//	@Param			couplets_page		query		int							false	"Number of page with couplets to return, required unless limit is set"
+//	@Param			couplets_per_page	query		int							false	"Number of couplets per page to return, required unless limit is set"
+//	@Param			limit				query		int							false	"Number of couplets per page for cursor pagination"
+//	@Param			cursor				query		string						false	"nextCursor or prevCursor from previous response, requires limit"
+//	@Param			count				query		string						false	"Whether to count total. Defaults to none if cursor is set"	Enums(exact, none)	default(exact)
 //	@Success		200					{object}	getSongCoupletsResponseBody	"Success"
+//	@Header			200					{string}	Link						"RFC 8288 links to first, prev, next and last pages"
+//	@Failure		400					{object}	apiutils.HTTPError			"Invalid query params"
 //	@Failure		404					{object}	apiutils.HTTPError			"Song not found"
 //	@Failure		500					{object}	apiutils.HTTPError			"Internal server error"
 //	@Router			/songs/{songID}/couplets [get]
@@ -46,13 +58,17 @@ func (ctr *SongController) getSongCouplets(c *gin.Context) {
 		return
 	}
 
+	pagination, err := reqQuery.toPagination()
+	if err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+
 	ctx := utils.PassContextLogger(c, context.Background())
-	songCouplets, err := ctr.songService.GetSongCoupletsPaginated(
+	coupletsPage, err := ctr.songService.GetSongCoupletsPaginated(
 		ctx,
 		songID,
-		domain.Pagination{
-			Page:    reqQuery.Page - 1,
-			PerPage: reqQuery.PerPage})
+		pagination)
 	switch {
 	case errors.Is(err, domain.ErrSongNotFound):
 		ginutils.NotFoundError(c, err)
@@ -62,21 +78,79 @@ func (ctr *SongController) getSongCouplets(c *gin.Context) {
 		return
 	}
 
-	if songCouplets == nil {
-		songCouplets = make([]string, 0)
+	respBody, err := newGetSongCoupletsResponseBody(coupletsPage, pagination)
+	if err != nil {
+		slogutils.Error(ctx, "get song couplets:", err)
+		ginutils.InternalError(c)
+		return
 	}
-	c.JSON(http.StatusOK, getSongCoupletsResponseBody{
-		SongCouplets: songCouplets,
-	})
+	err = setPaginationLinks(c, "couplets_page", respBody.paginationDTO,
+		len(respBody.SongCouplets), respBody.NextCursor, respBody.PrevCursor, "")
+	if err != nil {
+		slogutils.Error(ctx, "get song couplets:", err)
+		ginutils.InternalError(c)
+		return
+	}
+	c.JSON(http.StatusOK, respBody)
 }
 
 func (q *getSongCoupletsRequestQuery) validate() error {
-	if q.Page < 1 {
-		return fmt.Errorf("page value is less than 1")
+	err := validatePaginationParams(q.Page, q.PerPage, q.Cursor, q.Limit)
+	if err != nil {
+		return err
+	}
+
+	// Couplets are always counted exactly
+	return validateCountMode(q.Count, domain.CountModeExact, domain.CountModeNone)
+}
+
+func (q *getSongCoupletsRequestQuery) toPagination() (domain.Pagination, error) {
+	countMode := countModeParam(q.Count, q.Cursor)
+	if q.Limit == nil {
+		return domain.Pagination{
+			Page:    *q.Page - 1,
+			PerPage: *q.PerPage,
+			Count:   countMode,
+		}, nil
+	}
+
+	cursor := &domain.Cursor{}
+	if q.Cursor != nil {
+		var err error
+		cursor, err = decodeCoupletsCursor(*q.Cursor)
+		if err != nil {
+			return domain.Pagination{}, fmt.Errorf("parse cursor: %w", err)
+		}
+	}
+
+	return domain.Pagination{
+		PerPage: *q.Limit,
+		Cursor:  cursor,
+		Count:   countMode,
+	}, nil
+}
+
+func newGetSongCoupletsResponseBody(
+	coupletsPage *domain.CoupletsPage, pagination domain.Pagination,
+) (*getSongCoupletsResponseBody, error) {
+	respBody := getSongCoupletsResponseBody{
+		paginationDTO: newPaginationDTO(
+			pagination, coupletsPage.Total, false),
+		SongCouplets: coupletsPage.Couplets,
 	}
-	if q.PerPage < 0 {
-		return fmt.Errorf("per page value is less than 0")
+	if respBody.SongCouplets == nil {
+		respBody.SongCouplets = make([]string, 0)
+	}
+
+	var err error
+	respBody.NextCursor, err = encodeCursor(coupletsPage.NextCursor, "")
+	if err != nil {
+		return nil, fmt.Errorf("encode next cursor: %w", err)
+	}
+	respBody.PrevCursor, err = encodeCursor(coupletsPage.PrevCursor, "")
+	if err != nil {
+		return nil, fmt.Errorf("encode prev cursor: %w", err)
 	}
 
-	return nil
+	return &respBody, nil
 }
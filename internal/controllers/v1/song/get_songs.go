@@ -2,37 +2,96 @@ package songcontroller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
 	"song-lib/internal/domain"
+	"song-lib/internal/filterexpr"
 	"song-lib/internal/utils"
+	slogutils "song-lib/internal/utils/slog-utils"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 )
 
 type getSongsRequestQuery struct {
-	Page                 *int    `form:"page" binding:"required"`
-	PerPage              *int    `form:"per_page" binding:"required"`
+	songFiltersQuery
+	Page    *int    `form:"page"`
+	PerPage *int    `form:"per_page"`
+	Cursor  *string `form:"cursor"`
+	Limit   *int    `form:"limit"`
+	Sort    *string `form:"sort"`
+	Count   *string `form:"count"`
+	Fields  *string `form:"fields"`
+	Include *string `form:"include"`
+}
+
+// songFiltersQuery is shared by endpoints filtering songs
+type songFiltersQuery struct {
 	SongName             *string `form:"song"`
 	MusicGroupName       *string `form:"group"`
+	NameMatchMode        *string `form:"match"`
 	SongLink             *string `form:"link"`
 	SongTextContains     *string `form:"text_contains"`
 	SongReleaseDateRange *string `form:"release_date_range"`
+	SongReleaseYear      *int    `form:"release_year"`
+	SongReleased         *string `form:"released"`
+	Filter               *string `form:"filter"`
 }
 
 type getSongsResponseBody struct {
-	Songs []songDTO `json:"songs"`
+	paginationDTO
+	Songs      []songDTO                `json:"songs"`
+	NextCursor *string                  `json:"nextCursor,omitempty"`
+	PrevCursor *string                  `json:"prevCursor,omitempty"`
+	DidYouMean *songSearchSuggestionDTO `json:"didYouMean,omitempty"`
+}
+
+type songSearchSuggestionDTO struct {
+	SongName       *string `json:"song,omitempty"`
+	MusicGroupName *string `json:"group,omitempty"`
 }
 
+// songDTO fields other than ID and version are
+// omitted if not requested in song lists
 type songDTO struct {
-	ID          string        `json:"id"`
-	Name        string        `json:"name"`
-	ReleaseDate string        `json:"releaseDate"`
-	Couplets    []string      `json:"couplets"`
-	Link        string        `json:"link"`
-	MusicGroup  musicGroupDTO `json:"group"`
+	ID      string  `json:"id"`
+	Version int64   `json:"version"`
+	Name    *string `json:"name,omitempty"`
+	// ReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD
+	// depending on how precisely it is known
+	ReleaseDate *string   `json:"releaseDate,omitempty" example:"2001-05"`
+	Couplets    *[]string `json:"couplets,omitempty"`
+	// Link is null if song has none
+	Link       *nullableString `json:"link,omitempty" swaggertype:"string"`
+	MusicGroup *musicGroupDTO  `json:"group,omitempty"`
+	CreatedAt  *time.Time      `json:"createdAt,omitempty"`
+	UpdatedAt  *time.Time      `json:"updatedAt,omitempty"`
+}
+
+// nullableString is marshaled as null if it is empty
+type nullableString string
+
+func (s nullableString) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(string(s))
+}
+
+// songAttributeFields can be selected with fields query param,
+// couplets are requested with include query param instead
+var songAttributeFields = []domain.SongField{
+	domain.SongFieldName,
+	domain.SongFieldGroup,
+	domain.SongFieldReleaseDate,
+	domain.SongFieldLink,
+	domain.SongFieldCreatedAt,
+	domain.SongFieldUpdatedAt,
 }
 
 type musicGroupDTO struct {
@@ -43,14 +102,26 @@ type musicGroupDTO struct {
 //	@Summary	Get songs
 //	@Tags		song
 //	@Produce	json
-//	@Param		page				query		int						true	"Number of page to return"
-//	@Param		per_page			query		int						true	"Number of items per returned page"
-//	@Param		song				query		string					false	"Equality filter for name"
-//	@Param		group				query		string					false	"Equality filter for music group name"
+//	@Param		page				query		int						false	"Number of page to return, required unless limit is set"
+//	@Param		per_page			query		int						false	"Number of items per returned page, required unless limit is set"
+//	@Param		limit				query		int						false	"Number of items per returned page for cursor pagination"
+//	@Param		cursor				query		string					false	"nextCursor or prevCursor from previous response, requires limit and the same sort"
+//	@Param		song				query		string					false	"Filter for name, see match"
+//	@Param		group				query		string					false	"Filter for music group name, see match"
+//	@Param		match				query		string					false	"Matching mode for song and group filters"	Enums(exact, prefix, fuzzy)	default(exact)
 //	@Param		link				query		string					false	"Equality filter for link"
 //	@Param		text_contains		query		string					false	"'in' filter for text"
-//	@Param		release_date_range	query		string					false	"'in range' filter for release data e.g., [12-03-2001;21-11-2024]"
+//	@Param		release_date_range	query		string					false	"'in range' filter for release date e.g., [2001-03-12;2024-11-21]. Parentheses exclude bounds, bounds can be omitted e.g., (2010-01-01;]. Relative ranges ending today: last_N_days, last_N_weeks, last_N_months, last_N_years"
+//	@Param		filter				query		string					false	"Filter expression combined with other filters, e.g., group:\"Muse\" AND (releaseDate>=2010-01-01 OR song~love) AND NOT text~\"war\". Fields: song, group, link, text, releaseDate, createdAt, updatedAt. Operators: ':' equals, '~' contains, '>', '>=', '<', '<=' for dates"
+//	@Param		release_year		query		int						false	"Filter for release year"
+//	@Param		released			query		string					false	"Filter for release year, month or day e.g., 2001-05"
+//	@Param		count				query		string					false	"How to count total, estimated count is faster for large result sets. Defaults to none if cursor is set"	Enums(exact, estimated, none)	default(exact)
+//	@Param		sort				query		string					false	"Comma separated sort keys, '-' prefix for descending order: name, group, releaseDate, createdAt, updatedAt, relevance e.g., -releaseDate,name"
+//	@Param		fields				query		string					false	"Comma separated song fields to return besides id: name, group, releaseDate, link, createdAt, updatedAt. All of them by default"
+//	@Param		include				query		string					false	"Comma separated relations to return: couplets. Couplets are returned by default only if neither fields nor include is set"
 //	@Success	200					{object}	getSongsResponseBody	"Success"
+//	@Header		200					{string}	Link					"RFC 8288 links to first, prev, next and last pages"
+//	@Failure	400					{object}	apiutils.HTTPError		"Invalid query params"
 //	@Failure	500					{object}	apiutils.HTTPError		"Internal server error"
 //	@Router		/songs [get]
 func (ctr *SongController) getSongs(c *gin.Context) {
@@ -69,79 +140,303 @@ func (ctr *SongController) getSongs(c *gin.Context) {
 		ginutils.BindQueryError(c, err)
 		return
 	}
+	songSort, err := reqQuery.toSongSort()
+	if err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+	pagination, err := reqQuery.toPagination(songSort)
+	if err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+	songFields, err := reqQuery.toSongFields()
+	if err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
 	ctx := utils.PassContextLogger(c, context.Background())
-	songs, err := ctr.songService.GetSongsFilteredPaginated(
+	songsPage, err := ctr.songService.GetSongsFilteredPaginated(
 		ctx,
 		songFilters,
-		domain.Pagination{
-			Page:    *reqQuery.Page - 1,
-			PerPage: *reqQuery.PerPage})
+		songSort,
+		pagination,
+		songFields)
 	if err != nil {
 		ginutils.InternalError(c)
 		return
 	}
 
-	songDTOs := make([]songDTO, 0, len(songs))
-	for _, song := range songs {
-		songDTOs = append(songDTOs, songDTO{
-			ID:          song.ID.String(),
-			Name:        song.Name,
-			ReleaseDate: song.ReleaseDate.Format(DateLayout),
-			Couplets:    song.Couplets,
-			Link:        song.Link,
-			MusicGroup: musicGroupDTO{
-				ID:   song.MusicGroup.ID.String(),
-				Name: song.MusicGroup.Name,
-			},
-		})
+	respBody, err := newGetSongsResponseBody(
+		songsPage, songSort, pagination, songFields)
+	if err != nil {
+		slogutils.Error(ctx, "get songs:", err)
+		ginutils.InternalError(c)
+		return
+	}
+	err = setPaginationLinks(c, "page", respBody.paginationDTO,
+		len(respBody.Songs), respBody.NextCursor, respBody.PrevCursor,
+		formatSongSort(songSort))
+	if err != nil {
+		slogutils.Error(ctx, "get songs:", err)
+		ginutils.InternalError(c)
+		return
 	}
-	c.JSON(http.StatusOK, &getSongsResponseBody{
-		Songs: songDTOs,
-	})
+	c.JSON(http.StatusOK, respBody)
 }
 
 func (q *getSongsRequestQuery) validate() error {
-	if *q.Page < 1 {
-		return fmt.Errorf("page value is less than 1")
+	err := validatePaginationParams(q.Page, q.PerPage, q.Cursor, q.Limit)
+	if err != nil {
+		return err
+	}
+	err = validateCountMode(q.Count, domain.CountModeExact,
+		domain.CountModeEstimated, domain.CountModeNone)
+	if err != nil {
+		return err
+	}
+
+	return q.songFiltersQuery.validate()
+}
+
+func (q *songFiltersQuery) validate() error {
+	releaseDateParams := 0
+	for _, given := range []bool{
+		q.SongReleaseDateRange != nil,
+		q.SongReleaseYear != nil,
+		q.SongReleased != nil,
+	} {
+		if given {
+			releaseDateParams++
+		}
+	}
+	if releaseDateParams > 1 {
+		return fmt.Errorf("only one of release_date_range, release_year and released can be used")
 	}
-	if *q.PerPage < 0 {
-		return fmt.Errorf("per page value is less than 0")
+	if q.SongReleaseYear != nil &&
+		(*q.SongReleaseYear < 1 || *q.SongReleaseYear > 9999) {
+		return fmt.Errorf("release year value is out of range")
+	}
+	if q.NameMatchMode != nil {
+		switch domain.MatchMode(*q.NameMatchMode) {
+		case domain.MatchModeExact, domain.MatchModePrefix, domain.MatchModeFuzzy:
+		default:
+			return fmt.Errorf("match value \"%s\" is unknown", *q.NameMatchMode)
+		}
 	}
 
 	return nil
 }
 
-func (q *getSongsRequestQuery) toSongFilters() (*domain.SongFilters, error) {
+func (q *songFiltersQuery) toSongFilters() (*domain.SongFilters, error) {
 	var (
 		releaseDateRange *domain.TimeRange
 		err              error
 	)
-	if q.SongReleaseDateRange != nil {
+	switch {
+	case q.SongReleaseDateRange != nil:
 		releaseDateRange = new(domain.TimeRange)
-		*releaseDateRange, err = parseDateRange(*q.SongReleaseDateRange)
+		*releaseDateRange, err = parseDateRange(
+			*q.SongReleaseDateRange, time.Now())
 		if err != nil {
 			return nil, errors.Wrap(err, "parse release date range")
 		}
+	case q.SongReleaseYear != nil:
+		releaseDateRange = new(domain.TimeRange)
+		*releaseDateRange, err = parsePartialDate(
+			fmt.Sprintf("%04d", *q.SongReleaseYear))
+		if err != nil {
+			return nil, errors.Wrap(err, "parse release year")
+		}
+	case q.SongReleased != nil:
+		releaseDateRange = new(domain.TimeRange)
+		*releaseDateRange, err = parsePartialDate(*q.SongReleased)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse released")
+		}
+	}
+
+	var expression filterexpr.Expr
+	if q.Filter != nil {
+		expression, err = filterexpr.Parse(*q.Filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse filter")
+		}
+	}
+
+	nameMatchMode := domain.MatchModeExact
+	if q.NameMatchMode != nil {
+		nameMatchMode = domain.MatchMode(*q.NameMatchMode)
 	}
 
 	return &domain.SongFilters{
 		SongName:             q.SongName,
 		MusicGroupName:       q.MusicGroupName,
+		NameMatchMode:        nameMatchMode,
 		SongLink:             q.SongLink,
 		SongCoupletContains:  q.SongTextContains,
 		SongReleaseDateRange: releaseDateRange,
+		Expression:           expression,
 	}, nil
 }
 
+func (q *getSongsRequestQuery) toSongSort() ([]domain.SongSortOption, error) {
+	if q.Sort == nil {
+		return nil, nil
+	}
+
+	songSort, err := parseSongSort(*q.Sort)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse sort")
+	}
+	for _, sortOption := range songSort {
+		if sortOption.Field == domain.SongSortFieldRelevance &&
+			q.SongName == nil && q.MusicGroupName == nil {
+			return nil, errors.New(
+				"sort by relevance requires song or group filter")
+		}
+	}
+
+	return songSort, nil
+}
+
+func (q *getSongsRequestQuery) toPagination(
+	songSort []domain.SongSortOption,
+) (domain.Pagination, error) {
+	countMode := countModeParam(q.Count, q.Cursor)
+	if q.Limit == nil {
+		return domain.Pagination{
+			Page:    *q.Page - 1,
+			PerPage: *q.PerPage,
+			Count:   countMode,
+		}, nil
+	}
+
+	cursor := &domain.Cursor{}
+	if q.Cursor != nil {
+		var err error
+		cursor, err = decodeSongsCursor(*q.Cursor, songSort)
+		if err != nil {
+			return domain.Pagination{}, errors.Wrap(err, "parse cursor")
+		}
+	}
+
+	return domain.Pagination{
+		PerPage: *q.Limit,
+		Cursor:  cursor,
+		Count:   countMode,
+	}, nil
+}
+
+func (q *getSongsRequestQuery) toSongFields() (domain.SongFieldSet, error) {
+	if q.Fields == nil && q.Include == nil {
+		return nil, nil
+	}
+
+	songFields := make(domain.SongFieldSet)
+	if q.Fields != nil {
+		for _, field := range strings.Split(*q.Fields, ",") {
+			field = strings.TrimSpace(field)
+			if field == "id" {
+				continue
+			}
+			if !slices.Contains(songAttributeFields, domain.SongField(field)) {
+				return nil, fmt.Errorf("field \"%s\" is unknown", field)
+			}
+			songFields[domain.SongField(field)] = true
+		}
+	} else {
+		for _, field := range songAttributeFields {
+			songFields[field] = true
+		}
+	}
+
+	if q.Include != nil {
+		for _, relation := range strings.Split(*q.Include, ",") {
+			relation = strings.TrimSpace(relation)
+			if domain.SongField(relation) != domain.SongFieldCouplets {
+				return nil, fmt.Errorf("relation \"%s\" is unknown", relation)
+			}
+			songFields[domain.SongFieldCouplets] = true
+		}
+	}
+
+	return songFields, nil
+}
+
+func newGetSongsResponseBody(
+	songsPage *domain.SongsPage, songSort []domain.SongSortOption,
+	pagination domain.Pagination, songFields domain.SongFieldSet,
+) (*getSongsResponseBody, error) {
+	respBody := getSongsResponseBody{
+		paginationDTO: newPaginationDTO(
+			pagination, songsPage.Total, songsPage.TotalEstimated),
+		Songs:      make([]songDTO, 0, len(songsPage.Songs)),
+		DidYouMean: newSongSearchSuggestionDTO(songsPage.Suggestion),
+	}
+	for _, song := range songsPage.Songs {
+		respBody.Songs = append(respBody.Songs, *newSongDTO(&song, songFields))
+	}
+
+	var err error
+	sort := formatSongSort(songSort)
+	respBody.NextCursor, err = encodeCursor(songsPage.NextCursor, sort)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode next cursor")
+	}
+	respBody.PrevCursor, err = encodeCursor(songsPage.PrevCursor, sort)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode prev cursor")
+	}
+
+	return &respBody, nil
+}
+
 func newSongDTOFromEntity(song *domain.Song) *songDTO {
-	return &songDTO{
-		ID:          song.ID.String(),
-		Name:        song.Name,
-		ReleaseDate: song.ReleaseDate.Format(DateLayout),
-		Couplets:    song.Couplets,
-		Link:        song.Link,
-		MusicGroup: musicGroupDTO{
+	return newSongDTO(song, nil)
+}
+
+func newSongDTO(song *domain.Song, fields domain.SongFieldSet) *songDTO {
+	dto := songDTO{ID: song.ID.String(), Version: song.Version}
+	if fields.Has(domain.SongFieldName) {
+		dto.Name = &song.Name
+	}
+	if fields.Has(domain.SongFieldReleaseDate) {
+		releaseDate := domain.ReleaseDateLayouts.Format(song.ReleaseDate)
+		dto.ReleaseDate = &releaseDate
+	}
+	if fields.Has(domain.SongFieldCouplets) {
+		dto.Couplets = &song.Couplets
+	}
+	if fields.Has(domain.SongFieldLink) {
+		link := nullableString(song.Link)
+		dto.Link = &link
+	}
+	if fields.Has(domain.SongFieldGroup) {
+		dto.MusicGroup = &musicGroupDTO{
 			ID:   song.MusicGroup.ID.String(),
 			Name: song.MusicGroup.Name,
-		}}
+		}
+	}
+	if fields.Has(domain.SongFieldCreatedAt) {
+		dto.CreatedAt = &song.CreatedAt
+	}
+	if fields.Has(domain.SongFieldUpdatedAt) {
+		dto.UpdatedAt = &song.UpdatedAt
+	}
+
+	return &dto
+}
+
+func newSongSearchSuggestionDTO(
+	suggestion *domain.SongSearchSuggestion,
+) *songSearchSuggestionDTO {
+	if suggestion == nil {
+		return nil
+	}
+
+	return &songSearchSuggestionDTO{
+		SongName:       suggestion.SongName,
+		MusicGroupName: suggestion.MusicGroupName,
+	}
 }
@@ -0,0 +1,140 @@
+package songcontroller
+
+import (
+	"context"
+	"net/http"
+	apiutils "song-lib/internal/controllers/api-utils"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/domain"
+	"song-lib/internal/songimport"
+	"song-lib/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var importFormats = map[string]songimport.Format{
+	"text/csv":             songimport.FormatCSV,
+	"application/jsonl":    songimport.FormatJSONL,
+	"application/x-ndjson": songimport.FormatJSONL,
+}
+
+var errUnsupportedImportType = errors.New(
+	"content type should be text/csv, application/jsonl or application/x-ndjson")
+
+type importSongsQuery struct {
+	DryRun      bool   `form:"dry_run"`
+	OnDuplicate string `form:"on_duplicate,default=skip" binding:"oneof=skip update fail"`
+}
+
+type importReportDTO struct {
+	DryRun  bool `json:"dryRun"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Skipped int  `json:"skipped"`
+	Failed  int  `json:"failed"`
+	// Rows lists skipped and failed rows
+	Rows []importRowReportDTO `json:"rows"`
+	// Error is set if import is stopped at unreadable row,
+	// rows before it are imported
+	Error string `json:"error,omitempty"`
+}
+
+type importRowReportDTO struct {
+	Line   int                   `json:"line" example:"3"`
+	Status string                `json:"status" enums:"skipped,failed"`
+	Error  string                `json:"error,omitempty"`
+	Fields []apiutils.FieldError `json:"fields,omitempty"`
+}
+
+//	@Summary		Import songs
+//	@Description	Import songs from CSV with header or JSON Lines. Rows have song and group fields and optional text, releaseDate (YYYY, YYYY-MM or YYYY-MM-DD) and link ones. Missing optional fields are taken from the song info service
+//	@Tags			song
+//	@Accept			text/csv,application/jsonl,application/x-ndjson
+//	@Produce		json
+//	@Param			songs			body		string				true	"Songs to import"
+//	@Param			dry_run			query		bool				false	"Validate rows without saving them"
+//	@Param			on_duplicate	query		string				false	"What to do with existing songs"	Enums(skip, update, fail)	default(skip)
+//	@Success		200				{object}	importReportDTO		"Import report"
+//	@Param			Idempotency-Key	header		string				false	"Unique key of the request, repeated requests with the same key get the first response"
+//	@Failure		400				{object}	importReportDTO		"Malformed source, rows before the malformed one are imported"
+//	@Failure		413				{object}	importReportDTO		"Source is too large, rows read before the limit are imported"
+//	@Failure		409				{object}	apiutils.HTTPError	"Request with the same Idempotency-Key is being processed"
+//	@Failure		415				{object}	apiutils.HTTPError	"Unsupported source format"
+//	@Failure		422				{object}	apiutils.HTTPError	"Idempotency-Key is used for another request"
+//	@Failure		500				{object}	apiutils.HTTPError	"Internal server error"
+//	@Router			/songs:import [post]
+func (ctr *SongController) importSongs(c *gin.Context) {
+	var query importSongsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ginutils.BindQueryError(c, err)
+		return
+	}
+	format, ok := importFormats[c.ContentType()]
+	if !ok {
+		ginutils.Error(c, http.StatusUnsupportedMediaType, errUnsupportedImportType)
+		return
+	}
+
+	source, err := songimport.NewSource(format, c.Request.Body)
+	switch {
+	case ginutils.IsBodyTooLarge(err):
+		ginutils.RequestEntityTooLarge(c, err)
+		return
+	case err != nil:
+		ginutils.BadRequest(c, errors.Wrap(err, "read source"))
+		return
+	}
+
+	ctx := utils.PassContextLogger(c, context.Background())
+	report, err := ctr.songService.ImportSongs(ctx, source,
+		domain.SongImportOptions{
+			DryRun:      query.DryRun,
+			OnDuplicate: domain.DuplicatePolicy(query.OnDuplicate),
+		})
+	switch {
+	case errors.Is(err, domain.ErrImportSource):
+		reportDTO := newImportReportDTO(report, query.DryRun)
+		reportDTO.Error = err.Error()
+		status := http.StatusBadRequest
+		if ginutils.IsBodyTooLarge(err) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, reportDTO)
+		return
+	case err != nil:
+		ginutils.InternalError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, newImportReportDTO(report, query.DryRun))
+}
+
+func newImportReportDTO(
+	report *domain.SongImportReport, dryRun bool,
+) *importReportDTO {
+	reportDTO := importReportDTO{
+		DryRun:  dryRun,
+		Created: report.Created,
+		Updated: report.Updated,
+		Skipped: report.Skipped,
+		Failed:  report.Failed,
+		Rows:    make([]importRowReportDTO, 0, len(report.Rows)),
+	}
+	for _, row := range report.Rows {
+		rowDTO := importRowReportDTO{
+			Line:   row.Line,
+			Status: string(row.Status),
+		}
+		if row.Err != nil {
+			rowDTO.Error = row.Err.Error()
+		}
+		var validationErr *domain.ValidationError
+		if errors.As(row.Err, &validationErr) {
+			rowDTO.Fields = newFieldErrors(validationErr, createSongRequestFields)
+		}
+		reportDTO.Rows = append(reportDTO.Rows, rowDTO)
+	}
+
+	return &reportDTO
+}
@@ -0,0 +1,127 @@
+package songcontroller
+
+import (
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/domain"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type paginationDTO struct {
+	Total          *int `json:"total,omitempty"`
+	TotalEstimated bool `json:"totalEstimated,omitempty"`
+	// Page and TotalPages are not set for cursor pagination
+	Page       *int `json:"page,omitempty"`
+	PerPage    int  `json:"perPage"`
+	TotalPages *int `json:"totalPages,omitempty"`
+}
+
+func newPaginationDTO(
+	pagination domain.Pagination, total *int, totalEstimated bool,
+) paginationDTO {
+	dto := paginationDTO{
+		Total:          total,
+		TotalEstimated: totalEstimated,
+		PerPage:        pagination.PerPage,
+	}
+	if pagination.Cursor != nil {
+		return dto
+	}
+
+	page := pagination.Page + 1
+	dto.Page = &page
+	if total != nil {
+		totalPages := 0
+		if pagination.PerPage > 0 {
+			totalPages = (*total + pagination.PerPage - 1) / pagination.PerPage
+		}
+		dto.TotalPages = &totalPages
+	}
+
+	return dto
+}
+
+// countModeParam is count mode of count query param. Total is
+// not counted by default if cursor is set, as counting it for
+// each next page defeats the purpose of cursor pagination
+func countModeParam(count, cursor *string) domain.CountMode {
+	switch {
+	case count != nil:
+		return domain.CountMode(*count)
+	case cursor != nil:
+		return domain.CountModeNone
+	default:
+		return domain.CountModeExact
+	}
+}
+
+// setPaginationLinks sets Link header pointing at the first,
+// last and adjacent pages. pageParam is the name of page number
+// query param, cursors and sort are the ones of response body
+func setPaginationLinks(
+	c *gin.Context, pageParam string, paginationDTO paginationDTO,
+	pageLen int, nextCursor, prevCursor *string, sort string,
+) error {
+	if paginationDTO.Page == nil {
+		return setCursorPaginationLinks(c, nextCursor, prevCursor, sort)
+	}
+
+	pageLink := func(rel string, page int) ginutils.Link {
+		pageStr := strconv.Itoa(page)
+		return ginutils.Link{
+			Rel:    rel,
+			Params: map[string]*string{pageParam: &pageStr},
+		}
+	}
+
+	page := *paginationDTO.Page
+	links := []ginutils.Link{pageLink("first", 1)}
+	if page > 1 {
+		links = append(links, pageLink("prev", page-1))
+	}
+	switch {
+	case paginationDTO.TotalPages != nil:
+		if page < *paginationDTO.TotalPages {
+			links = append(links, pageLink("next", page+1))
+		}
+		if *paginationDTO.TotalPages > 0 {
+			links = append(links, pageLink("last", *paginationDTO.TotalPages))
+		}
+	// Without total the next page is assumed to exist if this one is full
+	case pageLen > 0 && pageLen == paginationDTO.PerPage:
+		links = append(links, pageLink("next", page+1))
+	}
+
+	ginutils.SetLinkHeader(c, links...)
+	return nil
+}
+
+func setCursorPaginationLinks(
+	c *gin.Context, nextCursor, prevCursor *string, sort string,
+) error {
+	cursorLink := func(rel string, cursor *string) ginutils.Link {
+		return ginutils.Link{
+			Rel:    rel,
+			Params: map[string]*string{"cursor": cursor},
+		}
+	}
+
+	// Cursor without keys points at the end of the list
+	lastCursor, err := encodeCursor(&domain.Cursor{Backward: true}, sort)
+	if err != nil {
+		return errors.Wrap(err, "encode last cursor")
+	}
+	links := []ginutils.Link{cursorLink("first", nil)}
+	if prevCursor != nil {
+		links = append(links, cursorLink("prev", prevCursor))
+	}
+	if nextCursor != nil {
+		links = append(links, cursorLink("next", nextCursor))
+	}
+	links = append(links, cursorLink("last", lastCursor))
+
+	ginutils.SetLinkHeader(c, links...)
+	return nil
+}
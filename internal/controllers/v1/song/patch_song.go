@@ -0,0 +1,207 @@
+package songcontroller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
+	"song-lib/internal/domain"
+	"song-lib/internal/utils"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// songPatchDocument is the song representation patches are applied to,
+// link is the only field that can be removed
+type songPatchDocument struct {
+	Name        *string  `json:"name,omitempty"`
+	ReleaseDate *string  `json:"releaseDate,omitempty"`
+	Link        *string  `json:"link,omitempty"`
+	Couplets    []string `json:"couplets,omitempty"`
+}
+
+//	@Summary		Patch song
+//	@Description	Patch song with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) applied to {"name", "releaseDate", "link", "couplets"} document. Couplets can be edited by index e.g., /couplets/1. Link is removed by setting it to null
+//	@Tags			song
+//	@Accept			application/merge-patch+json,application/json-patch+json
+//	@Produce		json
+//	@Param			songID		path		string							true	"Song ID"
+//	@Param			patch		body		object							true	"Merge patch object or JSON Patch operations array"
+//	@Param			If-Match	header		string							false	"ETag of song to patch, required if server is configured so"
+//	@Success		200			{object}	songDTO							"Success"
+//	@Failure		400			{object}	apiutils.HTTPError				"Malformed patch"
+//	@Header			200			{string}	ETag							"Song version tag"
+//	@Failure		404			{object}	apiutils.HTTPError				"Song not found"
+//	@Failure		409			{object}	apiutils.HTTPError				"JSON Patch test operation failed"
+//	@Failure		412			{object}	apiutils.HTTPError				"Song version doesn't match If-Match"
+//	@Failure		415			{object}	apiutils.HTTPError				"Unsupported patch format"
+//	@Failure		422			{object}	apiutils.ValidationHTTPError	"Patch can't be applied or patched song is invalid"
+//	@Failure		428			{object}	apiutils.HTTPError				"If-Match is required"
+//	@Failure		500			{object}	apiutils.HTTPError				"Internal server error"
+//	@Router			/songs/{songID} [patch]
+func (ctr *SongController) patchSong(c *gin.Context) {
+	songID := c.MustGet("songID").(ksuid.KSUID)
+	version, ok := ctr.ifMatchVersion(c, songID)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		ginutils.BadRequest(c, errors.Wrap(err, "read body"))
+		return
+	}
+
+	applyPatch, err := newSongPatchApplier(c.ContentType(), body)
+	switch {
+	case errors.Is(err, errUnsupportedPatchType):
+		ginutils.Error(c, http.StatusUnsupportedMediaType, err)
+		return
+	case err != nil:
+		ginutils.BadRequest(c, errors.Wrap(err, "parse patch"))
+		return
+	}
+
+	ctx := utils.PassContextLogger(c, context.Background())
+	song, err := ctr.songService.PatchSong(ctx, songID, version,
+		func(song *domain.Song) (*domain.SongUpdate, error) {
+			return patchSong(song, applyPatch)
+		})
+	var validationErr *domain.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		ginutils.ValidationError(c, validationErr,
+			newFieldErrors(validationErr, nil))
+		return
+	case errors.Is(err, domain.ErrSongNotFound):
+		ginutils.NotFoundError(c, err)
+		return
+	case errors.Is(err, domain.ErrVersionMismatch):
+		ginutils.PreconditionFailed(c, err)
+		return
+	case errors.Is(err, domain.ErrPatchConflict):
+		ginutils.ConflictError(c, err)
+		return
+	case errors.Is(err, domain.ErrInvalidPatch):
+		ginutils.ValidationError(c, err, nil)
+		return
+	case err != nil:
+		ginutils.InternalError(c)
+		return
+	}
+
+	setSongETag(c, song)
+	c.JSON(http.StatusOK, newSongDTOFromEntity(song))
+}
+
+var errUnsupportedPatchType = errors.New(
+	"content type should be " + mergePatchContentType +
+		" or " + jsonPatchContentType)
+
+// newSongPatchApplier parses patch, so that malformed patches
+// are rejected before song is locked for update
+func newSongPatchApplier(
+	contentType string, body []byte,
+) (func(doc []byte) ([]byte, error), error) {
+	switch contentType {
+	case mergePatchContentType:
+		var patch map[string]json.RawMessage
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, errors.Wrap(err, "merge patch should be JSON object")
+		}
+		return func(doc []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(doc, body)
+		}, nil
+	case jsonPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return nil, err
+		}
+		return patch.Apply, nil
+	default:
+		return nil, errUnsupportedPatchType
+	}
+}
+
+// patchSong applies patch to the song representation
+// and returns update replacing all song fields
+func patchSong(
+	song *domain.Song, applyPatch func(doc []byte) ([]byte, error),
+) (*domain.SongUpdate, error) {
+	releaseDate := domain.ReleaseDateLayouts.Format(song.ReleaseDate)
+	doc := songPatchDocument{
+		Name:        &song.Name,
+		ReleaseDate: &releaseDate,
+		Couplets:    song.Couplets,
+	}
+	if song.Link != "" {
+		doc.Link = &song.Link
+	}
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal song")
+	}
+
+	patchedJSON, err := applyPatch(docJSON)
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return nil, errors.Wrap(domain.ErrPatchConflict, err.Error())
+	case err != nil:
+		return nil, errors.Wrap(domain.ErrInvalidPatch, err.Error())
+	}
+
+	var patched songPatchDocument
+	decoder := json.NewDecoder(bytes.NewReader(patchedJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return nil, errors.Wrap(domain.ErrInvalidPatch, err.Error())
+	}
+
+	return patched.toSongUpdate()
+}
+
+func (d *songPatchDocument) toSongUpdate() (*domain.SongUpdate, error) {
+	var validationErr domain.ValidationError
+	if d.Name == nil {
+		validationErr.Add(domain.SongFieldName, "is required")
+	}
+	if d.Couplets == nil {
+		validationErr.Add(domain.SongFieldCouplets, "is required")
+	}
+
+	var releaseDate *domain.PartialDate
+	if d.ReleaseDate == nil {
+		validationErr.Add(domain.SongFieldReleaseDate, "is required")
+	} else {
+		date, err := domain.ReleaseDateLayouts.Parse(*d.ReleaseDate)
+		if err != nil {
+			validationErr.Add(domain.SongFieldReleaseDate,
+				"should be YYYY, YYYY-MM or YYYY-MM-DD")
+		}
+		releaseDate = &date
+	}
+	if err := validationErr.OrNil(); err != nil {
+		return nil, err
+	}
+
+	link := ""
+	if d.Link != nil {
+		link = *d.Link
+	}
+
+	return &domain.SongUpdate{
+		Name:        d.Name,
+		ReleaseDate: releaseDate,
+		Couplets:    &d.Couplets,
+		Link:        &link,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package songcontroller
+
+import (
+	"song-lib/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchSong(t *testing.T) {
+	song := &domain.Song{
+		Name: "Uprising",
+		ReleaseDate: domain.PartialDate{
+			Date:      time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC),
+			Precision: domain.DatePrecisionYear,
+		},
+		Link:     "https://example.com/uprising",
+		Couplets: []string{"first", "second"},
+	}
+
+	applyMergePatch, err := newSongPatchApplier(mergePatchContentType,
+		[]byte(`{"releaseDate": "2009-08", "link": null}`))
+	require.NoError(t, err)
+	songUpdate, err := patchSong(song, applyMergePatch)
+	require.NoError(t, err)
+	require.Equal(t, "Uprising", *songUpdate.Name)
+	require.Equal(t, domain.DatePrecisionMonth, songUpdate.ReleaseDate.Precision)
+	require.Equal(t, "", *songUpdate.Link)
+	require.Equal(t, []string{"first", "second"}, *songUpdate.Couplets)
+
+	applyJSONPatch, err := newSongPatchApplier(jsonPatchContentType, []byte(`[
+		{"op": "test", "path": "/couplets/0", "value": "first"},
+		{"op": "replace", "path": "/couplets/1", "value": "new second"},
+		{"op": "add", "path": "/couplets/-", "value": "third"}
+	]`))
+	require.NoError(t, err)
+	songUpdate, err = patchSong(song, applyJSONPatch)
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "new second", "third"}, *songUpdate.Couplets)
+	require.Equal(t, song.Link, *songUpdate.Link)
+
+	applyJSONPatch, err = newSongPatchApplier(jsonPatchContentType,
+		[]byte(`[{"op": "test", "path": "/name", "value": "Resistance"}]`))
+	require.NoError(t, err)
+	_, err = patchSong(song, applyJSONPatch)
+	require.ErrorIs(t, err, domain.ErrPatchConflict)
+
+	applyMergePatch, err = newSongPatchApplier(mergePatchContentType,
+		[]byte(`{"name": null, "genre": "rock"}`))
+	require.NoError(t, err)
+	_, err = patchSong(song, applyMergePatch)
+	require.ErrorIs(t, err, domain.ErrInvalidPatch)
+
+	_, err = newSongPatchApplier("application/json", []byte(`{}`))
+	require.ErrorIs(t, err, errUnsupportedPatchType)
+}
@@ -12,6 +12,20 @@ import (
 
 type SongController struct {
 	songService SongService
+	options     Options
+}
+
+type Options struct {
+	// RequireIfMatch makes changes without If-Match header fail
+	RequireIfMatch bool
+	// Idempotency middleware is applied to song
+	// creation, batches and imports if set
+	Idempotency gin.HandlerFunc
+	// MaxBatchOperations and MaxBatchBodyBytes limit batch request size
+	MaxBatchOperations int
+	MaxBatchBodyBytes  int64
+	// MaxImportBodyBytes limits import request size
+	MaxImportBodyBytes int64
 }
 
 type SongService interface {
@@ -20,40 +34,98 @@ type SongService interface {
 		dto *domain.CreateSongDTO,
 	) (*domain.Song, error)
 
+	GetSong(
+		ctx context.Context,
+		songID ksuid.KSUID,
+	) (*domain.Song, error)
+
 	GetSongsFilteredPaginated(
 		ctx context.Context,
 		filters *domain.SongFilters,
+		sort []domain.SongSortOption,
 		pagination domain.Pagination,
-	) ([]domain.Song, error)
+		fields domain.SongFieldSet,
+	) (*domain.SongsPage, error)
 
 	GetSongCoupletsPaginated(
 		ctx context.Context,
 		songID ksuid.KSUID,
 		pagination domain.Pagination,
-	) ([]string, error)
+	) (*domain.CoupletsPage, error)
 
 	UpdateSong(
 		ctx context.Context,
 		songID ksuid.KSUID,
+		version *int64,
 		songUpdate *domain.SongUpdate,
 	) (*domain.Song, error)
 
+	PatchSong(
+		ctx context.Context,
+		songID ksuid.KSUID,
+		version *int64,
+		patch domain.SongPatchFunc,
+	) (*domain.Song, error)
+
 	DeleteSong(
 		ctx context.Context,
 		songID ksuid.KSUID,
+		version *int64,
 	) error
+
+	ApplySongBatch(
+		ctx context.Context,
+		operations []domain.SongBatchOperation,
+		atomic bool,
+	) (*domain.SongBatchResult, error)
+
+	ExportSongs(
+		ctx context.Context,
+		filters *domain.SongFilters,
+		export func(song *domain.Song) error,
+	) error
+
+	ImportSongs(
+		ctx context.Context,
+		source domain.SongImportSource,
+		options domain.SongImportOptions,
+	) (*domain.SongImportReport, error)
 }
 
-func NewSongController(accountStorage SongService) controllers.Controller {
+func NewSongController(
+	accountStorage SongService, options Options,
+) controllers.Controller {
 	return &SongController{
 		songService: accountStorage,
+		options:     options,
 	}
 }
 
 func (c *SongController) RegisterRoutes(engine *gin.Engine) {
 	songsGroup := engine.Group("api/v1/songs")
-	songsGroup.POST("", c.createSong)
+	createHandlers := []gin.HandlerFunc{c.createSong}
+	if c.options.Idempotency != nil {
+		createHandlers = append(
+			[]gin.HandlerFunc{c.options.Idempotency}, createHandlers...)
+	}
+	songsGroup.POST("", createHandlers...)
 	songsGroup.GET("", c.getSongs)
+	engine.GET("api/v1/export", c.exportSongs)
+
+	batchHandlers := gin.HandlersChain{
+		ginutils.CreateBodyLimitMiddleware(c.options.MaxBatchBodyBytes),
+	}
+	importHandlers := gin.HandlersChain{
+		ginutils.CreateBodyLimitMiddleware(c.options.MaxImportBodyBytes),
+	}
+	if c.options.Idempotency != nil {
+		batchHandlers = append(batchHandlers, c.options.Idempotency)
+		importHandlers = append(importHandlers, c.options.Idempotency)
+	}
+	engine.POST("api/v1/songs:customMethod", customMethodRoutes{
+		":batch":  append(batchHandlers, c.batchSongs),
+		":import": append(importHandlers, c.importSongs),
+	}.handlers()...)
 
 	songIDParsingMiddleware := ginutils.CreateParamParsingMiddleware(
 		"songID",
@@ -61,7 +133,9 @@ func (c *SongController) RegisterRoutes(engine *gin.Engine) {
 		func(param string) (any, error) { return ksuid.Parse(param) },
 	)
 	songGroup := songsGroup.Group("/:songID", songIDParsingMiddleware)
+	songGroup.GET("", c.getSong)
 	songGroup.GET("/couplets", c.getSongCouplets)
 	songGroup.DELETE("", c.deleteSong)
 	songGroup.PUT("", c.updateSong)
+	songGroup.PATCH("", c.patchSong)
 }
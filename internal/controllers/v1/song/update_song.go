@@ -2,8 +2,6 @@ package songcontroller
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"net/http"
 	apiutils "song-lib/internal/controllers/api-utils"
 	ginutils "song-lib/internal/controllers/api-utils/gin-utils"
@@ -11,6 +9,7 @@ import (
 	"song-lib/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"github.com/segmentio/ksuid"
 )
 
@@ -18,14 +17,23 @@ import (
 //	@Description	Update song by passing fields to be updated
 //	@Tags			song
 //	@Accept			json
-//	@Param			songID		path		string					true	"Song ID"
-//	@Param			update_info	body		updateSongRequestBody	true	"Song updating details"
-//	@Success		200			{nil}		nil						"Success"
-//	@Failure		404			{object}	apiutils.HTTPError		"Song not found"
-//	@Failure		500			{object}	apiutils.HTTPError		"Internal server error"
+//	@Param			songID		path		string							true	"Song ID"
+//	@Param			update_info	body		updateSongRequestBody			true	"Song updating details"
+//	@Param			If-Match	header		string							false	"ETag of song to update, required if server is configured so"
+//	@Success		200			{nil}		nil								"Success"
+//	@Header			200			{string}	ETag							"Song version tag"
+//	@Failure		404			{object}	apiutils.HTTPError				"Song not found"
+//	@Failure		412			{object}	apiutils.HTTPError				"Song version doesn't match If-Match"
+//	@Failure		428			{object}	apiutils.HTTPError				"If-Match is required"
+//	@Failure		422			{object}	apiutils.ValidationHTTPError	"Invalid song fields"
+//	@Failure		500			{object}	apiutils.HTTPError				"Internal server error"
 //	@Router			/songs/{songID} [put]
 func (ctr *SongController) updateSong(c *gin.Context) {
 	songID := c.MustGet("songID").(ksuid.KSUID)
+	version, ok := ctr.ifMatchVersion(c, songID)
+	if !ok {
+		return
+	}
 	var reqBody updateSongRequestBody
 	if err := c.BindJSON(&reqBody); err != nil {
 		ginutils.BindJSONError(c, err)
@@ -36,24 +44,37 @@ func (ctr *SongController) updateSong(c *gin.Context) {
 		return
 	}
 
-	songUpdate := domain.SongUpdate(reqBody)
+	songUpdate, parseErr := reqBody.toSongUpdate()
+	if parseErr != nil {
+		ginutils.ValidationError(c, parseErr, newFieldErrors(parseErr, nil))
+		return
+	}
 	ctx := utils.PassContextLogger(c, context.Background())
-	song, err := ctr.songService.UpdateSong(ctx, songID, &songUpdate)
+	song, err := ctr.songService.UpdateSong(ctx, songID, version, songUpdate)
+	var validationErr *domain.ValidationError
 	switch {
+	case errors.As(err, &validationErr):
+		ginutils.ValidationError(c, err, newFieldErrors(validationErr, nil))
+		return
 	case errors.Is(err, domain.ErrSongNotFound):
 		ginutils.NotFoundError(c, err)
 		return
+	case errors.Is(err, domain.ErrVersionMismatch):
+		ginutils.PreconditionFailed(c, err)
+		return
 	case err != nil:
 		ginutils.InternalError(c)
 		return
 	}
 
+	setSongETag(c, song)
 	c.JSON(http.StatusOK, newSongDTOFromEntity(song))
 }
 
 type updateSongRequestBody struct {
-	Name        *string   `json:"name"`
-	ReleaseDate *string   `json:"releaseDate"`
+	Name *string `json:"name"`
+	// ReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD
+	ReleaseDate *string   `json:"releaseDate" example:"2001-05"`
 	Couplets    *[]string `json:"couplets"`
 	Link        *string   `json:"link"`
 }
@@ -63,9 +84,29 @@ func (b *updateSongRequestBody) validate() error {
 		b.Couplets == nil && b.Link == nil {
 		return apiutils.ErrUpdateObjectEmpty
 	}
-	if b.Couplets != nil && len(*b.Couplets) == 0 {
-		return fmt.Errorf("song should have at least one couplet")
+	return nil
+}
+
+// toSongUpdate fails with validation error if
+// release date can't be parsed
+func (b *updateSongRequestBody) toSongUpdate() (
+	*domain.SongUpdate, *domain.ValidationError,
+) {
+	songUpdate := domain.SongUpdate{
+		Name:     b.Name,
+		Couplets: b.Couplets,
+		Link:     b.Link,
+	}
+	if b.ReleaseDate != nil {
+		releaseDate, err := domain.ReleaseDateLayouts.Parse(*b.ReleaseDate)
+		if err != nil {
+			var validationErr domain.ValidationError
+			validationErr.Add(domain.SongFieldReleaseDate,
+				"should be YYYY, YYYY-MM or YYYY-MM-DD")
+			return nil, &validationErr
+		}
+		songUpdate.ReleaseDate = &releaseDate
 	}
 
-	return nil
+	return &songUpdate, nil
 }
@@ -2,33 +2,129 @@ package songcontroller
 
 import (
 	"fmt"
-	"regexp"
+	"slices"
+	apiutils "song-lib/internal/controllers/api-utils"
 	"song-lib/internal/domain"
+	"strings"
 	"time"
 )
 
 const DateLayout = time.DateOnly
 
-var dateRangeRegexp = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2});(\d{4}-\d{2}-\d{2})\]$`)
+// validatePaginationParams checks that params of either
+// page based or cursor based pagination are given
+func validatePaginationParams(
+	page, perPage *int, cursor *string, limit *int,
+) error {
+	if limit != nil {
+		if page != nil || perPage != nil {
+			return fmt.Errorf("page params can't be used with limit")
+		}
+		if *limit < 1 {
+			return fmt.Errorf("limit value is less than 1")
+		}
+		return nil
+	}
 
-func parseDateRange(dateRange string) (domain.TimeRange, error) {
-	matches := dateRangeRegexp.FindStringSubmatch(dateRange)
-	if len(matches) != 3 {
-		return domain.TimeRange{}, fmt.Errorf("date range \"%s\" has invalid format", dateRange)
+	if cursor != nil {
+		return fmt.Errorf("cursor requires limit")
+	}
+	if page == nil || perPage == nil {
+		return fmt.Errorf("page params or limit are required")
+	}
+	if *page < 1 {
+		return fmt.Errorf("page value is less than 1")
+	}
+	if *perPage < 0 {
+		return fmt.Errorf("per page value is less than 0")
 	}
 
-	startDate, err := time.Parse(DateLayout, matches[1])
-	if err != nil {
-		return domain.TimeRange{}, fmt.Errorf("start date \"%s\" has invalid format", matches[0])
+	return nil
+}
+
+// validateCountMode checks that count mode is one of modes
+func validateCountMode(countMode *string, modes ...domain.CountMode) error {
+	if countMode == nil || slices.Contains(modes, domain.CountMode(*countMode)) {
+		return nil
 	}
-	endDate, err := time.Parse(DateLayout, matches[2])
-	if err != nil {
-		return domain.TimeRange{}, fmt.Errorf("end date \"%s\" has invalid format", matches[1])
+	return fmt.Errorf("count value \"%s\" is unknown", *countMode)
+}
+
+var songSortFields = map[string]domain.SongSortField{
+	"name":        domain.SongSortFieldName,
+	"group":       domain.SongSortFieldGroupName,
+	"releaseDate": domain.SongSortFieldReleaseDate,
+	"createdAt":   domain.SongSortFieldCreatedAt,
+	"updatedAt":   domain.SongSortFieldUpdatedAt,
+	"relevance":   domain.SongSortFieldRelevance,
+}
+
+// parseSongSort parses comma separated sort keys,
+// key with "-" prefix means descending order
+func parseSongSort(sort string) ([]domain.SongSortOption, error) {
+	keys := strings.Split(sort, ",")
+	songSort := make([]domain.SongSortOption, 0, len(keys))
+	seenFields := make(map[domain.SongSortField]bool, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		descending := strings.HasPrefix(key, "-")
+		if descending {
+			key = key[1:]
+		} else {
+			key = strings.TrimPrefix(key, "+")
+		}
+
+		field, ok := songSortFields[key]
+		if !ok {
+			return nil, fmt.Errorf("sort key \"%s\" is unknown", key)
+		}
+		if seenFields[field] {
+			return nil, fmt.Errorf("sort key \"%s\" is repeated", key)
+		}
+		seenFields[field] = true
+
+		songSort = append(songSort, domain.SongSortOption{
+			Field:      field,
+			Descending: descending,
+		})
 	}
 
-	if startDate.After(endDate) {
-		return domain.TimeRange{}, fmt.Errorf("date range start date %s is after end date %s", startDate.Format(DateLayout), endDate.Format(DateLayout))
+	return songSort, nil
+}
+
+// formatSongSort is the inverse of parseSongSort
+func formatSongSort(songSort []domain.SongSortOption) string {
+	keys := make([]string, 0, len(songSort))
+	for _, sortOption := range songSort {
+		key := string(sortOption.Field)
+		if sortOption.Descending {
+			key = "-" + key
+		}
+		keys = append(keys, key)
 	}
 
-	return domain.TimeRange{StartTime: startDate, EndTime: endDate}, nil
+	return strings.Join(keys, ",")
+}
+
+// newFieldErrors converts domain field errors to API ones, fieldNames
+// map song fields to request fields if their names differ
+func newFieldErrors(
+	validationErr *domain.ValidationError,
+	fieldNames map[domain.SongField]string,
+) []apiutils.FieldError {
+	fieldErrors := make([]apiutils.FieldError, 0, len(validationErr.Fields))
+	for _, fieldErr := range validationErr.Fields {
+		field, ok := fieldNames[fieldErr.Field]
+		if !ok {
+			field = string(fieldErr.Field)
+		}
+		if fieldErr.Index != nil {
+			field = fmt.Sprintf("%s[%d]", field, *fieldErr.Index)
+		}
+		fieldErrors = append(fieldErrors, apiutils.FieldError{
+			Field:   field,
+			Message: fieldErr.Message,
+		})
+	}
+	return fieldErrors
 }
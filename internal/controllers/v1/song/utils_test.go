@@ -0,0 +1,110 @@
+package songcontroller
+
+import (
+	"encoding/json"
+	"song-lib/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSongSort(t *testing.T) {
+	songSort, err := parseSongSort("-releaseDate, name,+group")
+	require.NoError(t, err)
+	require.Equal(t, []domain.SongSortOption{
+		{Field: domain.SongSortFieldReleaseDate, Descending: true},
+		{Field: domain.SongSortFieldName},
+		{Field: domain.SongSortFieldGroupName},
+	}, songSort)
+
+	for _, sort := range []string{"", "title", "name,-name", "--name"} {
+		_, err := parseSongSort(sort)
+		require.Error(t, err, sort)
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	date := func(s string) *time.Time {
+		d, err := time.Parse(DateLayout, s)
+		require.NoError(t, err)
+		return &d
+	}
+	now := time.Date(2024, 3, 31, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		dateRange string
+		expected  domain.TimeRange
+	}{
+		{"[2001-03-12;2024-11-21]", domain.TimeRange{
+			StartTime: date("2001-03-12"), EndTime: date("2024-11-21")}},
+		{"(2010-01-01;]", domain.TimeRange{
+			StartTime: date("2010-01-01"), StartTimeExclusive: true}},
+		{"[;2010-01-01)", domain.TimeRange{
+			EndTime: date("2010-01-01"), EndTimeExclusive: true}},
+		{"last_30_days", domain.TimeRange{
+			StartTime: date("2024-03-02"), EndTime: date("2024-03-31")}},
+		{"last_1_years", domain.TimeRange{
+			StartTime: date("2023-04-01"), EndTime: date("2024-03-31")}},
+	}
+	for _, test := range tests {
+		timeRange, err := parseDateRange(test.dateRange, now)
+		require.NoError(t, err, test.dateRange)
+		require.Equal(t, test.expected, timeRange, test.dateRange)
+	}
+
+	// Relative ranges cover the given number of days, today included
+	for dateRange, days := range map[string]int{
+		"last_1_days": 1, "last_7_days": 7, "last_2_weeks": 14,
+		"last_1_months": 31, "last_1_years": 366,
+	} {
+		timeRange, err := parseDateRange(dateRange, now)
+		require.NoError(t, err, dateRange)
+		require.Equal(t, days,
+			int(timeRange.EndTime.Sub(*timeRange.StartTime).Hours()/24)+1,
+			dateRange)
+	}
+
+	for _, dateRange := range []string{
+		"[;]", "[2024-01-01;2010-01-01]", "[2010-13-01;]", "last_0_days", "2010-01-01",
+	} {
+		_, err := parseDateRange(dateRange, now)
+		require.Error(t, err, dateRange)
+	}
+}
+
+func TestParsePartialDate(t *testing.T) {
+	timeRange, err := parsePartialDate("2001-05")
+	require.NoError(t, err)
+	require.Equal(t, "2001-05-01", timeRange.StartTime.Format(DateLayout))
+	require.Equal(t, "2001-05-31", timeRange.EndTime.Format(DateLayout))
+
+	_, err = parsePartialDate("2001-5")
+	require.Error(t, err)
+}
+
+func TestSongDTOLink(t *testing.T) {
+	song := &domain.Song{Name: "Uprising"}
+
+	body, err := json.Marshal(newSongDTOFromEntity(song))
+	require.NoError(t, err)
+	require.Contains(t, string(body), `"link":null`)
+
+	body, err = json.Marshal(newSongDTO(song,
+		domain.SongFieldSet{domain.SongFieldName: true}))
+	require.NoError(t, err)
+	require.NotContains(t, string(body), `"link"`)
+}
+
+func TestCoupletsCountMode(t *testing.T) {
+	page, perPage := 1, 10
+	query := getSongCoupletsRequestQuery{Page: &page, PerPage: &perPage}
+	for _, count := range []string{"exact", "none"} {
+		query.Count = &count
+		require.NoError(t, query.validate())
+	}
+	// Couplets are not counted approximately
+	count := "estimated"
+	query.Count = &count
+	require.Error(t, query.validate())
+}
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"song-lib/deploy/migrations"
+	"sync"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratepostgres "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// migrationLockID is the advisory lock key held for the whole
+// migration run, so that replicas started at once wait for
+// each other instead of racing to migrate
+const migrationLockID int64 = 4_170_296_523_181_845
+
+// RunMigrator calls fn with migrator of embedded migrations
+// while holding the migration lock on a dedicated connection
+func RunMigrator(
+	ctx context.Context, db *sqlx.DB,
+	fn func(migrator *migrate.Migrate) error,
+) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get connection")
+	}
+	// Migrator is not closed as it would close the connection
+	// before the lock is released, which would leave the lock held
+	// by the session of the connection returned to the pool
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx,
+		`SELECT pg_advisory_lock($1)`, migrationLockID)
+	if err != nil {
+		return errors.Wrap(err, "acquire migration lock")
+	}
+	defer conn.ExecContext(context.Background(),
+		`SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	source, err := iofs.New(migrations.Postgres, "postgres")
+	if err != nil {
+		return errors.Wrap(err, "read migrations")
+	}
+	driver, err := migratepostgres.WithConnection(
+		ctx, conn, &migratepostgres.Config{})
+	if err != nil {
+		return errors.Wrap(err, "create database driver")
+	}
+	migrator, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		return errors.Wrap(err, "create migrator")
+	}
+
+	return fn(migrator)
+}
+
+// MigrateUp applies all embedded migrations not applied yet
+func MigrateUp(ctx context.Context, db *sqlx.DB) error {
+	return RunMigrator(ctx, db, func(migrator *migrate.Migrate) error {
+		err := migrator.Up()
+		if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return err
+		}
+		return nil
+	})
+}
+
+// CheckMigrations fails unless the last embedded migration is applied
+// and the schema is not dirty, later versions applied by newer releases
+// are fine. Migration lock is not waited for, so that the check
+// doesn't hang while migrations are being applied
+func CheckMigrations(ctx context.Context, db *sqlx.DB) error {
+	latest, err := latestMigrationVersion()
+	if err != nil {
+		return errors.Wrap(err, "get latest migration version")
+	}
+
+	var applied struct {
+		Version int64 `db:"version"`
+		Dirty   bool  `db:"dirty"`
+	}
+	err = db.GetContext(ctx, &applied,
+		`SELECT version, dirty FROM schema_migrations LIMIT 1`)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return errors.New("no migrations applied")
+	case err != nil:
+		return errors.Wrap(err, "get applied version")
+	case applied.Dirty:
+		return errors.Errorf("version %d is dirty", applied.Version)
+	case applied.Version < int64(latest):
+		return errors.Errorf("version %d is older than %d",
+			applied.Version, latest)
+	}
+
+	return nil
+}
+
+// latestMigrationVersion is read once, as embedded migrations never change
+var latestMigrationVersion = sync.OnceValues(readLatestMigrationVersion)
+
+func readLatestMigrationVersion() (uint, error) {
+	source, err := iofs.New(migrations.Postgres, "postgres")
+	if err != nil {
+		return 0, errors.Wrap(err, "read migrations")
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		return 0, errors.Wrap(err, "get first migration")
+	}
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "get next migration")
+		}
+		version = next
+	}
+}
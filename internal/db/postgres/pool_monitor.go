@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// PoolMonitor reports stats of connection pools as metrics
+// of the global meter provider and logs them periodically
+type PoolMonitor struct {
+	pools        map[string]*sqlx.DB
+	registration metric.Registration
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+type poolInstruments struct {
+	open         metric.Int64ObservableGauge
+	inUse        metric.Int64ObservableGauge
+	idle         metric.Int64ObservableGauge
+	waitCount    metric.Int64ObservableCounter
+	waitDuration metric.Float64ObservableCounter
+}
+
+func (m *PoolMonitor) logStats(interval time.Duration) {
+	defer m.stopped.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			for name, db := range m.pools {
+				stats := db.Stats()
+				slog.Info("database pool stats",
+					"pool", name,
+					"open", stats.OpenConnections,
+					"inUse", stats.InUse,
+					"idle", stats.Idle,
+					"waitCount", stats.WaitCount,
+					"waitDuration", stats.WaitDuration.String(),
+					"maxIdleClosed", stats.MaxIdleClosed,
+					"maxIdleTimeClosed", stats.MaxIdleTimeClosed,
+					"maxLifetimeClosed", stats.MaxLifetimeClosed)
+			}
+		}
+	}
+}
+
+func (m *PoolMonitor) observe(
+	instruments *poolInstruments, observer metric.Observer,
+) {
+	for name, db := range m.pools {
+		stats := db.Stats()
+		pool := metric.WithAttributes(attribute.String("pool", name))
+		observer.ObserveInt64(instruments.open,
+			int64(stats.OpenConnections), pool)
+		observer.ObserveInt64(instruments.inUse, int64(stats.InUse), pool)
+		observer.ObserveInt64(instruments.idle, int64(stats.Idle), pool)
+		observer.ObserveInt64(instruments.waitCount, stats.WaitCount, pool)
+		observer.ObserveFloat64(instruments.waitDuration,
+			stats.WaitDuration.Seconds(), pool)
+	}
+}
+
+// Close stops reporting stats, pools are left open
+func (m *PoolMonitor) Close() {
+	close(m.stop)
+	m.stopped.Wait()
+	m.registration.Unregister()
+}
+
+// NewPoolMonitor starts reporting stats of pools by their
+// names, they are logged each logInterval unless it is zero
+func NewPoolMonitor(
+	pools map[string]*sqlx.DB, logInterval time.Duration,
+) (*PoolMonitor, error) {
+	meter := otel.Meter("song-lib/db")
+	var (
+		instruments poolInstruments
+		err         error
+	)
+	instruments.open, err = meter.Int64ObservableGauge(
+		"db.pool.connections.open",
+		metric.WithDescription("Open connections, both in use and idle"))
+	if err != nil {
+		return nil, errors.Wrap(err, "create open connections gauge")
+	}
+	instruments.inUse, err = meter.Int64ObservableGauge(
+		"db.pool.connections.in_use",
+		metric.WithDescription("Connections in use"))
+	if err != nil {
+		return nil, errors.Wrap(err, "create connections in use gauge")
+	}
+	instruments.idle, err = meter.Int64ObservableGauge(
+		"db.pool.connections.idle",
+		metric.WithDescription("Idle connections"))
+	if err != nil {
+		return nil, errors.Wrap(err, "create idle connections gauge")
+	}
+	instruments.waitCount, err = meter.Int64ObservableCounter(
+		"db.pool.wait.count",
+		metric.WithDescription("Connections waited for"))
+	if err != nil {
+		return nil, errors.Wrap(err, "create wait count counter")
+	}
+	instruments.waitDuration, err = meter.Float64ObservableCounter(
+		"db.pool.wait.duration",
+		metric.WithDescription("Time spent waiting for connections"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, errors.Wrap(err, "create wait duration counter")
+	}
+
+	monitor := &PoolMonitor{
+		pools: pools,
+		stop:  make(chan struct{}),
+	}
+	monitor.registration, err = meter.RegisterCallback(
+		func(_ context.Context, observer metric.Observer) error {
+			monitor.observe(&instruments, observer)
+			return nil
+		},
+		instruments.open, instruments.inUse, instruments.idle,
+		instruments.waitCount, instruments.waitDuration)
+	if err != nil {
+		return nil, errors.Wrap(err, "register callback")
+	}
+
+	if logInterval > 0 {
+		monitor.stopped.Add(1)
+		go monitor.logStats(logInterval)
+	}
+
+	return monitor, nil
+}
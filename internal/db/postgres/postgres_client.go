@@ -1,28 +1,72 @@
 package postgres
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"song-lib/internal/config"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
 )
 
+// Delay between connection attempts doubles
+// from the min one up to the max one
+const (
+	connectMinRetryDelay = 100 * time.Millisecond
+	connectMaxRetryDelay = 5 * time.Second
+)
+
+// NewClient connects to the database retrying till ConnectTimeout
+// passes, so that the database starting a bit later is waited for
 func NewClient(cfg config.DBConfig) (*sqlx.DB, error) {
 	connInfo := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
 
-	client, err := sqlx.Connect("postgres", connInfo)
+	client, err := sqlx.Open("postgres", connInfo)
 	if err != nil {
 		return nil, err
 	}
+	configurePool(client, cfg)
 
-	err = client.Ping()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+	err = pingWithBackoff(ctx, client)
 	if err != nil {
+		client.Close()
 		return nil, err
 	}
 
 	return client, nil
 }
+
+func configurePool(db *sqlx.DB, cfg config.DBConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// pingWithBackoff pings db till it responds or ctx is done
+func pingWithBackoff(ctx context.Context, db *sqlx.DB) error {
+	delay := connectMinRetryDelay
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		slog.Warn("database is unreachable, retrying",
+			"error", err.Error(), "delay", delay.String())
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(err, "connect")
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, connectMaxRetryDelay)
+	}
+}
@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"song-lib/internal/config"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ReadRouter routes reads tolerating replication lag to read replicas.
+// Replicas are checked periodically, ones that don't respond or lag
+// behind the primary more than maxLag get no reads until they catch up.
+// Reads go to the primary if no replica is available or the process
+// has written recently, so that they see the writes. Writes of other
+// instances are not tracked, so their callers may read stale data
+type ReadRouter struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   time.Duration
+	// readYourWritesWindow is how long reads
+	// go to the primary after a write
+	readYourWritesWindow time.Duration
+	// lastWrite is the time of the last write in Unix nanoseconds
+	lastWrite atomic.Int64
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+type replica struct {
+	num       int
+	db        *sqlx.DB
+	available atomic.Bool
+}
+
+// Reader returns an available replica or the primary
+func (r *ReadRouter) Reader() *sqlx.DB {
+	lastWrite := time.Unix(0, r.lastWrite.Load())
+	if time.Since(lastWrite) < r.readYourWritesWindow {
+		return r.primary
+	}
+
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		replica := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if replica.available.Load() {
+			return replica.db
+		}
+	}
+	return r.primary
+}
+
+// RecordWrite makes reads go to the primary for a while
+func (r *ReadRouter) RecordWrite() {
+	r.lastWrite.Store(time.Now().UnixNano())
+}
+
+// Fallback tells whether read failed with err on db is to be made
+// on the primary. Replica is unavailable till the next successful
+// check if it can't be connected to
+func (r *ReadRouter) Fallback(db *sqlx.DB, err error) bool {
+	if err == nil || db == r.primary || !isConnectionError(err) {
+		return false
+	}
+
+	for _, replica := range r.replicas {
+		if replica.db == db && replica.available.Swap(false) {
+			slog.Warn("read replica is unavailable",
+				"replica", replica.num, "error", err.Error())
+		}
+	}
+	return true
+}
+
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr)
+}
+
+func (r *ReadRouter) monitorReplicas(interval time.Duration) {
+	defer r.stopped.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkReplicas(interval)
+		}
+	}
+}
+
+// checkReplicas updates availability of replicas,
+// each of them is given timeout to respond
+func (r *ReadRouter) checkReplicas(timeout time.Duration) {
+	for _, replica := range r.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.checkReplica(ctx, replica.db)
+		cancel()
+
+		available := err == nil
+		if replica.available.Swap(available) == available {
+			continue
+		}
+		if available {
+			slog.Info("read replica is available", "replica", replica.num)
+		} else {
+			slog.Warn("read replica is unavailable",
+				"replica", replica.num, "error", err.Error())
+		}
+	}
+}
+
+// replicationLagQuery returns replay lag in seconds. Replica having
+// replayed all it has received is not lagging, even though the last
+// replayed transaction is old if nothing is written to the primary
+const replicationLagQuery = `
+SELECT CASE
+    WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// checkReplica fails if replica doesn't respond or lags behind too much
+func (r *ReadRouter) checkReplica(ctx context.Context, db *sqlx.DB) error {
+	var lagSeconds float64
+	err := db.GetContext(ctx, &lagSeconds, replicationLagQuery)
+	if err != nil {
+		return errors.Wrap(err, "get replication lag")
+	}
+
+	lag := time.Duration(lagSeconds * float64(time.Second))
+	if lag > r.maxLag {
+		return fmt.Errorf("replication lag %s exceeds %s", lag, r.maxLag)
+	}
+	return nil
+}
+
+// Pools returns the primary and replicas by names
+// used in logs and metrics of their pools
+func (r *ReadRouter) Pools() map[string]*sqlx.DB {
+	pools := map[string]*sqlx.DB{"primary": r.primary}
+	for _, replica := range r.replicas {
+		pools[fmt.Sprintf("replica-%d", replica.num)] = replica.db
+	}
+	return pools
+}
+
+// Close stops checking replicas and closes them, primary is left open
+func (r *ReadRouter) Close() {
+	close(r.stop)
+	r.stopped.Wait()
+	for _, replica := range r.replicas {
+		replica.db.Close()
+	}
+}
+
+// NewReadRouter connects to replicas of the config. Replicas that
+// are unavailable at the moment don't fail it, they get reads
+// once they respond to a check
+func NewReadRouter(primary *sqlx.DB, cfg config.DBConfig) (*ReadRouter, error) {
+	router := &ReadRouter{
+		primary:              primary,
+		maxLag:               cfg.ReplicaMaxLag,
+		readYourWritesWindow: cfg.ReadYourWritesWindow,
+		stop:                 make(chan struct{}),
+	}
+	for i, dsn := range cfg.ReplicaDSNs {
+		db, err := sqlx.Open("postgres", dsn)
+		if err != nil {
+			router.Close()
+			return nil, errors.Wrapf(err, "open replica %d", i)
+		}
+		configurePool(db, cfg)
+		router.replicas = append(router.replicas, &replica{num: i, db: db})
+	}
+	if len(router.replicas) == 0 {
+		return router, nil
+	}
+
+	router.checkReplicas(cfg.ReplicaHealthCheckInterval)
+	router.stopped.Add(1)
+	go router.monitorReplicas(cfg.ReplicaHealthCheckInterval)
+
+	return router, nil
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"os"
+	"song-lib/internal/config"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRouter(t *testing.T) {
+	// Nothing listens on the port, so replica is unavailable
+	const dsn = "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1"
+	primary, err := sqlx.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { primary.Close() })
+
+	router, err := NewReadRouter(primary, config.DBConfig{
+		ReplicaDSNs:                []string{dsn},
+		ReplicaHealthCheckInterval: time.Second,
+		ReadYourWritesWindow:       time.Hour,
+	})
+	require.NoError(t, err)
+	t.Cleanup(router.Close)
+	replica := router.replicas[0]
+
+	require.Same(t, primary, router.Reader())
+
+	replica.available.Store(true)
+	require.Same(t, replica.db, router.Reader())
+
+	require.False(t, router.Fallback(replica.db, sql.ErrNoRows))
+	require.True(t, router.Fallback(replica.db,
+		errors.Wrap(driver.ErrBadConn, "execute query")))
+	require.Same(t, primary, router.Reader())
+
+	replica.available.Store(true)
+	router.RecordWrite()
+	require.Same(t, primary, router.Reader())
+}
+
+func TestReadRouterReplicationLag(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+
+	// Primary isn't replaying anything, so it doesn't lag
+	router := &ReadRouter{maxLag: 0}
+	require.NoError(t, router.checkReplica(ctx, db))
+	router.maxLag = -time.Second
+	require.Error(t, router.checkReplica(ctx, db))
+}
@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"song-lib/deploy/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// MigrateUp applies all embedded migrations not applied yet.
+// Migrations of concurrent processes don't interleave
+// as each of them runs in a write transaction
+func MigrateUp(db *sqlx.DB) error {
+	source, err := iofs.New(migrations.SQLite, "sqlite")
+	if err != nil {
+		return errors.Wrap(err, "read migrations")
+	}
+	driver, err := migratesqlite.WithInstance(db.DB, &migratesqlite.Config{})
+	if err != nil {
+		return errors.Wrap(err, "create database driver")
+	}
+	// Migrator is not closed as it would close the database
+	migrator, err := migrate.NewWithInstance("iofs", source, "sqlite3", driver)
+	if err != nil {
+		return errors.Wrap(err, "create migrator")
+	}
+
+	err = migrator.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Package sqlite opens SQLite databases having functions
+// queries share with Postgres: escape_like_string and pg_trgm similarity.
+// FTS5 is required, so go-sqlite3 should be built with sqlite_fts5 tag
+package sqlite
+
+import (
+	"database/sql"
+	"net/url"
+	"song-lib/internal/config"
+	"song-lib/internal/trigram"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+const driverName = "sqlite3_song_lib"
+
+// ErrFTS5Unavailable is returned if SQLite is built without FTS5
+var ErrFTS5Unavailable = errors.New(
+	"SQLite is built without FTS5, build with -tags sqlite_fts5")
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: registerFunctions,
+	})
+}
+
+func registerFunctions(conn *sqlite3.SQLiteConn) error {
+	functions := map[string]any{
+		// escape_like_string escapes LIKE wildcards with backslash,
+		// so patterns are to have ESCAPE '\' clause
+		"escape_like_string": likeEscaper.Replace,
+		// casefold lowers case of all letters, not only ASCII ones
+		"casefold":   strings.ToLower,
+		"similarity": trigram.Similarity,
+	}
+	for name, function := range functions {
+		err := conn.RegisterFunc(name, function, true)
+		if err != nil {
+			return errors.Wrapf(err, "register function %s", name)
+		}
+	}
+	return nil
+}
+
+// NewClient opens database file creating it if needed. Connections
+// enforce foreign keys and start transactions with write lock,
+// so that transactions reading before writing don't fail to upgrade
+func NewClient(cfg config.SQLiteConfig) (*sqlx.DB, error) {
+	params := url.Values{
+		"_foreign_keys": {"on"},
+		"_journal_mode": {"WAL"},
+		"_busy_timeout": {"5000"},
+		"_txlock":       {"immediate"},
+	}
+	client, err := sqlx.Connect(driverName, cfg.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var fts5 bool
+	err = client.Get(&fts5, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`)
+	if err != nil {
+		client.Close()
+		return nil, errors.Wrap(err, "check FTS5")
+	}
+	if !fts5 {
+		client.Close()
+		return nil, ErrFTS5Unavailable
+	}
+
+	return client, nil
+}
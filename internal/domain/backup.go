@@ -0,0 +1,34 @@
+package domain
+
+// BackupSink receives all music groups before songs
+type BackupSink interface {
+	WriteMusicGroup(group *MusicGroup) error
+	WriteSong(song *Song) error
+}
+
+// RestoreSource gives all music groups before songs,
+// io.EOF is returned after the last one of each
+type RestoreSource interface {
+	NextMusicGroup() (*MusicGroup, error)
+	NextSong() (*Song, error)
+}
+
+// RestoreConflictPolicy tells what to do with backup songs having the
+// same ID or the same name and music group as existing ones
+type RestoreConflictPolicy string
+
+const (
+	RestoreConflictFail      RestoreConflictPolicy = "fail"
+	RestoreConflictSkip      RestoreConflictPolicy = "skip"
+	RestoreConflictOverwrite RestoreConflictPolicy = "overwrite"
+)
+
+type RestoreReport struct {
+	// MusicGroups is the number of created music groups, groups
+	// with the same name as existing ones are merged into them
+	MusicGroups int
+	// Songs is the number of restored songs including overwritten ones
+	Songs       int
+	Skipped     int
+	Overwritten int
+}
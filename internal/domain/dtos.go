@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"song-lib/internal/filterexpr"
+
+	"github.com/segmentio/ksuid"
+)
 
 type CreateSongDTO struct {
 	SongName       string
@@ -8,7 +12,7 @@ type CreateSongDTO struct {
 }
 
 type IntegrationSongInfo struct {
-	ReleaseDate time.Time
+	ReleaseDate PartialDate
 	Text        string
 	Link        string
 }
@@ -16,14 +20,122 @@ type IntegrationSongInfo struct {
 type SongFilters struct {
 	SongName             *string
 	MusicGroupName       *string
+	NameMatchMode        MatchMode
 	SongLink             *string
 	SongCoupletContains  *string
 	SongReleaseDateRange *TimeRange
+	// Expression is parsed filter expression, nil if not given
+	Expression filterexpr.Expr
+}
+
+type SongsPage struct {
+	Songs          []Song
+	NextCursor     *Cursor
+	PrevCursor     *Cursor
+	Total          *int
+	TotalEstimated bool
+	Suggestion     *SongSearchSuggestion
+}
+
+type CoupletsPage struct {
+	Couplets   []string
+	NextCursor *Cursor
+	PrevCursor *Cursor
+	Total      *int
+}
+
+type SongSearchSuggestion struct {
+	SongName       *string
+	MusicGroupName *string
+}
+
+type AutocompleteSuggestion struct {
+	ID             ksuid.KSUID
+	Name           string
+	MusicGroupName string
 }
 
 type SongUpdate struct {
 	Name        *string
-	ReleaseDate *string
+	ReleaseDate *PartialDate
 	Couplets    *[]string
 	Link        *string
 }
+
+func (f *SongFilters) isExactNameLookup() bool {
+	return (f.NameMatchMode == "" || f.NameMatchMode == MatchModeExact) &&
+		(f.SongName != nil || f.MusicGroupName != nil)
+}
+
+// withoutMatches drops suggested names equal to the ones
+// that were searched, returns nil if nothing is left
+func (s *SongSearchSuggestion) withoutMatches(
+	filters *SongFilters,
+) *SongSearchSuggestion {
+	if s == nil {
+		return nil
+	}
+
+	res := *s
+	if res.SongName != nil && filters.SongName != nil &&
+		*res.SongName == *filters.SongName {
+		res.SongName = nil
+	}
+	if res.MusicGroupName != nil && filters.MusicGroupName != nil &&
+		*res.MusicGroupName == *filters.MusicGroupName {
+		res.MusicGroupName = nil
+	}
+	if res.SongName == nil && res.MusicGroupName == nil {
+		return nil
+	}
+
+	return &res
+}
+
+// SongPatchFunc returns update replacing all song fields,
+// it is called with the current song state
+type SongPatchFunc func(song *Song) (*SongUpdate, error)
+
+type SongBatchOperationKind string
+
+const (
+	SongBatchOperationCreate SongBatchOperationKind = "create"
+	SongBatchOperationUpdate SongBatchOperationKind = "update"
+	SongBatchOperationDelete SongBatchOperationKind = "delete"
+)
+
+// SongBatchOperation has Create set for creation, SongID,
+// Version and Update set for update, SongID and Version
+// set for deletion. Nil version matches any
+type SongBatchOperation struct {
+	Kind    SongBatchOperationKind
+	Create  *CreateSongDTO
+	SongID  ksuid.KSUID
+	Version *int64
+	Update  *SongUpdate
+	// Err is set if operation is found invalid before it's applied,
+	// the operation fails with it as if it failed validation
+	Err error
+}
+
+// SongBatchWrite is a validated batch operation,
+// Song is the one to be saved on creation
+type SongBatchWrite struct {
+	Kind    SongBatchOperationKind
+	Song    *Song
+	SongID  ksuid.KSUID
+	Version *int64
+	Update  *SongUpdate
+}
+
+type SongBatchItemResult struct {
+	// Song is nil for deletion and failed operations
+	Song *Song
+	Err  error
+}
+
+type SongBatchResult struct {
+	// Committed is false if atomic batch was rolled back
+	Committed bool
+	Items     []SongBatchItemResult
+}
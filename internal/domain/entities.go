@@ -11,8 +11,12 @@ type Song struct {
 	Name        string
 	MusicGroup  MusicGroup
 	Couplets    []string
-	ReleaseDate time.Time
+	ReleaseDate PartialDate
 	Link        string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// Version is incremented on each song change
+	Version int64
 }
 
 type MusicGroup struct {
@@ -5,9 +5,20 @@ import "github.com/pkg/errors"
 var (
 	ErrInternal    = errors.New("internal error")
 	ErrIntegration = errors.New("integration error")
+	ErrTimeout     = errors.New("timeout")
 
 	ErrSongNotFound      = errors.New("song not found")
 	ErrSongAlreadyExists = errors.New("song already exists")
+	ErrVersionMismatch   = errors.New("song version doesn't match")
+
+	ErrInvalidPatch  = errors.New("patch can't be applied to song")
+	ErrPatchConflict = errors.New("patch conflicts with song state")
+
+	ErrBatchAborted = errors.New("batch is aborted due to failed operation")
+
+	ErrRestoreConflict = errors.New("song from backup conflicts with existing one")
+
+	ErrIdempotencyKeyLost = errors.New("idempotency key is claimed by another request")
 )
 
 type SongInfoIntegrationError error
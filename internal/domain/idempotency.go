@@ -0,0 +1,16 @@
+package domain
+
+// IdempotencyRecord is stored for each idempotency key,
+// Response is nil while the first request is being processed
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Response    *IdempotentResponse
+}
+
+// IdempotentResponse is replayed for repeated requests
+type IdempotentResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       []byte
+}
@@ -0,0 +1,245 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	slogutils "song-lib/internal/utils/slog-utils"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrImportSource = errors.New("import source can't be read")
+
+// SongImportRow is a song read from import source,
+// optional fields missing in the source are nil
+type SongImportRow struct {
+	// Line is the source line the row starts at
+	Line           int
+	SongName       string
+	MusicGroupName string
+	Text           *string
+	ReleaseDate    *PartialDate
+	Link           *string
+	// Err is set if the row can't be parsed,
+	// the rest of the source can still be read
+	Err error
+}
+
+type SongImportSource interface {
+	// Next returns io.EOF after the last row
+	Next() (*SongImportRow, error)
+}
+
+// DuplicatePolicy tells what to do with imported
+// songs that already exist in the library
+type DuplicatePolicy string
+
+const (
+	DuplicatePolicySkip   DuplicatePolicy = "skip"
+	DuplicatePolicyUpdate DuplicatePolicy = "update"
+	DuplicatePolicyFail   DuplicatePolicy = "fail"
+)
+
+type SongImportOptions struct {
+	// DryRun validates rows without saving them
+	// or calling the song info integration
+	DryRun      bool
+	OnDuplicate DuplicatePolicy
+}
+
+type SongImportRowStatus string
+
+const (
+	SongImportRowCreated SongImportRowStatus = "created"
+	SongImportRowUpdated SongImportRowStatus = "updated"
+	SongImportRowSkipped SongImportRowStatus = "skipped"
+	SongImportRowFailed  SongImportRowStatus = "failed"
+)
+
+type SongImportRowReport struct {
+	Line   int
+	Status SongImportRowStatus
+	Err    error
+}
+
+type SongImportReport struct {
+	Created int
+	Updated int
+	Skipped int
+	Failed  int
+	// Rows lists skipped and failed rows only,
+	// so that the report stays small on large imports
+	Rows []SongImportRowReport
+}
+
+func (r *SongImportReport) add(rowReport SongImportRowReport) {
+	switch rowReport.Status {
+	case SongImportRowCreated:
+		r.Created++
+	case SongImportRowUpdated:
+		r.Updated++
+	case SongImportRowSkipped:
+		r.Skipped++
+		r.Rows = append(r.Rows, rowReport)
+	case SongImportRowFailed:
+		r.Failed++
+		r.Rows = append(r.Rows, rowReport)
+	}
+}
+
+// ImportSongs imports rows one by one as they are read. Songs missing
+// text, release date or link are completed with the song info integration.
+// If the source can't be read, report of rows imported so far is returned
+// along with ErrImportSource
+func (s *SongService) ImportSongs(
+	ctx context.Context, source SongImportSource,
+	options SongImportOptions,
+) (*SongImportReport, error) {
+
+	report := &SongImportReport{}
+	// Dry run saves nothing, so duplicates within
+	// the source are tracked separately
+	var seen map[string]bool
+	if options.DryRun {
+		seen = make(map[string]bool)
+	}
+	for {
+		row, err := source.Next()
+		switch {
+		case errors.Is(err, io.EOF):
+			return report, nil
+		case err != nil:
+			return report, fmt.Errorf("%w: %w", ErrImportSource, err)
+		}
+
+		report.add(s.importSong(ctx, row, options, seen))
+	}
+}
+
+func (s *SongService) importSong(
+	ctx context.Context, row *SongImportRow,
+	options SongImportOptions, seen map[string]bool,
+) SongImportRowReport {
+
+	failed := func(err error) SongImportRowReport {
+		return SongImportRowReport{
+			Line: row.Line, Status: SongImportRowFailed, Err: err}
+	}
+	if row.Err != nil {
+		return failed(row.Err)
+	}
+
+	dto := CreateSongDTO{
+		SongName:       row.SongName,
+		MusicGroupName: row.MusicGroupName,
+	}
+	dto.normalize()
+	songUpdate := SongUpdate{ReleaseDate: row.ReleaseDate, Link: row.Link}
+	if row.Text != nil {
+		couplets := strings.Split(*row.Text, "\n\n")
+		songUpdate.Couplets = &couplets
+	}
+	songUpdate.normalize()
+	if err := dto.validate(); err != nil {
+		return failed(err)
+	}
+	if err := songUpdate.validate(time.Now()); err != nil {
+		return failed(err)
+	}
+
+	existing, err := s.songRepository.GetSongByNameAndMusicGroupName(
+		ctx, dto.SongName, dto.MusicGroupName)
+	if err != nil && !errors.Is(err, ErrSongNotFound) {
+		slogutils.Error(ctx, "import song:",
+			errors.Wrap(err, "get existing song"))
+		return failed(ErrInternal)
+	}
+	songKey := dto.MusicGroupName + "\x00" + dto.SongName
+	if existing != nil || seen[songKey] {
+		return s.importDuplicateSong(ctx, row, existing, &songUpdate, options)
+	}
+
+	if options.DryRun {
+		seen[songKey] = true
+		return SongImportRowReport{Line: row.Line, Status: SongImportRowCreated}
+	}
+
+	song := &Song{
+		Name:       dto.SongName,
+		MusicGroup: MusicGroup{Name: dto.MusicGroupName},
+	}
+	if songUpdate.Couplets == nil || songUpdate.ReleaseDate == nil ||
+		songUpdate.Link == nil {
+
+		song, err = s.newSongWithInfo(ctx, &dto)
+		if err != nil {
+			return failed(err)
+		}
+	}
+	if songUpdate.Couplets != nil {
+		song.Couplets = *songUpdate.Couplets
+	}
+	if songUpdate.ReleaseDate != nil {
+		song.ReleaseDate = *songUpdate.ReleaseDate
+	}
+	if songUpdate.Link != nil {
+		song.Link = *songUpdate.Link
+	}
+
+	_, err = s.songRepository.SaveSong(ctx, song)
+	switch {
+	case errors.Is(err, ErrSongAlreadyExists):
+		// Song is created concurrently since it was looked up
+		existing, err = s.songRepository.GetSongByNameAndMusicGroupName(
+			ctx, dto.SongName, dto.MusicGroupName)
+		if err != nil {
+			slogutils.Error(ctx, "import song:",
+				errors.Wrap(err, "get existing song"))
+			return failed(ErrInternal)
+		}
+		return s.importDuplicateSong(ctx, row, existing, &songUpdate, options)
+	case err != nil:
+		slogutils.Error(ctx, "import song:", errors.Wrap(err, "save song"))
+		return failed(ErrInternal)
+	}
+
+	return SongImportRowReport{Line: row.Line, Status: SongImportRowCreated}
+}
+
+// importDuplicateSong applies duplicate policy, existing
+// song is nil for duplicates within dry run source
+func (s *SongService) importDuplicateSong(
+	ctx context.Context, row *SongImportRow, existing *Song,
+	songUpdate *SongUpdate, options SongImportOptions,
+) SongImportRowReport {
+
+	report := SongImportRowReport{Line: row.Line, Err: ErrSongAlreadyExists}
+	switch {
+	case options.OnDuplicate == DuplicatePolicyFail:
+		report.Status = SongImportRowFailed
+		return report
+	case options.OnDuplicate != DuplicatePolicyUpdate,
+		songUpdate.ReleaseDate == nil && songUpdate.Link == nil &&
+			songUpdate.Couplets == nil:
+		report.Status = SongImportRowSkipped
+		return report
+	}
+
+	report.Err = nil
+	report.Status = SongImportRowUpdated
+	if options.DryRun {
+		return report
+	}
+
+	_, err := s.songRepository.UpdateSong(ctx, existing.ID, nil, songUpdate)
+	if err != nil {
+		slogutils.Error(ctx, "import song:", errors.Wrap(err, "update song"))
+		report.Status = SongImportRowFailed
+		report.Err = ErrInternal
+	}
+
+	return report
+}
@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+)
+
+type importSongRepository struct {
+	SongRepository
+	songs   map[string]*Song
+	updates int
+}
+
+func (r *importSongRepository) GetSongByNameAndMusicGroupName(
+	_ context.Context, songName, musicGroupName string,
+) (*Song, error) {
+	song, ok := r.songs[musicGroupName+"/"+songName]
+	if !ok {
+		return nil, ErrSongNotFound
+	}
+	return song, nil
+}
+
+func (r *importSongRepository) SaveSong(_ context.Context, song *Song) (*Song, error) {
+	song.ID = ksuid.New()
+	r.songs[song.MusicGroup.Name+"/"+song.Name] = song
+	return song, nil
+}
+
+func (r *importSongRepository) UpdateSong(
+	_ context.Context, _ ksuid.KSUID, _ *int64, _ *SongUpdate,
+) (*Song, error) {
+	r.updates++
+	return &Song{}, nil
+}
+
+// noTxManager runs functions without transactions
+type noTxManager struct{}
+
+func (noTxManager) InTx(
+	ctx context.Context, _ TxOptions, fn func(ctx context.Context) error,
+) error {
+	return fn(ctx)
+}
+
+type importSongInfoIntegration struct {
+	calls int
+}
+
+func (i *importSongInfoIntegration) GetSongInfo(
+	_, _ string,
+) (*IntegrationSongInfo, error) {
+	i.calls++
+	return &IntegrationSongInfo{
+		ReleaseDate: PartialDate{
+			Date:      time.Date(2009, 9, 14, 0, 0, 0, 0, time.UTC),
+			Precision: DatePrecisionDay,
+		},
+		Text: "first\n\nsecond",
+		Link: "https://example.com/song",
+	}, nil
+}
+
+type sliceImportSource struct {
+	rows []*SongImportRow
+}
+
+func (s *sliceImportSource) Next() (*SongImportRow, error) {
+	if len(s.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+func TestImportSongs(t *testing.T) {
+	link := "https://example.com/resistance"
+	newSource := func() *sliceImportSource {
+		return &sliceImportSource{rows: []*SongImportRow{
+			{Line: 2, SongName: "Uprising", MusicGroupName: "Muse"},
+			{Line: 3, SongName: " Resistance", MusicGroupName: "Muse", Link: &link},
+			{Line: 4, SongName: "Resistance", MusicGroupName: "Muse", Link: &link},
+			{Line: 5, SongName: "", MusicGroupName: "Muse"},
+		}}
+	}
+	repo := &importSongRepository{songs: make(map[string]*Song)}
+	integration := &importSongInfoIntegration{}
+	service := NewSongService(repo, noTxManager{}, integration, 1)
+
+	report, err := service.ImportSongs(context.Background(), newSource(),
+		SongImportOptions{DryRun: true, OnDuplicate: DuplicatePolicyFail})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Created)
+	require.Equal(t, 2, report.Failed)
+	require.Equal(t, 4, report.Rows[0].Line)
+	require.ErrorIs(t, report.Rows[0].Err, ErrSongAlreadyExists)
+	require.Empty(t, repo.songs)
+	require.Zero(t, integration.calls)
+
+	report, err = service.ImportSongs(context.Background(), newSource(),
+		SongImportOptions{OnDuplicate: DuplicatePolicyUpdate})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Created)
+	require.Equal(t, 1, report.Updated)
+	require.Equal(t, 1, report.Failed)
+	require.Equal(t, 2, integration.calls)
+	require.Equal(t, link, repo.songs["Muse/Resistance"].Link)
+	require.Equal(t, []string{"first", "second"}, repo.songs["Muse/Resistance"].Couplets)
+	require.Equal(t, 1, repo.updates)
+
+	report, err = service.ImportSongs(context.Background(), newSource(),
+		SongImportOptions{OnDuplicate: DuplicatePolicySkip})
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Skipped)
+	require.Equal(t, 1, repo.updates)
+}
+
+// racingSongRepository has the song created
+// concurrently once it is looked up
+type racingSongRepository struct {
+	importSongRepository
+	racing *Song
+}
+
+func (r *racingSongRepository) SaveSong(_ context.Context, song *Song) (*Song, error) {
+	r.songs[song.MusicGroup.Name+"/"+song.Name] = r.racing
+	return nil, ErrSongAlreadyExists
+}
+
+func TestImportSongsConcurrentDuplicate(t *testing.T) {
+	repo := &racingSongRepository{
+		importSongRepository: importSongRepository{songs: make(map[string]*Song)},
+		racing:               &Song{ID: ksuid.New(), Name: "Uprising"},
+	}
+	service := NewSongService(repo, noTxManager{}, &importSongInfoIntegration{}, 1)
+	link := "https://example.com/uprising"
+	source := &sliceImportSource{rows: []*SongImportRow{
+		{Line: 2, SongName: "Uprising", MusicGroupName: "Muse", Link: &link},
+	}}
+
+	report, err := service.ImportSongs(context.Background(), source,
+		SongImportOptions{OnDuplicate: DuplicatePolicyUpdate})
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Updated)
+	require.Equal(t, 1, repo.updates)
+}
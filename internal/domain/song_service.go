@@ -2,8 +2,11 @@ package domain
 
 import (
 	"context"
+	"slices"
 	slogutils "song-lib/internal/utils/slog-utils"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/segmentio/ksuid"
@@ -11,7 +14,11 @@ import (
 
 type SongService struct {
 	songRepository      SongRepository
+	txManager           TxManager
 	SongInfoIntegration SongInfoIntegration
+	// batchConcurrency limits concurrent integration
+	// calls made for a batch of operations
+	batchConcurrency int
 }
 
 type SongRepository interface {
@@ -19,13 +26,52 @@ type SongRepository interface {
 
 	GetSongsFilteredPaginated(
 		ctx context.Context, filters *SongFilters,
-		pagination Pagination,
-	) ([]Song, error)
+		sort []SongSortOption, pagination Pagination,
+		fields SongFieldSet,
+	) (*SongsPage, error)
+
+	// ForEachSongFiltered calls fn for each filtered song
+	// without loading all of them at once, fn errors are returned as is
+	ForEachSongFiltered(
+		ctx context.Context, filters *SongFilters,
+		fn func(song *Song) error,
+	) error
+
+	CountSongsFiltered(
+		ctx context.Context, filters *SongFilters,
+		estimated bool,
+	) (int, error)
+
+	GetSongSearchSuggestion(
+		ctx context.Context, filters *SongFilters,
+	) (*SongSearchSuggestion, error)
 
 	GetSongCoupletsPaginated(
 		ctx context.Context, songID ksuid.KSUID,
 		pagination Pagination,
-	) ([]string, error)
+	) (*CoupletsPage, error)
+
+	CountSongCouplets(
+		ctx context.Context, songID ksuid.KSUID,
+	) (int, error)
+
+	GetAutocompleteSuggestions(
+		ctx context.Context, query string,
+		kind AutocompleteKind, limit int,
+	) ([]AutocompleteSuggestion, error)
+
+	// GetSongByID fails with ErrSongNotFound
+	// if there is no such song
+	GetSongByID(
+		ctx context.Context, songID ksuid.KSUID,
+	) (*Song, error)
+
+	// GetSongByNameAndMusicGroupName fails with
+	// ErrSongNotFound if there is no such song
+	GetSongByNameAndMusicGroupName(
+		ctx context.Context, songName string,
+		musicGroupName string,
+	) (*Song, error)
 
 	SongExistsByID(
 		ctx context.Context, songID ksuid.KSUID,
@@ -36,11 +82,32 @@ type SongRepository interface {
 		musicGroupName string,
 	) (bool, error)
 
+	// UpdateSong, PatchSong and DeleteSong change the song only if
+	// it has the given version, failing with ErrVersionMismatch otherwise.
+	// Nil version matches any. They fail with ErrSongNotFound
+	// if there is no such song
 	UpdateSong(
 		ctx context.Context, songID ksuid.KSUID,
-		songUpdate *SongUpdate,
+		version *int64, songUpdate *SongUpdate,
 	) (*Song, error)
-	DeleteSong(ctx context.Context, songID ksuid.KSUID) error
+	// PatchSong applies the patch to the song locked for update,
+	// patch errors are returned as is
+	PatchSong(
+		ctx context.Context, songID ksuid.KSUID,
+		version *int64, patch SongPatchFunc,
+	) (*Song, error)
+	DeleteSong(
+		ctx context.Context, songID ksuid.KSUID,
+		version *int64,
+	) error
+
+	// ApplySongBatch applies writes in a single transaction and returns
+	// a result for each of them. Atomic batch is rolled back on the first
+	// failed write, the rest of writes are failed with ErrBatchAborted
+	ApplySongBatch(
+		ctx context.Context, writes []SongBatchWrite,
+		atomic bool,
+	) ([]SongBatchItemResult, error)
 }
 
 type SongInfoIntegration interface {
@@ -51,12 +118,16 @@ type SongInfoIntegration interface {
 
 func NewSongService(
 	songRepository SongRepository,
+	txManager TxManager,
 	songInfoIntegration SongInfoIntegration,
+	batchConcurrency int,
 ) *SongService {
 
 	return &SongService{
 		songRepository:      songRepository,
+		txManager:           txManager,
 		SongInfoIntegration: songInfoIntegration,
+		batchConcurrency:    max(batchConcurrency, 1),
 	}
 }
 
@@ -64,6 +135,65 @@ func (s *SongService) CreateSong(
 	ctx context.Context, dto *CreateSongDTO,
 ) (*Song, error) {
 
+	song, err := s.prepareSong(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+
+	// Song may have been created while the integration was
+	// called, so existence is checked again along with saving
+	var savedSong *Song
+	err = s.txManager.InTx(ctx,
+		TxOptions{Isolation: TxIsolationSerializable},
+		func(ctx context.Context) error {
+			exists, err := s.songRepository.
+				SongExistsByNameAndMusicGroupName(
+					ctx, song.Name, song.MusicGroup.Name)
+			switch {
+			case err != nil:
+				return errors.Wrap(err, "check song exists")
+			case exists:
+				return ErrSongAlreadyExists
+			}
+
+			savedSong, err = s.songRepository.SaveSong(ctx, song)
+			return errors.Wrap(err, "save song")
+		})
+	switch {
+	case errors.Is(err, ErrSongAlreadyExists):
+		return nil, ErrSongAlreadyExists
+	case err != nil:
+		slogutils.Error(ctx, "create song:", err)
+		return nil, ErrInternal
+	}
+
+	return savedSong, nil
+}
+
+// prepareSong validates the song to be created and
+// completes it with the info from the integration
+func (s *SongService) prepareSong(
+	ctx context.Context, dto *CreateSongDTO,
+) (*Song, error) {
+
+	if err := s.checkNewSong(ctx, dto); err != nil {
+		return nil, err
+	}
+
+	return s.newSongWithInfo(ctx, dto)
+}
+
+// checkNewSong validates the song to be created
+// and checks that it is not in the library yet
+func (s *SongService) checkNewSong(
+	ctx context.Context, dto *CreateSongDTO,
+) error {
+
+	dto.normalize()
+	if err := dto.validate(); err != nil {
+		return err
+	}
+
 	exists, err := s.songRepository.
 		SongExistsByNameAndMusicGroupName(
 			ctx, dto.SongName,
@@ -72,11 +202,20 @@ func (s *SongService) CreateSong(
 	case err != nil:
 		slogutils.Error(ctx, "create song:",
 			errors.Wrap(err, "check song exists"))
-		return nil, ErrInternal
+		return ErrInternal
 	case exists:
-		return nil, ErrSongAlreadyExists
+		return ErrSongAlreadyExists
 	}
 
+	return nil
+}
+
+// newSongWithInfo creates song entity filled
+// with the info from the integration
+func (s *SongService) newSongWithInfo(
+	ctx context.Context, dto *CreateSongDTO,
+) (*Song, error) {
+
 	additionalSongInfo, err := s.SongInfoIntegration.
 		GetSongInfo(dto.SongName, dto.MusicGroupName)
 	switch {
@@ -93,72 +232,200 @@ func (s *SongService) CreateSong(
 	}
 
 	songCouplets := strings.Split(additionalSongInfo.Text, "\n\n")
-	song, err := s.songRepository.SaveSong(
-		ctx,
-		&Song{
-			Name:        dto.SongName,
-			MusicGroup:  MusicGroup{Name: dto.MusicGroupName},
-			Couplets:    songCouplets,
-			ReleaseDate: additionalSongInfo.ReleaseDate,
-			Link:        additionalSongInfo.Link,
-		})
-	if err != nil {
-		slogutils.Error(ctx, "create song:",
-			errors.Wrap(err, "save song"))
-		return nil, ErrInternal
-	}
-
-	return song, nil
+	return &Song{
+		Name:        dto.SongName,
+		MusicGroup:  MusicGroup{Name: dto.MusicGroupName},
+		Couplets:    songCouplets,
+		ReleaseDate: additionalSongInfo.ReleaseDate,
+		Link:        additionalSongInfo.Link,
+	}, nil
 }
 
 func (s *SongService) GetSongCoupletsPaginated(
 	ctx context.Context, songID ksuid.KSUID,
 	pagination Pagination,
-) ([]string, error) {
+) (*CoupletsPage, error) {
+
+	// Page and total are read from a single
+	// snapshot, so that they are consistent
+	var coupletsPage *CoupletsPage
+	err := s.txManager.InTx(ctx,
+		TxOptions{Isolation: TxIsolationRepeatableRead, ReadOnly: true},
+		func(ctx context.Context) error {
+			exists, err := s.songRepository.SongExistsByID(ctx, songID)
+			switch {
+			case err != nil:
+				return errors.Wrap(err, "check song exists")
+			case !exists:
+				return ErrSongNotFound
+			}
+
+			coupletsPage, err = s.songRepository.
+				GetSongCoupletsPaginated(ctx, songID, pagination)
+			if err != nil {
+				return err
+			}
 
-	exists, err := s.songRepository.SongExistsByID(ctx, songID)
+			if pagination.Count != CountModeNone {
+				total, ok := pagination.totalFromPage(len(coupletsPage.Couplets))
+				if !ok {
+					// Songs have few couplets, so they are always counted exactly
+					total, err = s.songRepository.CountSongCouplets(ctx, songID)
+					if err != nil {
+						return errors.Wrap(err, "count couplets")
+					}
+				}
+				coupletsPage.Total = &total
+			}
+			return nil
+		})
 	switch {
-	case err != nil:
-		slogutils.Error(ctx, "get song couplets:",
-			errors.Wrap(err, "check song exists"))
-		return nil, ErrInternal
-	case !exists:
+	case errors.Is(err, ErrSongNotFound):
 		return nil, ErrSongNotFound
-	}
-
-	songCouplets, err := s.songRepository.
-		GetSongCoupletsPaginated(ctx, songID, pagination)
-	if err != nil {
+	case err != nil:
 		slogutils.Error(ctx, "get song couplets:", err)
 		return nil, ErrInternal
 	}
 
-	return songCouplets, nil
+	return coupletsPage, nil
 }
 
 func (s *SongService) GetSongsFilteredPaginated(
 	ctx context.Context, filters *SongFilters,
-	pagination Pagination,
-) ([]Song, error) {
+	sort []SongSortOption, pagination Pagination,
+	fields SongFieldSet,
+) (*SongsPage, error) {
+
+	var songsPage *SongsPage
+	getPage := func(ctx context.Context) error {
+		var err error
+		songsPage, err = s.songRepository.GetSongsFilteredPaginated(
+			ctx, filters, sort, pagination, fields)
+		if err != nil || pagination.Count == CountModeNone {
+			return err
+		}
 
-	songs, err := s.songRepository.
-		GetSongsFilteredPaginated(ctx, filters, pagination)
+		total, ok := pagination.totalFromPage(len(songsPage.Songs))
+		if !ok {
+			songsPage.TotalEstimated = pagination.Count == CountModeEstimated
+			total, err = s.songRepository.CountSongsFiltered(
+				ctx, filters, songsPage.TotalEstimated)
+			if err != nil {
+				return errors.Wrap(err, "count songs")
+			}
+		}
+		songsPage.Total = &total
+		return nil
+	}
+
+	var err error
+	if pagination.Count == CountModeExact {
+		// Page and exact total are read from a single
+		// snapshot, so that they are consistent
+		err = s.txManager.InTx(ctx,
+			TxOptions{Isolation: TxIsolationRepeatableRead, ReadOnly: true},
+			getPage)
+	} else {
+		err = getPage(ctx)
+	}
 	if err != nil {
 		slogutils.Error(ctx, "get songs:", err)
 		return nil, ErrInternal
 	}
 
-	return songs, nil
+	if len(songsPage.Songs) == 0 && pagination.isFirstPage() &&
+		filters.isExactNameLookup() {
+
+		suggestion, err := s.songRepository.
+			GetSongSearchSuggestion(ctx, filters)
+		if err != nil {
+			// Suggestion is optional, so failing to get it
+			// should not fail the whole request
+			slogutils.Error(ctx, "get songs:",
+				errors.Wrap(err, "get search suggestion"))
+		} else {
+			songsPage.Suggestion = suggestion.withoutMatches(filters)
+		}
+	}
+
+	return songsPage, nil
+}
+
+// ExportSongs calls export for each filtered song ordered by ID,
+// export errors are returned as is
+func (s *SongService) ExportSongs(
+	ctx context.Context, filters *SongFilters,
+	export func(song *Song) error,
+) error {
+
+	var exportErr error
+	err := s.songRepository.ForEachSongFiltered(ctx, filters,
+		func(song *Song) error {
+			exportErr = export(song)
+			return exportErr
+		})
+	switch {
+	case exportErr != nil:
+		return exportErr
+	case err != nil:
+		slogutils.Error(ctx, "export songs:", err)
+		return ErrInternal
+	}
+
+	return nil
+}
+
+func (s *SongService) Autocomplete(
+	ctx context.Context, query string,
+	kind AutocompleteKind, limit int,
+) ([]AutocompleteSuggestion, error) {
+
+	suggestions, err := s.songRepository.
+		GetAutocompleteSuggestions(ctx, query, kind, limit)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return nil, ErrTimeout
+	case err != nil:
+		slogutils.Error(ctx, "autocomplete:", err)
+		return nil, ErrInternal
+	}
+
+	return suggestions, nil
+}
+
+func (s *SongService) GetSong(
+	ctx context.Context, songID ksuid.KSUID,
+) (*Song, error) {
+
+	song, err := s.songRepository.GetSongByID(ctx, songID)
+	switch {
+	case errors.Is(err, ErrSongNotFound):
+		return nil, err
+	case err != nil:
+		slogutils.Error(ctx, "get song:", err)
+		return nil, ErrInternal
+	}
+
+	return song, nil
 }
 
 func (s *SongService) UpdateSong(
 	ctx context.Context, songID ksuid.KSUID,
-	songUpdate *SongUpdate,
+	version *int64, songUpdate *SongUpdate,
 ) (*Song, error) {
 
+	songUpdate.normalize()
+	if err := songUpdate.validate(time.Now()); err != nil {
+		return nil, err
+	}
+
 	song, err := s.songRepository.
-		UpdateSong(ctx, songID, songUpdate)
-	if err != nil {
+		UpdateSong(ctx, songID, version, songUpdate)
+	switch {
+	case errors.Is(err, ErrSongNotFound),
+		errors.Is(err, ErrVersionMismatch):
+		return nil, err
+	case err != nil:
 		slogutils.Error(ctx, "update song:", err)
 		return nil, ErrInternal
 	}
@@ -166,15 +433,178 @@ func (s *SongService) UpdateSong(
 	return song, nil
 }
 
+func (s *SongService) PatchSong(
+	ctx context.Context, songID ksuid.KSUID,
+	version *int64, patch SongPatchFunc,
+) (*Song, error) {
+
+	song, err := s.songRepository.PatchSong(ctx, songID, version,
+		func(song *Song) (*SongUpdate, error) {
+			songUpdate, err := patch(song)
+			if err != nil {
+				return nil, err
+			}
+			songUpdate.normalize()
+			if err := songUpdate.validate(time.Now()); err != nil {
+				return nil, err
+			}
+			return songUpdate, nil
+		})
+	var validationErr *ValidationError
+	switch {
+	case errors.Is(err, ErrSongNotFound),
+		errors.Is(err, ErrVersionMismatch),
+		errors.Is(err, ErrInvalidPatch),
+		errors.Is(err, ErrPatchConflict),
+		errors.As(err, &validationErr):
+		return nil, err
+	case err != nil:
+		slogutils.Error(ctx, "patch song:", err)
+		return nil, ErrInternal
+	}
+
+	return song, nil
+}
+
 func (s *SongService) DeleteSong(
 	ctx context.Context, songID ksuid.KSUID,
+	version *int64,
 ) error {
 
-	err := s.songRepository.DeleteSong(ctx, songID)
-	if err != nil {
+	err := s.songRepository.DeleteSong(ctx, songID, version)
+	switch {
+	case errors.Is(err, ErrSongNotFound),
+		errors.Is(err, ErrVersionMismatch):
+		return err
+	case err != nil:
 		slogutils.Error(ctx, "delete song:", err)
 		return ErrInternal
 	}
 
 	return nil
 }
+
+// ApplySongBatch validates all operations before applying any of them.
+// Atomic batch is applied only if every operation succeeds, otherwise
+// operations are applied independently. Info of created songs is got
+// from the integration only once atomic batch is known to be valid
+func (s *SongService) ApplySongBatch(
+	ctx context.Context, operations []SongBatchOperation,
+	atomic bool,
+) (*SongBatchResult, error) {
+
+	now := time.Now()
+	items := make([]SongBatchItemResult, len(operations))
+	writes := make([]SongBatchWrite, len(operations))
+
+	type songKey struct{ name, musicGroupName string }
+	created := make(map[songKey]struct{})
+	for i, op := range operations {
+		writes[i] = SongBatchWrite{
+			Kind:    op.Kind,
+			SongID:  op.SongID,
+			Version: op.Version,
+			Update:  op.Update,
+		}
+		switch {
+		case op.Err != nil:
+			items[i].Err = op.Err
+		case op.Kind == SongBatchOperationCreate:
+			items[i].Err = s.checkNewSong(ctx, op.Create)
+			if items[i].Err != nil {
+				continue
+			}
+			key := songKey{op.Create.SongName, op.Create.MusicGroupName}
+			if _, ok := created[key]; ok {
+				items[i].Err = ErrSongAlreadyExists
+				continue
+			}
+			created[key] = struct{}{}
+		case op.Kind == SongBatchOperationUpdate:
+			op.Update.normalize()
+			items[i].Err = op.Update.validate(now)
+		}
+	}
+	if atomic && abortSongBatch(items) {
+		return &SongBatchResult{Items: items}, nil
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, s.batchConcurrency)
+	for i, op := range operations {
+		if op.Kind != SongBatchOperationCreate || items[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			writes[i].Song, items[i].Err = s.newSongWithInfo(ctx, op.Create)
+		}()
+	}
+	wg.Wait()
+	if atomic && abortSongBatch(items) {
+		return &SongBatchResult{Items: items}, nil
+	}
+
+	pending := make([]int, 0, len(operations))
+	for i := range items {
+		if items[i].Err == nil {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return &SongBatchResult{Committed: true, Items: items}, nil
+	}
+
+	pendingWrites := make([]SongBatchWrite, 0, len(pending))
+	for _, i := range pending {
+		pendingWrites = append(pendingWrites, writes[i])
+	}
+	results, err := s.songRepository.
+		ApplySongBatch(ctx, pendingWrites, atomic)
+	if err != nil {
+		slogutils.Error(ctx, "apply song batch:", err)
+		return nil, ErrInternal
+	}
+
+	committed := true
+	for j, i := range pending {
+		items[i] = results[j]
+		err := items[i].Err
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, ErrSongNotFound),
+			errors.Is(err, ErrVersionMismatch),
+			errors.Is(err, ErrSongAlreadyExists),
+			errors.Is(err, ErrBatchAborted):
+		default:
+			slogutils.Error(ctx, "apply song batch:", err)
+			items[i].Err = ErrInternal
+		}
+		if atomic {
+			committed = false
+		}
+	}
+
+	return &SongBatchResult{Committed: committed, Items: items}, nil
+}
+
+// abortSongBatch marks items without errors aborted if
+// any item has failed and tells whether batch is aborted
+func abortSongBatch(items []SongBatchItemResult) bool {
+	failed := slices.ContainsFunc(items, func(item SongBatchItemResult) bool {
+		return item.Err != nil
+	})
+	if !failed {
+		return false
+	}
+	for i := range items {
+		if items[i].Err == nil {
+			items[i].Err = ErrBatchAborted
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type batchSongRepository struct {
+	SongRepository
+	existing map[string]bool
+}
+
+func (r *batchSongRepository) SongExistsByNameAndMusicGroupName(
+	_ context.Context, songName, musicGroupName string,
+) (bool, error) {
+	return r.existing[musicGroupName+"/"+songName], nil
+}
+
+func TestApplySongBatchAtomic(t *testing.T) {
+	repo := &batchSongRepository{existing: map[string]bool{"Muse/Uprising": true}}
+	integration := &importSongInfoIntegration{}
+	service := NewSongService(repo, noTxManager{}, integration, 1)
+	create := func(songName string) SongBatchOperation {
+		return SongBatchOperation{
+			Kind:   SongBatchOperationCreate,
+			Create: &CreateSongDTO{SongName: songName, MusicGroupName: "Muse"},
+		}
+	}
+
+	result, err := service.ApplySongBatch(context.Background(), []SongBatchOperation{
+		create("Resistance"),
+		create("Uprising"),
+		create(" Resistance"),
+		create(""),
+	}, true)
+	require.NoError(t, err)
+	require.False(t, result.Committed)
+	require.ErrorIs(t, result.Items[0].Err, ErrBatchAborted)
+	require.ErrorIs(t, result.Items[1].Err, ErrSongAlreadyExists)
+	require.ErrorIs(t, result.Items[2].Err, ErrSongAlreadyExists)
+	require.ErrorAs(t, result.Items[3].Err, new(*ValidationError))
+	// Integration isn't called for the batch that is aborted anyway
+	require.Zero(t, integration.calls)
+
+	invalid := SongBatchOperation{
+		Kind: SongBatchOperationUpdate,
+		Err:  &ValidationError{Fields: []FieldError{{Field: SongFieldReleaseDate}}},
+	}
+	result, err = service.ApplySongBatch(context.Background(), []SongBatchOperation{
+		create("Resistance"),
+		invalid,
+	}, true)
+	require.NoError(t, err)
+	require.False(t, result.Committed)
+	require.ErrorIs(t, result.Items[0].Err, ErrBatchAborted)
+	require.ErrorAs(t, result.Items[1].Err, new(*ValidationError))
+}
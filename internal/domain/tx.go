@@ -0,0 +1,32 @@
+package domain
+
+import "context"
+
+// TxManager runs functions in transactions
+type TxManager interface {
+	// InTx runs fn in a transaction committed unless fn fails, fn errors
+	// are returned as is. Repository calls made with the context fn gets
+	// are part of the transaction, as well as nested InTx calls.
+	// Transactions failing on serialization are retried, so fn
+	// may be called several times
+	InTx(
+		ctx context.Context, opts TxOptions,
+		fn func(ctx context.Context) error,
+	) error
+}
+
+type TxOptions struct {
+	Isolation TxIsolation
+	ReadOnly  bool
+}
+
+type TxIsolation string
+
+const (
+	// TxIsolationDefault is the isolation
+	// transaction manager is configured with
+	TxIsolationDefault        TxIsolation = ""
+	TxIsolationReadCommitted  TxIsolation = "read committed"
+	TxIsolationRepeatableRead TxIsolation = "repeatable read"
+	TxIsolationSerializable   TxIsolation = "serializable"
+)
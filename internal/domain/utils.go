@@ -1,13 +1,195 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
+// TimeRange is open on the side of nil bound
 type TimeRange struct {
-	StartTime time.Time
-	EndTime   time.Time
+	StartTime          *time.Time
+	EndTime            *time.Time
+	StartTimeExclusive bool
+	EndTimeExclusive   bool
 }
 
 type Pagination struct {
 	Page    int
 	PerPage int
+	// Cursor switches pagination to keyset mode,
+	// Page is ignored then
+	Cursor *Cursor
+	Count  CountMode
+}
+
+// CountMode tells how to count the total number of items
+type CountMode string
+
+const (
+	CountModeExact CountMode = "exact"
+	// CountModeEstimated uses query planner estimate,
+	// which is cheap for large filtered result sets
+	CountModeEstimated CountMode = "estimated"
+	CountModeNone      CountMode = "none"
+)
+
+// Cursor points at the item next page starts after or, if
+// Backward is set, at the item previous page ends before.
+// Keys are values of the item sort keys, empty Keys point
+// at the start (or at the end if Backward is set)
+type Cursor struct {
+	Keys     []any
+	Backward bool
+}
+
+type MatchMode string
+
+const (
+	MatchModeExact  MatchMode = "exact"
+	MatchModePrefix MatchMode = "prefix"
+	MatchModeFuzzy  MatchMode = "fuzzy"
+)
+
+type AutocompleteKind string
+
+const (
+	AutocompleteKindSong  AutocompleteKind = "song"
+	AutocompleteKindGroup AutocompleteKind = "group"
+)
+
+type SongSortField string
+
+const (
+	SongSortFieldName        SongSortField = "name"
+	SongSortFieldGroupName   SongSortField = "group"
+	SongSortFieldReleaseDate SongSortField = "releaseDate"
+	SongSortFieldCreatedAt   SongSortField = "createdAt"
+	SongSortFieldUpdatedAt   SongSortField = "updatedAt"
+	// SongSortFieldRelevance orders by similarity to
+	// song and music group name filters
+	SongSortFieldRelevance SongSortField = "relevance"
+)
+
+// sortFieldSongFields maps sort fields to song
+// fields holding their values, relevance has none
+var sortFieldSongFields = map[SongSortField]SongField{
+	SongSortFieldName:        SongFieldName,
+	SongSortFieldGroupName:   SongFieldGroup,
+	SongSortFieldReleaseDate: SongFieldReleaseDate,
+	SongSortFieldCreatedAt:   SongFieldCreatedAt,
+	SongSortFieldUpdatedAt:   SongFieldUpdatedAt,
+}
+
+// SongField returns song field holding values of the sort field,
+// false is returned if they are computed rather than stored
+func (f SongSortField) SongField() (SongField, bool) {
+	field, ok := sortFieldSongFields[f]
+	return field, ok
+}
+
+type SongSortOption struct {
+	Field      SongSortField
+	Descending bool
+}
+
+func (p Pagination) isFirstPage() bool {
+	if p.Cursor != nil {
+		return len(p.Cursor.Keys) == 0 && !p.Cursor.Backward
+	}
+	return p.Page == 0
+}
+
+// totalFromPage returns the total number of items if it is
+// evident from the page length, so that counting can be skipped
+func (p Pagination) totalFromPage(pageLen int) (int, bool) {
+	if p.Cursor != nil || pageLen == 0 || pageLen >= p.PerPage {
+		return 0, false
+	}
+	return p.Page*p.PerPage + pageLen, true
+}
+
+type SongField string
+
+const (
+	SongFieldName        SongField = "name"
+	SongFieldGroup       SongField = "group"
+	SongFieldReleaseDate SongField = "releaseDate"
+	SongFieldLink        SongField = "link"
+	SongFieldCouplets    SongField = "couplets"
+	SongFieldCreatedAt   SongField = "createdAt"
+	SongFieldUpdatedAt   SongField = "updatedAt"
+)
+
+// SongFieldSet is a set of song fields to get besides ID,
+// nil set means all of them
+type SongFieldSet map[SongField]bool
+
+func (s SongFieldSet) Has(field SongField) bool {
+	return s == nil || s[field]
+}
+
+// DatePrecision tells which part of a date is known
+type DatePrecision string
+
+const (
+	DatePrecisionDay   DatePrecision = "day"
+	DatePrecisionMonth DatePrecision = "month"
+	DatePrecisionYear  DatePrecision = "year"
+)
+
+// PartialDate is a date known up to the precision,
+// Date is the first day of the period then
+type PartialDate struct {
+	Date      time.Time
+	Precision DatePrecision
+}
+
+// LastDay returns the last day of the period covered by the date
+func (d PartialDate) LastDay() time.Time {
+	switch d.Precision {
+	case DatePrecisionYear:
+		return d.Date.AddDate(1, 0, -1)
+	case DatePrecisionMonth:
+		return d.Date.AddDate(0, 1, -1)
+	default:
+		return d.Date
+	}
+}
+
+// PartialDateLayouts are time layouts of dates of each precision
+type PartialDateLayouts map[DatePrecision]string
+
+// ReleaseDateLayouts are formats release dates are
+// read and written in: YYYY-MM-DD, YYYY-MM or YYYY
+var ReleaseDateLayouts = PartialDateLayouts{
+	DatePrecisionDay:   time.DateOnly,
+	DatePrecisionMonth: "2006-01",
+	DatePrecisionYear:  "2006",
+}
+
+var datePrecisions = []DatePrecision{
+	DatePrecisionDay, DatePrecisionMonth, DatePrecisionYear,
+}
+
+// Parse parses date with the most precise layout that fits it
+func (l PartialDateLayouts) Parse(value string) (PartialDate, error) {
+	for _, precision := range datePrecisions {
+		layout, ok := l[precision]
+		if !ok {
+			continue
+		}
+		if date, err := time.Parse(layout, value); err == nil {
+			return PartialDate{Date: date, Precision: precision}, nil
+		}
+	}
+
+	return PartialDate{}, fmt.Errorf("date \"%s\" has invalid format", value)
+}
+
+func (l PartialDateLayouts) Format(d PartialDate) string {
+	layout, ok := l[d.Precision]
+	if !ok {
+		layout = l[DatePrecisionDay]
+	}
+	return d.Date.Format(layout)
 }
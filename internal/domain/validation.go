@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxNameLen        = 255
+	maxLinkLen        = 2048
+	maxCoupletLen     = 5000
+	maxCoupletsCount  = 200
+	minReleaseYear    = 1000
+	releaseDateFuture = 24 * time.Hour
+)
+
+var allowedLinkSchemes = []string{"http", "https"}
+
+// FieldError tells why the field value is invalid,
+// Index is set for elements of list fields
+type FieldError struct {
+	Field   SongField
+	Index   *int
+	Message string
+}
+
+// ValidationError lists all invalid fields of the input
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, fieldErr := range e.Fields {
+		field := string(fieldErr.Field)
+		if fieldErr.Index != nil {
+			field = fmt.Sprintf("%s[%d]", field, *fieldErr.Index)
+		}
+		messages = append(messages, field+": "+fieldErr.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Add(field SongField, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+func (e *ValidationError) addAt(field SongField, index int, message string) {
+	e.Fields = append(e.Fields, FieldError{
+		Field: field, Index: &index, Message: message})
+}
+
+// OrNil returns nil if there are no field errors
+func (e *ValidationError) OrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (dto *CreateSongDTO) normalize() {
+	dto.SongName = normalizeText(dto.SongName)
+	dto.MusicGroupName = normalizeText(dto.MusicGroupName)
+}
+
+func (dto *CreateSongDTO) validate() error {
+	var validationErr ValidationError
+	validateName(&validationErr, SongFieldName, dto.SongName)
+	validateName(&validationErr, SongFieldGroup, dto.MusicGroupName)
+
+	return validationErr.OrNil()
+}
+
+func (u *SongUpdate) normalize() {
+	if u.Name != nil {
+		name := normalizeText(*u.Name)
+		u.Name = &name
+	}
+	if u.Link != nil {
+		link := strings.TrimSpace(*u.Link)
+		u.Link = &link
+	}
+	if u.Couplets != nil {
+		couplets := make([]string, 0, len(*u.Couplets))
+		for _, couplet := range *u.Couplets {
+			couplets = append(couplets, normalizeText(couplet))
+		}
+		u.Couplets = &couplets
+	}
+}
+
+func (u *SongUpdate) validate(now time.Time) error {
+	var validationErr ValidationError
+	if u.Name != nil {
+		validateName(&validationErr, SongFieldName, *u.Name)
+	}
+	if u.ReleaseDate != nil {
+		validateReleaseDate(&validationErr, *u.ReleaseDate, now)
+	}
+	if u.Link != nil {
+		validateLink(&validationErr, *u.Link)
+	}
+	if u.Couplets != nil {
+		validateCouplets(&validationErr, *u.Couplets)
+	}
+
+	return validationErr.OrNil()
+}
+
+// normalizeText trims spaces and brings text to
+// NFC form, so that equal names are stored equally
+func normalizeText(text string) string {
+	return norm.NFC.String(strings.TrimSpace(text))
+}
+
+func validateName(validationErr *ValidationError, field SongField, name string) {
+	switch {
+	case name == "":
+		validationErr.Add(field, "should not be empty")
+	case !utf8.ValidString(name):
+		validationErr.Add(field, "should be valid UTF-8")
+	case utf8.RuneCountInString(name) > maxNameLen:
+		validationErr.Add(field, fmt.Sprintf(
+			"should be at most %d characters long", maxNameLen))
+	}
+}
+
+func validateReleaseDate(
+	validationErr *ValidationError, releaseDate PartialDate, now time.Time,
+) {
+	switch {
+	case releaseDate.Date.Year() < minReleaseYear:
+		validationErr.Add(SongFieldReleaseDate, fmt.Sprintf(
+			"should not be before year %d", minReleaseYear))
+	case releaseDate.Date.After(now.Add(releaseDateFuture)):
+		validationErr.Add(SongFieldReleaseDate, "should not be in the future")
+	}
+}
+
+// validateLink allows empty link, which means song has no link
+func validateLink(validationErr *ValidationError, link string) {
+	if link == "" {
+		return
+	}
+	if len(link) > maxLinkLen {
+		validationErr.Add(SongFieldLink, fmt.Sprintf(
+			"should be at most %d bytes long", maxLinkLen))
+		return
+	}
+
+	linkURL, err := url.Parse(link)
+	switch {
+	case err != nil || linkURL.Host == "":
+		validationErr.Add(SongFieldLink, "should be absolute URL")
+	case !slices.Contains(allowedLinkSchemes, strings.ToLower(linkURL.Scheme)):
+		validationErr.Add(SongFieldLink, fmt.Sprintf(
+			"scheme should be one of %s", strings.Join(allowedLinkSchemes, ", ")))
+	}
+}
+
+func validateCouplets(validationErr *ValidationError, couplets []string) {
+	switch {
+	case len(couplets) == 0:
+		validationErr.Add(SongFieldCouplets, "song should have at least one couplet")
+		return
+	case len(couplets) > maxCoupletsCount:
+		validationErr.Add(SongFieldCouplets, fmt.Sprintf(
+			"song should have at most %d couplets", maxCoupletsCount))
+		return
+	}
+
+	for i, couplet := range couplets {
+		switch {
+		case couplet == "":
+			validationErr.addAt(SongFieldCouplets, i, "should not be empty")
+		case !utf8.ValidString(couplet):
+			validationErr.addAt(SongFieldCouplets, i, "should be valid UTF-8")
+		case utf8.RuneCountInString(couplet) > maxCoupletLen:
+			validationErr.addAt(SongFieldCouplets, i, fmt.Sprintf(
+				"should be at most %d characters long", maxCoupletLen))
+		}
+	}
+}
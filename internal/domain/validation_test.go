@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSongUpdateValidation(t *testing.T) {
+	now := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	name := "  Café "
+	link := "ftp://example.com/song"
+	couplets := []string{"First couplet", " "}
+	songUpdate := SongUpdate{
+		Name: &name,
+		ReleaseDate: &PartialDate{
+			Date:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Precision: DatePrecisionYear,
+		},
+		Link:     &link,
+		Couplets: &couplets,
+	}
+
+	songUpdate.normalize()
+	require.Equal(t, "Café", *songUpdate.Name)
+
+	err := songUpdate.validate(now)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	index := 1
+	require.Equal(t, []FieldError{
+		{Field: SongFieldReleaseDate, Message: "should not be in the future"},
+		{Field: SongFieldLink, Message: "scheme should be one of http, https"},
+		{Field: SongFieldCouplets, Index: &index, Message: "should not be empty"},
+	}, validationErr.Fields)
+}
+
+func TestCreateSongDTOValidation(t *testing.T) {
+	dto := CreateSongDTO{SongName: " \t", MusicGroupName: "Muse"}
+	dto.normalize()
+
+	err := dto.validate()
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []FieldError{
+		{Field: SongFieldName, Message: "should not be empty"},
+	}, validationErr.Fields)
+}
@@ -0,0 +1,87 @@
+package filterexpr
+
+import "time"
+
+// Expr is a node of parsed filter expression:
+// And, Or, Not or Comparison
+type Expr interface {
+	expr()
+}
+
+type And struct {
+	Operands []Expr
+}
+
+type Or struct {
+	Operands []Expr
+}
+
+type Not struct {
+	Operand Expr
+}
+
+// Comparison compares song field with the value,
+// Value is string for text fields and time.Time for date ones
+type Comparison struct {
+	Field    Field
+	Operator Operator
+	Value    any
+	// DateOnly is set for time values given without time of day
+	DateOnly bool
+}
+
+func (And) expr()        {}
+func (Or) expr()         {}
+func (Not) expr()        {}
+func (Comparison) expr() {}
+
+type Field string
+
+const (
+	FieldSong        Field = "song"
+	FieldGroup       Field = "group"
+	FieldLink        Field = "link"
+	FieldText        Field = "text"
+	FieldReleaseDate Field = "releaseDate"
+	FieldCreatedAt   Field = "createdAt"
+	FieldUpdatedAt   Field = "updatedAt"
+)
+
+type Operator string
+
+const (
+	OperatorEq       Operator = ":"
+	OperatorContains Operator = "~"
+	OperatorGt       Operator = ">"
+	OperatorGtOrEq   Operator = ">="
+	OperatorLt       Operator = "<"
+	OperatorLtOrEq   Operator = "<="
+)
+
+type fieldSpec struct {
+	operators []Operator
+	isTime    bool
+}
+
+var (
+	textOperators = []Operator{OperatorEq, OperatorContains}
+	timeOperators = []Operator{
+		OperatorEq, OperatorGt, OperatorGtOrEq, OperatorLt, OperatorLtOrEq}
+)
+
+var fieldSpecs = map[Field]fieldSpec{
+	FieldSong:        {operators: textOperators},
+	FieldGroup:       {operators: textOperators},
+	FieldLink:        {operators: textOperators},
+	FieldText:        {operators: []Operator{OperatorContains}},
+	FieldReleaseDate: {operators: timeOperators, isTime: true},
+	FieldCreatedAt:   {operators: timeOperators, isTime: true},
+	FieldUpdatedAt:   {operators: timeOperators, isTime: true},
+}
+
+// fieldAliases are alternative names of fields
+var fieldAliases = map[string]Field{
+	"name": FieldSong,
+}
+
+const dateLayout = time.DateOnly
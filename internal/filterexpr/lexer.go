@@ -0,0 +1,122 @@
+package filterexpr
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// pos is the rune offset of the token in the input
+	pos int
+}
+
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of input"
+	}
+	if t.kind == tokenString {
+		return `"` + t.text + `"`
+	}
+	return t.text
+}
+
+func tokenize(input string) ([]token, error) {
+	runes := []rune(input)
+	tokens := make([]token, 0)
+	for pos := 0; pos < len(runes); {
+		r := runes[pos]
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			pos++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			pos++
+		case r == ':' || r == '~':
+			tokens = append(tokens, token{kind: tokenOperator, text: string(r), pos: pos})
+			pos++
+		case r == '<' || r == '>':
+			operator := string(r)
+			if pos+1 < len(runes) && runes[pos+1] == '=' {
+				operator += "="
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: pos})
+			pos += len(operator)
+		case r == '"':
+			text, end, err := readString(runes, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: pos})
+			pos = end
+		default:
+			// Values may have colons, as RFC 3339 times do
+			isValue := len(tokens) > 0 &&
+				tokens[len(tokens)-1].kind == tokenOperator
+			start := pos
+			for pos < len(runes) &&
+				(isWordRune(runes[pos]) || isValue && runes[pos] == ':') {
+				pos++
+			}
+			if pos == start {
+				return nil, &SyntaxError{
+					Pos:     pos,
+					Token:   string(r),
+					Message: "unexpected character",
+				}
+			}
+			tokens = append(tokens, token{
+				kind: tokenWord, text: string(runes[start:pos]), pos: start})
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// readString reads double quoted string starting at pos,
+// backslash escapes the next character
+func readString(runes []rune, pos int) (string, int, error) {
+	var text strings.Builder
+	for i := pos + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				text.WriteRune(runes[i])
+			}
+		case '"':
+			return text.String(), i + 1, nil
+		default:
+			text.WriteRune(runes[i])
+		}
+	}
+
+	return "", 0, &SyntaxError{
+		Pos:     pos,
+		Token:   string(runes[pos:]),
+		Message: "unterminated string",
+	}
+}
+
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()":~<>=`, r)
+}
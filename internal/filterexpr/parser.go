@@ -0,0 +1,232 @@
+// Package filterexpr parses song filter expressions like
+//
+//	group:"Muse" AND (releaseDate>=2010-01-01 OR song~love) AND NOT text~"war"
+//
+// Comparisons are joined with AND, OR and NOT (case insensitive),
+// NOT binds tighter than AND, which binds tighter than OR.
+// Operators are ":" for equality, "~" for substring match and
+// ">", ">=", "<", "<=" for dates, which are YYYY-MM-DD or
+// RFC 3339 times. Songs have no tags, so there is no tag field.
+package filterexpr
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	MaxExpressionLen   = 1000
+	maxExpressionDepth = 32
+)
+
+// SyntaxError points at the token the expression can't be parsed at
+type SyntaxError struct {
+	// Pos is the offset of the token in runes, starting from 0
+	Pos     int
+	Token   string
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d near %s", e.Message, e.Pos+1, e.Token)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// Parse parses and validates filter expression
+func Parse(input string) (Expr, error) {
+	if len([]rune(input)) > MaxExpressionLen {
+		return nil, fmt.Errorf(
+			"expression is longer than %d characters", MaxExpressionLen)
+	}
+
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, p.errorAt(next, "unexpected token")
+	}
+
+	return expr, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	operand, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Expr{operand}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		operand, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return operand, nil
+	}
+
+	return Or{Operands: operands}, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Expr{operand}
+	for p.peek().isKeyword("AND") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return operand, nil
+	}
+
+	return And{Operands: operands}, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if !p.peek().isKeyword("NOT") {
+		return p.parsePrimary()
+	}
+
+	notToken := p.next()
+	if err := p.enter(notToken); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	return Not{Operand: operand}, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	next := p.peek()
+	switch {
+	case next.kind == tokenLParen:
+		p.next()
+		if err := p.enter(next); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.errorAt(closing, `expected ")"`)
+		}
+		return expr, nil
+	case next.kind == tokenWord &&
+		!next.isKeyword("AND") && !next.isKeyword("OR"):
+		return p.parseComparison()
+	default:
+		return nil, p.errorAt(next, "expected comparison")
+	}
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	fieldToken := p.next()
+	field := Field(fieldToken.text)
+	if alias, ok := fieldAliases[fieldToken.text]; ok {
+		field = alias
+	}
+	spec, ok := fieldSpecs[field]
+	if !ok {
+		return nil, p.errorAt(fieldToken, "unknown field")
+	}
+
+	operatorToken := p.next()
+	if operatorToken.kind != tokenOperator {
+		return nil, p.errorAt(operatorToken, "expected operator")
+	}
+	operator := Operator(operatorToken.text)
+	if !slices.Contains(spec.operators, operator) {
+		return nil, p.errorAt(operatorToken, fmt.Sprintf(
+			"operator is not supported by field %s", field))
+	}
+
+	valueToken := p.next()
+	if valueToken.kind != tokenWord && valueToken.kind != tokenString {
+		return nil, p.errorAt(valueToken, "expected value")
+	}
+
+	comparison := Comparison{
+		Field:    field,
+		Operator: operator,
+		Value:    valueToken.text,
+	}
+	if spec.isTime {
+		value, dateOnly, err := parseTime(valueToken.text)
+		if err != nil {
+			return nil, p.errorAt(valueToken,
+				"expected date YYYY-MM-DD or RFC 3339 time")
+		}
+		comparison.Value = value
+		comparison.DateOnly = dateOnly
+	}
+
+	return comparison, nil
+}
+
+func parseTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) enter(t token) error {
+	p.depth++
+	if p.depth > maxExpressionDepth {
+		return p.errorAt(t, "expression is nested too deep")
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) errorAt(t token, message string) error {
+	return &SyntaxError{Pos: t.pos, Token: t.String(), Message: message}
+}
@@ -0,0 +1,68 @@
+package filterexpr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	expr, err := Parse(
+		`group:"Muse" AND (releaseDate>=2010-01-01 OR song~love) AND NOT text~"war"`)
+	require.NoError(t, err)
+	require.Equal(t, And{Operands: []Expr{
+		Comparison{Field: FieldGroup, Operator: OperatorEq, Value: "Muse"},
+		Or{Operands: []Expr{
+			Comparison{
+				Field:    FieldReleaseDate,
+				Operator: OperatorGtOrEq,
+				Value:    time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC),
+				DateOnly: true,
+			},
+			Comparison{Field: FieldSong, Operator: OperatorContains, Value: "love"},
+		}},
+		Not{Operand: Comparison{
+			Field: FieldText, Operator: OperatorContains, Value: "war"}},
+	}}, expr)
+}
+
+func TestParseTime(t *testing.T) {
+	expr, err := Parse(`createdAt>=2024-01-01T10:00:00+03:00 AND link:x:y`)
+	require.NoError(t, err)
+	require.Equal(t, And{Operands: []Expr{
+		Comparison{
+			Field:    FieldCreatedAt,
+			Operator: OperatorGtOrEq,
+			Value: time.Date(2024, 1, 1, 10, 0, 0, 0,
+				time.FixedZone("", 3*60*60)),
+		},
+		Comparison{Field: FieldLink, Operator: OperatorEq, Value: "x:y"},
+	}}, expr)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		token string
+	}{
+		{`group:Muse AND genre:rock`, 15, "genre"},
+		{`group:Muse AND`, 14, "end of input"},
+		{`(group:Muse`, 11, "end of input"},
+		{`group:Muse)`, 10, ")"},
+		{`text:love`, 4, ":"},
+		{`releaseDate>2010-13-01`, 12, "2010-13-01"},
+		{`song:"unterminated`, 5, `"unterminated`},
+		{`group=Muse`, 5, "="},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := Parse(test.input)
+			var syntaxErr *SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			require.Equal(t, test.pos, syntaxErr.Pos)
+			require.Equal(t, test.token, syntaxErr.Token)
+		})
+	}
+}
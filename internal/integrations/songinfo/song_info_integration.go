@@ -1,13 +1,13 @@
 package songinfo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"song-lib/internal/config"
 	"song-lib/internal/domain"
-	"time"
 
 	"github.com/pkg/errors"
 )
@@ -27,10 +27,14 @@ func NewSongInfoIntegration(
 	}
 }
 
-const (
-	songInfoAPIPath          = "/info"
-	songInfoReleseDateLayout = "02.01.2006"
-)
+const songInfoAPIPath = "/info"
+
+// Release dates may be known to month or year only
+var songInfoReleaseDateLayouts = domain.PartialDateLayouts{
+	domain.DatePrecisionDay:   "02.01.2006",
+	domain.DatePrecisionMonth: "01.2006",
+	domain.DatePrecisionYear:  "2006",
+}
 
 var newError = func(err error) domain.SongInfoIntegrationError {
 	return domain.SongInfoIntegrationError(err)
@@ -78,8 +82,30 @@ func (i *SongInfoIntegration) GetSongInfo(
 	return songInfo, nil
 }
 
+// Ping fails if the API can't be reached or has server error.
+// Song info path is requested without query, so that any response
+// but 5xx means the API is up, even if it rejects the request
+func (i *SongInfoIntegration) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodHead, i.songInfoURL, nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "make request")
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errors.Errorf("response status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
 func (b *songInfoResponseBody) toDomainSongInfo() (*domain.IntegrationSongInfo, error) {
-	releaseDate, err := time.Parse(songInfoReleseDateLayout, b.ReleaseDate)
+	releaseDate, err := songInfoReleaseDateLayouts.Parse(b.ReleaseDate)
 	if err != nil {
 		return nil, errors.Wrap(err, "parse release date")
 	}
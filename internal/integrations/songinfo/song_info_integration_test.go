@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"song-lib/internal/config"
+	"song-lib/internal/domain"
 	"strings"
 	"testing"
 	"time"
@@ -19,7 +20,7 @@ func TestGetSongInfoSuccess(t *testing.T) {
 	songText := "Ooh baby, don't you know I suffer?\nOoh baby, can you hear me moan?\nYou caught me under false pretenses\nHow long before you let me go?\n\nOoh\nYou set my soul alight\nOoh\nYou set my soul alight"
 	songLink := "https://www.youtube.com/watch?v=Xsp3_a-PMTw"
 	songReleaseDateStr := "16.07.2006"
-	songReleaseDate, err := time.Parse(songInfoReleseDateLayout, songReleaseDateStr)
+	songReleaseDate, err := time.Parse("02.01.2006", songReleaseDateStr)
 	require.NoError(t, err)
 
 	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
@@ -65,8 +66,27 @@ func TestGetSongInfoSuccess(t *testing.T) {
 	songInfo, err := songInfoIntegration.GetSongInfo(songName, musicGroupName)
 	require.NoError(t, err)
 	require.NotNil(t, songInfo)
-	require.Equal(t, songReleaseDate, songInfo.ReleaseDate)
+	require.Equal(t, domain.PartialDate{
+		Date:      songReleaseDate,
+		Precision: domain.DatePrecisionDay,
+	}, songInfo.ReleaseDate)
 	require.Equal(t, songText, songInfo.Text)
 	require.Equal(t, songLink, songInfo.Link)
 
 }
+
+func TestSongInfoPartialReleaseDate(t *testing.T) {
+	for releaseDate, precision := range map[string]domain.DatePrecision{
+		"07.2006": domain.DatePrecisionMonth,
+		"2006":    domain.DatePrecisionYear,
+	} {
+		respBody := songInfoResponseBody{ReleaseDate: releaseDate}
+		songInfo, err := respBody.toDomainSongInfo()
+		require.NoError(t, err)
+		require.Equal(t, precision, songInfo.ReleaseDate.Precision)
+	}
+
+	respBody := songInfoResponseBody{ReleaseDate: "2006-07-16"}
+	_, err := respBody.toDomainSongInfo()
+	require.Error(t, err)
+}
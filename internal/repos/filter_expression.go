@@ -0,0 +1,179 @@
+package repos
+
+import (
+	"song-lib/internal/domain"
+	"song-lib/internal/filterexpr"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+)
+
+var filterExpressionColumns = map[filterexpr.Field]string{
+	filterexpr.FieldSong:        "s.name",
+	filterexpr.FieldGroup:       "mg.name",
+	filterexpr.FieldLink:        "s.link",
+	filterexpr.FieldText:        "sc.text",
+	filterexpr.FieldReleaseDate: "s.release_date",
+	filterexpr.FieldCreatedAt:   "s.created_at",
+	filterexpr.FieldUpdatedAt:   "s.updated_at",
+}
+
+var filterExpressionOperators = map[filterexpr.Operator]string{
+	filterexpr.OperatorEq:     "=",
+	filterexpr.OperatorGt:     ">",
+	filterexpr.OperatorGtOrEq: ">=",
+	filterexpr.OperatorLt:     "<",
+	filterexpr.OperatorLtOrEq: "<=",
+}
+
+// sqlDialect builds conditions databases express differently
+type sqlDialect interface {
+	// containsCondition matches column values
+	// containing text in any letter case
+	containsCondition(column, text string) sq.Sqlizer
+	// utcDate converts timestamp column to date in UTC
+	utcDate(column string) string
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) containsCondition(column, text string) sq.Sqlizer {
+	return sq.Expr(column+" ILIKE '%' || escape_like_string(?) || '%'", text)
+}
+
+func (postgresDialect) utcDate(column string) string {
+	return "(" + column + " AT TIME ZONE 'UTC')::date"
+}
+
+// compileFilterExpression converts filter expression
+// into condition on songs joined with music groups
+func compileFilterExpression(
+	expr filterexpr.Expr, dialect sqlDialect,
+) (sq.Sqlizer, error) {
+	switch expr := expr.(type) {
+	case filterexpr.And:
+		conditions, err := compileFilterExpressions(expr.Operands, dialect)
+		if err != nil {
+			return nil, err
+		}
+		return sq.And(conditions), nil
+	case filterexpr.Or:
+		conditions, err := compileFilterExpressions(expr.Operands, dialect)
+		if err != nil {
+			return nil, err
+		}
+		return sq.Or(conditions), nil
+	case filterexpr.Not:
+		condition, err := compileFilterExpression(expr.Operand, dialect)
+		if err != nil {
+			return nil, err
+		}
+		return sq.Expr("NOT (?)", condition), nil
+	case filterexpr.Comparison:
+		return compileFilterComparison(expr, dialect)
+	default:
+		return nil, errors.Errorf("unknown expression %T", expr)
+	}
+}
+
+func compileFilterExpressions(
+	exprs []filterexpr.Expr, dialect sqlDialect,
+) ([]sq.Sqlizer, error) {
+	conditions := make([]sq.Sqlizer, 0, len(exprs))
+	for _, expr := range exprs {
+		condition, err := compileFilterExpression(expr, dialect)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+func compileFilterComparison(
+	c filterexpr.Comparison, dialect sqlDialect,
+) (sq.Sqlizer, error) {
+	column, ok := filterExpressionColumns[c.Field]
+	if !ok {
+		return nil, errors.Errorf("unknown field %s", c.Field)
+	}
+
+	var condition sq.Sqlizer
+	switch value := c.Value.(type) {
+	case string:
+		switch {
+		case c.Field == filterexpr.FieldLink &&
+			c.Operator == filterexpr.OperatorEq:
+			condition = linkEqCondition(value)
+		case c.Field == filterexpr.FieldLink &&
+			c.Operator == filterexpr.OperatorContains:
+			condition = dialect.containsCondition("COALESCE(s.link, '')", value)
+		case c.Operator == filterexpr.OperatorEq:
+			condition = sq.Eq{column: value}
+		case c.Operator == filterexpr.OperatorContains:
+			condition = dialect.containsCondition(column, value)
+		}
+	case time.Time:
+		operator, ok := filterExpressionOperators[c.Operator]
+		if !ok {
+			break
+		}
+		if c.Field == filterexpr.FieldReleaseDate {
+			condition = releaseDateComparison(c.Operator, value)
+			break
+		}
+		// Timestamps are compared by UTC date if time of day is not given
+		if c.DateOnly {
+			column = dialect.utcDate(column)
+		}
+		condition = sq.Expr(column+" "+operator+" ?", value)
+	}
+	if condition == nil {
+		return nil, errors.Errorf(
+			"unsupported comparison %s%s%v", c.Field, c.Operator, c.Value)
+	}
+
+	if c.Field == filterexpr.FieldText {
+		return inConditionWithSubquery("s.id", sq.
+			Select("sc.song_id").
+			From("song_couplets sc").
+			Where(condition)), nil
+	}
+
+	return condition, nil
+}
+
+// releaseDateComparison matches songs with release date period
+// overlapping dates the comparison is true for
+func releaseDateComparison(
+	operator filterexpr.Operator, value time.Time,
+) sq.Sqlizer {
+	switch operator {
+	case filterexpr.OperatorEq:
+		return timeRangeCondition(
+			"s.release_date", "s.release_date_last_day",
+			&domain.TimeRange{StartTime: &value, EndTime: &value})
+	case filterexpr.OperatorGt:
+		return sq.Gt{"s.release_date_last_day": value}
+	case filterexpr.OperatorGtOrEq:
+		return sq.GtOrEq{"s.release_date_last_day": value}
+	case filterexpr.OperatorLt:
+		return sq.Lt{"s.release_date": value}
+	case filterexpr.OperatorLtOrEq:
+		return sq.LtOrEq{"s.release_date": value}
+	default:
+		return nil
+	}
+}
+
+// linkEqCondition matches songs having the link. Missing link is NULL
+// in Postgres and empty in SQLite, empty link matches it in both. The
+// condition is never NULL, so that its negation matches songs without
+// link, and link equality can still use the index
+func linkEqCondition(link string) sq.Sqlizer {
+	if link == "" {
+		return sq.Expr("(s.link IS NULL OR s.link = '')")
+	}
+	return sq.Expr("(s.link = ? AND s.link IS NOT NULL)", link)
+}
@@ -0,0 +1,247 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"song-lib/internal/domain"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type IdempotencyRepository struct {
+	db *sqlx.DB
+}
+
+type idempotencyRecord struct {
+	Key             string  `db:"key"`
+	Fingerprint     string  `db:"fingerprint"`
+	ResponseStatus  *int    `db:"response_status"`
+	ResponseHeaders *[]byte `db:"response_headers"`
+	ResponseBody    *[]byte `db:"response_body"`
+}
+
+// maxIdempotencyClaimAttempts limits claim attempts of a key
+// released by concurrent requests between claim and read
+const maxIdempotencyClaimAttempts = 3
+
+// ClaimIdempotencyKey stores the key for the request leased for lease
+// unless there is a record of it already. Records older than ttl are
+// replaced, as are ones of the same request whose lease has expired
+// without response, so that the request can be retried if the process
+// processing it has died. It returns nil record if key was claimed
+// and the existing record otherwise. Claimed key is owned by token,
+// only the owner can renew the lease, save the response or release it
+func (r *IdempotencyRepository) ClaimIdempotencyKey(
+	ctx context.Context, key, fingerprint, token string, ttl, lease time.Duration,
+) (*domain.IdempotencyRecord, error) {
+	for range maxIdempotencyClaimAttempts {
+		claimed, err := r.claimIdempotencyKey(
+			ctx, key, fingerprint, token, ttl, lease)
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			return nil, nil
+		}
+
+		query, args, err := sq.
+			Select("key", "fingerprint", "response_status",
+				"response_headers", "response_body").
+			From("idempotency_keys").
+			Where(sq.Eq{"key": key}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return nil, errors.Wrap(err, "select key: build query")
+		}
+
+		var recordModel idempotencyRecord
+		err = r.db.GetContext(ctx, &recordModel, query, args...)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// Key was released after the claim attempt,
+			// so it's claimed on the next one
+			continue
+		case err != nil:
+			return nil, errors.Wrap(err, "select key: execute query")
+		}
+
+		return recordModel.toEntity()
+	}
+
+	return nil, errors.Errorf(
+		"key is released concurrently %d times", maxIdempotencyClaimAttempts)
+}
+
+func (r *IdempotencyRepository) claimIdempotencyKey(
+	ctx context.Context, key, fingerprint, token string, ttl, lease time.Duration,
+) (bool, error) {
+	query := `
+	INSERT INTO idempotency_keys AS k (key, fingerprint, claim_token, locked_until)
+	VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+	ON CONFLICT (key) DO UPDATE SET
+		fingerprint = EXCLUDED.fingerprint,
+		response_status = NULL,
+		response_headers = NULL,
+		response_body = NULL,
+		created_at = now(),
+		claim_token = EXCLUDED.claim_token,
+		locked_until = EXCLUDED.locked_until
+	WHERE
+		k.created_at < now() - make_interval(secs => $5) OR (
+			k.response_status IS NULL AND k.locked_until < now() AND
+			k.fingerprint = EXCLUDED.fingerprint)`
+
+	res, err := r.db.ExecContext(ctx, query,
+		key, fingerprint, token, lease.Seconds(), ttl.Seconds())
+	if err != nil {
+		return false, errors.Wrap(err, "claim key: execute query")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "claim key: get rows affected")
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// DeleteExpiredIdempotencyKeys deletes keys older than ttl
+// and returns the number of deleted ones
+func (r *IdempotencyRepository) DeleteExpiredIdempotencyKeys(
+	ctx context.Context, ttl time.Duration,
+) (int64, error) {
+	query, args, err := sq.
+		Delete("idempotency_keys").
+		Where(sq.Expr("created_at < now() - make_interval(secs => ?)",
+			ttl.Seconds())).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "build query")
+	}
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "execute query")
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "get rows affected")
+	}
+
+	return deleted, nil
+}
+
+// RenewIdempotencyKey extends the lease of the key owned by
+// token, so that it's not claimed again while being processed.
+// It fails with ErrIdempotencyKeyLost if the key is not owned
+func (r *IdempotencyRepository) RenewIdempotencyKey(
+	ctx context.Context, key, token string, lease time.Duration,
+) error {
+	query, args, err := sq.
+		Update("idempotency_keys").
+		Set("locked_until", sq.Expr("now() + make_interval(secs => ?)",
+			lease.Seconds())).
+		Where(sq.Eq{"key": key, "claim_token": token, "response_status": nil}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	return execOwnedKeyQuery(ctx, r.db, query, args)
+}
+
+// SaveIdempotentResponse stores the response of the request owning
+// the key by token, it fails with ErrIdempotencyKeyLost otherwise
+func (r *IdempotencyRepository) SaveIdempotentResponse(
+	ctx context.Context, key, token string, response *domain.IdempotentResponse,
+) error {
+	headers, err := json.Marshal(response.Headers)
+	if err != nil {
+		return errors.Wrap(err, "marshal headers")
+	}
+
+	query, args, err := sq.
+		Update("idempotency_keys").
+		Set("response_status", response.StatusCode).
+		Set("response_headers", headers).
+		Set("response_body", response.Body).
+		Where(sq.Eq{"key": key, "claim_token": token}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	return execOwnedKeyQuery(ctx, r.db, query, args)
+}
+
+// ReleaseIdempotencyKey deletes the key owned by token, so that the
+// request can be retried with it. It fails with ErrIdempotencyKeyLost
+// if the key is not owned
+func (r *IdempotencyRepository) ReleaseIdempotencyKey(
+	ctx context.Context, key, token string,
+) error {
+	query, args, err := sq.
+		Delete("idempotency_keys").
+		Where(sq.Eq{"key": key, "claim_token": token}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	return execOwnedKeyQuery(ctx, r.db, query, args)
+}
+
+// execOwnedKeyQuery executes query changing the key of its owner,
+// no rows affected means the key is not owned anymore
+func execOwnedKeyQuery(
+	ctx context.Context, db *sqlx.DB, query string, args []any,
+) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "execute query")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "get rows affected")
+	}
+	if rowsAffected == 0 {
+		return domain.ErrIdempotencyKeyLost
+	}
+
+	return nil
+}
+
+func (r *idempotencyRecord) toEntity() (*domain.IdempotencyRecord, error) {
+	record := domain.IdempotencyRecord{
+		Key:         r.Key,
+		Fingerprint: r.Fingerprint,
+	}
+	if r.ResponseStatus == nil {
+		return &record, nil
+	}
+
+	record.Response = &domain.IdempotentResponse{StatusCode: *r.ResponseStatus}
+	if r.ResponseHeaders != nil {
+		err := json.Unmarshal(*r.ResponseHeaders, &record.Response.Headers)
+		if err != nil {
+			return nil, errors.Wrap(err, "unmarshal response headers")
+		}
+	}
+	if r.ResponseBody != nil {
+		record.Response.Body = *r.ResponseBody
+	}
+
+	return &record, nil
+}
+
+func NewIdempotencyRepository(db *sqlx.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
@@ -0,0 +1,134 @@
+package repos
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"song-lib/internal/config"
+	"song-lib/internal/db/postgres"
+	"song-lib/internal/db/sqlite"
+	"song-lib/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type idempotencyRepository interface {
+	ClaimIdempotencyKey(
+		ctx context.Context, key, fingerprint, token string, ttl, lease time.Duration,
+	) (*domain.IdempotencyRecord, error)
+	RenewIdempotencyKey(ctx context.Context, key, token string, lease time.Duration) error
+	SaveIdempotentResponse(
+		ctx context.Context, key, token string, response *domain.IdempotentResponse,
+	) error
+	ReleaseIdempotencyKey(ctx context.Context, key, token string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error)
+}
+
+func TestIdempotencyRepository(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, postgres.MigrateUp(context.Background(), db))
+	_, err = db.Exec(`TRUNCATE idempotency_keys`)
+	require.NoError(t, err)
+
+	testIdempotencyRepository(t, NewIdempotencyRepository(db))
+}
+
+func TestSQLiteIdempotencyRepository(t *testing.T) {
+	db, err := sqlite.NewClient(config.SQLiteConfig{
+		Path: filepath.Join(t.TempDir(), "song-lib.db")})
+	if errors.Is(err, sqlite.ErrFTS5Unavailable) {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, sqlite.MigrateUp(db))
+
+	testIdempotencyRepository(t, NewSQLiteIdempotencyRepository(db))
+}
+
+func TestMemoryIdempotencyRepository(t *testing.T) {
+	testIdempotencyRepository(t, NewMemoryIdempotencyRepository())
+}
+
+func testIdempotencyRepository(t *testing.T, repo idempotencyRepository) {
+	ctx := context.Background()
+	const ttl = time.Hour
+	response := &domain.IdempotentResponse{StatusCode: 201, Body: []byte("{}")}
+
+	// Pending key is not claimed again while its lease lasts
+	record, err := repo.ClaimIdempotencyKey(ctx, "leased", "a", "1", ttl, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, record)
+	record, err = repo.ClaimIdempotencyKey(ctx, "leased", "a", "2", ttl, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Nil(t, record.Response)
+
+	// Pending key is taken over by the same request once its lease expires
+	record, err = repo.ClaimIdempotencyKey(ctx, "expired", "a", "1", ttl, -time.Second)
+	require.NoError(t, err)
+	require.Nil(t, record)
+	record, err = repo.ClaimIdempotencyKey(ctx, "expired", "b", "2", ttl, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Equal(t, "a", record.Fingerprint)
+	record, err = repo.ClaimIdempotencyKey(ctx, "expired", "a", "3", ttl, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	// Stale request whose key is taken over changes nothing
+	require.ErrorIs(t, repo.RenewIdempotencyKey(ctx, "expired", "1", time.Hour),
+		domain.ErrIdempotencyKeyLost)
+	require.ErrorIs(t, repo.SaveIdempotentResponse(ctx, "expired", "1", response),
+		domain.ErrIdempotencyKeyLost)
+	require.ErrorIs(t, repo.ReleaseIdempotencyKey(ctx, "expired", "1"),
+		domain.ErrIdempotencyKeyLost)
+	record, err = repo.ClaimIdempotencyKey(ctx, "expired", "a", "4", ttl, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Nil(t, record.Response)
+
+	// Renewed lease is not taken over
+	record, err = repo.ClaimIdempotencyKey(ctx, "renewed", "a", "1", ttl, -time.Second)
+	require.NoError(t, err)
+	require.Nil(t, record)
+	require.NoError(t, repo.RenewIdempotencyKey(ctx, "renewed", "1", time.Hour))
+	record, err = repo.ClaimIdempotencyKey(ctx, "renewed", "a", "2", ttl, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+
+	// Key with response is not taken over after its lease
+	record, err = repo.ClaimIdempotencyKey(ctx, "done", "a", "1", ttl, -time.Second)
+	require.NoError(t, err)
+	require.Nil(t, record)
+	require.NoError(t, repo.SaveIdempotentResponse(ctx, "done", "1", response))
+	record, err = repo.ClaimIdempotencyKey(ctx, "done", "a", "2", ttl, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.NotNil(t, record.Response)
+	require.Equal(t, 201, record.Response.StatusCode)
+
+	// Released key is claimed again
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, "expired", "3"))
+	record, err = repo.ClaimIdempotencyKey(ctx, "expired", "a", "5", ttl, time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	// Keys older than ttl are deleted
+	deleted, err := repo.DeleteExpiredIdempotencyKeys(ctx, ttl)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+	deleted, err = repo.DeleteExpiredIdempotencyKeys(ctx, -time.Second)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, deleted)
+}
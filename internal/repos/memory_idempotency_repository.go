@@ -0,0 +1,142 @@
+package repos
+
+import (
+	"context"
+	"maps"
+	"song-lib/internal/domain"
+	"sync"
+	"time"
+)
+
+// MemoryIdempotencyRepository keeps idempotency keys in memory,
+// so they are lost on restart
+type MemoryIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[string]*memoryIdempotencyRecord
+}
+
+type memoryIdempotencyRecord struct {
+	record      domain.IdempotencyRecord
+	token       string
+	createdAt   time.Time
+	lockedUntil time.Time
+}
+
+// ClaimIdempotencyKey behaves as IdempotencyRepository.ClaimIdempotencyKey
+func (r *MemoryIdempotencyRepository) ClaimIdempotencyKey(
+	_ context.Context, key, fingerprint, token string, ttl, lease time.Duration,
+) (*domain.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	existing, ok := r.records[key]
+	if !ok || existing.expired(now, ttl, fingerprint) {
+		r.records[key] = &memoryIdempotencyRecord{
+			record: domain.IdempotencyRecord{
+				Key:         key,
+				Fingerprint: fingerprint,
+			},
+			token:       token,
+			createdAt:   now,
+			lockedUntil: now.Add(lease),
+		}
+		return nil, nil
+	}
+
+	record := existing.record
+	if record.Response != nil {
+		response := *record.Response
+		response.Headers = maps.Clone(response.Headers)
+		record.Response = &response
+	}
+	return &record, nil
+}
+
+// expired tells whether the record is older than ttl or is the one
+// of the request with the given fingerprint whose lease has expired
+func (r *memoryIdempotencyRecord) expired(
+	now time.Time, ttl time.Duration, fingerprint string,
+) bool {
+	if r.createdAt.Before(now.Add(-ttl)) {
+		return true
+	}
+	return r.record.Response == nil &&
+		r.record.Fingerprint == fingerprint && r.lockedUntil.Before(now)
+}
+
+// DeleteExpiredIdempotencyKeys behaves as
+// IdempotencyRepository.DeleteExpiredIdempotencyKeys
+func (r *MemoryIdempotencyRepository) DeleteExpiredIdempotencyKeys(
+	_ context.Context, ttl time.Duration,
+) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	expiredBefore := time.Now().Add(-ttl)
+	for key, record := range r.records {
+		if record.createdAt.Before(expiredBefore) {
+			delete(r.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// RenewIdempotencyKey behaves as IdempotencyRepository.RenewIdempotencyKey
+func (r *MemoryIdempotencyRepository) RenewIdempotencyKey(
+	_ context.Context, key, token string, lease time.Duration,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.records[key]
+	if !ok || record.token != token || record.record.Response != nil {
+		return domain.ErrIdempotencyKeyLost
+	}
+	record.lockedUntil = time.Now().Add(lease)
+	return nil
+}
+
+// SaveIdempotentResponse behaves as
+// IdempotencyRepository.SaveIdempotentResponse
+func (r *MemoryIdempotencyRepository) SaveIdempotentResponse(
+	_ context.Context, key, token string, response *domain.IdempotentResponse,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.records[key]
+	if !ok || record.token != token {
+		return domain.ErrIdempotencyKeyLost
+	}
+	record.record.Response = &domain.IdempotentResponse{
+		StatusCode: response.StatusCode,
+		Headers:    maps.Clone(response.Headers),
+		Body:       append([]byte(nil), response.Body...),
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey behaves as
+// IdempotencyRepository.ReleaseIdempotencyKey
+func (r *MemoryIdempotencyRepository) ReleaseIdempotencyKey(
+	_ context.Context, key, token string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.records[key]
+	if !ok || record.token != token {
+		return domain.ErrIdempotencyKeyLost
+	}
+	delete(r.records, key)
+	return nil
+}
+
+func NewMemoryIdempotencyRepository() *MemoryIdempotencyRepository {
+	return &MemoryIdempotencyRepository{
+		records: make(map[string]*memoryIdempotencyRecord),
+	}
+}
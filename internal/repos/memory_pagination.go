@@ -0,0 +1,110 @@
+package repos
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"song-lib/internal/domain"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// memorySortKey is the in-memory counterpart of sortKey,
+// value returns the key value of a row
+type memorySortKey[T any] struct {
+	descending bool
+	value      func(row T) any
+}
+
+type memorySortedRow[T any] struct {
+	row    T
+	values []any
+}
+
+func memorySortKeyValues[T any](sortKeys []memorySortKey[T], row T) []any {
+	values := make([]any, 0, len(sortKeys))
+	for _, key := range sortKeys {
+		values = append(values, key.value(row))
+	}
+	return values
+}
+
+// paginateMemoryRows returns page of rows sorted by sort keys,
+// cursors are the same as of cursorPage
+func paginateMemoryRows[T any](
+	rows []T, sortKeys []memorySortKey[T], pagination domain.Pagination,
+) (page []T, next, prev *domain.Cursor) {
+	cursor := pagination.Cursor
+	backward := cursor != nil && cursor.Backward
+
+	sortedRows := make([]memorySortedRow[T], 0, len(rows))
+	for _, row := range rows {
+		values := memorySortKeyValues(sortKeys, row)
+		if cursor != nil && len(cursor.Keys) > 0 &&
+			compareSortKeyValues(sortKeys, values, cursor.Keys, backward) <= 0 {
+			continue
+		}
+		sortedRows = append(sortedRows, memorySortedRow[T]{row, values})
+	}
+	slices.SortFunc(sortedRows, func(a, b memorySortedRow[T]) int {
+		return compareSortKeyValues(sortKeys, a.values, b.values, backward)
+	})
+
+	limit := pagination.PerPage
+	from := 0
+	if cursor == nil {
+		from = min(pagination.Page*limit, len(sortedRows))
+	} else {
+		// The extra row tells if there are more of them
+		limit++
+	}
+	page = make([]T, 0, min(limit, len(sortedRows)-from))
+	for _, sortedRow := range sortedRows[from:min(from+limit, len(sortedRows))] {
+		page = append(page, sortedRow.row)
+	}
+	if cursor == nil {
+		return page, nil, nil
+	}
+
+	return cursorPage(page, pagination.PerPage, cursor,
+		func(row T) []any { return memorySortKeyValues(sortKeys, row) })
+}
+
+// compareSortKeyValues compares key values in sort keys
+// order, reversing directions if backward is set
+func compareSortKeyValues[T any](
+	sortKeys []memorySortKey[T], a, b []any, backward bool,
+) int {
+	for i, key := range sortKeys {
+		res := compareKeyValue(a[i], b[i])
+		if key.descending != backward {
+			res = -res
+		}
+		if res != 0 {
+			return res
+		}
+	}
+	return 0
+}
+
+// compareKeyValue compares values of the types sort keys have,
+// names are compared by code points unlike database collations.
+// Values of other or different types are a bug, so it panics on them
+func compareKeyValue(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case ksuid.KSUID:
+		return ksuid.Compare(a, b.(ksuid.KSUID))
+	default:
+		panic(fmt.Sprintf("sort key type %T is unknown", a))
+	}
+}
@@ -0,0 +1,314 @@
+package repos
+
+import (
+	"song-lib/internal/domain"
+	"song-lib/internal/filterexpr"
+	"song-lib/internal/trigram"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// songFilter is the in-memory counterpart of filterSongs
+type songFilter func(song *domain.Song) bool
+
+func (r *MemorySongRepository) songFilter(
+	f *domain.SongFilters,
+) (songFilter, error) {
+	var conditions []songFilter
+	if f.SongName != nil {
+		conditions = append(conditions, func(song *domain.Song) bool {
+			return r.nameMatches(song.Name, *f.SongName, f.NameMatchMode)
+		})
+	}
+	if f.SongLink != nil {
+		conditions = append(conditions, func(song *domain.Song) bool {
+			return song.Link == *f.SongLink
+		})
+	}
+	if f.MusicGroupName != nil {
+		conditions = append(conditions, func(song *domain.Song) bool {
+			return r.nameMatches(
+				song.MusicGroup.Name, *f.MusicGroupName, f.NameMatchMode)
+		})
+	}
+	if f.SongReleaseDateRange != nil {
+		conditions = append(conditions, func(song *domain.Song) bool {
+			return releaseDateInRange(song.ReleaseDate, f.SongReleaseDateRange)
+		})
+	}
+	if f.SongCoupletContains != nil {
+		conditions = append(conditions, func(song *domain.Song) bool {
+			return anyCoupletContains(song, *f.SongCoupletContains)
+		})
+	}
+	if f.Expression != nil {
+		condition, err := compileMemoryFilterExpression(f.Expression)
+		if err != nil {
+			return nil, errors.Wrap(err, "compile filter expression")
+		}
+		conditions = append(conditions, condition)
+	}
+
+	return func(song *domain.Song) bool {
+		for _, condition := range conditions {
+			if !condition(song) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func (r *MemorySongRepository) nameMatches(
+	name, query string, matchMode domain.MatchMode,
+) bool {
+	switch matchMode {
+	case domain.MatchModePrefix:
+		return strings.HasPrefix(strings.ToLower(name), strings.ToLower(query))
+	case domain.MatchModeFuzzy:
+		return trigram.Similarity(name, query) >= r.similarityThreshold
+	default:
+		return name == query
+	}
+}
+
+// releaseDateInRange tells if release date period overlaps the range
+func releaseDateInRange(releaseDate domain.PartialDate, r *domain.TimeRange) bool {
+	lastDay := releaseDate.LastDay()
+	switch {
+	case r.StartTime == nil:
+	case r.StartTimeExclusive && !lastDay.After(*r.StartTime),
+		lastDay.Before(*r.StartTime):
+		return false
+	}
+	switch {
+	case r.EndTime == nil:
+	case r.EndTimeExclusive && !releaseDate.Date.Before(*r.EndTime),
+		releaseDate.Date.After(*r.EndTime):
+		return false
+	}
+	return true
+}
+
+func anyCoupletContains(song *domain.Song, text string) bool {
+	text = strings.ToLower(text)
+	for _, couplet := range song.Couplets {
+		if strings.Contains(strings.ToLower(couplet), text) {
+			return true
+		}
+	}
+	return false
+}
+
+var memoryFilterExpressionFields = map[filterexpr.Field]func(
+	song *domain.Song) any{
+	filterexpr.FieldSong:      func(s *domain.Song) any { return s.Name },
+	filterexpr.FieldGroup:     func(s *domain.Song) any { return s.MusicGroup.Name },
+	filterexpr.FieldLink:      func(s *domain.Song) any { return s.Link },
+	filterexpr.FieldCreatedAt: func(s *domain.Song) any { return s.CreatedAt },
+	filterexpr.FieldUpdatedAt: func(s *domain.Song) any { return s.UpdatedAt },
+}
+
+// compileMemoryFilterExpression is the in-memory
+// counterpart of compileFilterExpression
+func compileMemoryFilterExpression(expr filterexpr.Expr) (songFilter, error) {
+	switch expr := expr.(type) {
+	case filterexpr.And:
+		conditions, err := compileMemoryFilterExpressions(expr.Operands)
+		if err != nil {
+			return nil, err
+		}
+		return func(song *domain.Song) bool {
+			for _, condition := range conditions {
+				if !condition(song) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case filterexpr.Or:
+		conditions, err := compileMemoryFilterExpressions(expr.Operands)
+		if err != nil {
+			return nil, err
+		}
+		return func(song *domain.Song) bool {
+			for _, condition := range conditions {
+				if condition(song) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case filterexpr.Not:
+		condition, err := compileMemoryFilterExpression(expr.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return func(song *domain.Song) bool { return !condition(song) }, nil
+	case filterexpr.Comparison:
+		return compileMemoryFilterComparison(expr)
+	default:
+		return nil, errors.Errorf("unknown expression %T", expr)
+	}
+}
+
+func compileMemoryFilterExpressions(
+	exprs []filterexpr.Expr,
+) ([]songFilter, error) {
+	conditions := make([]songFilter, 0, len(exprs))
+	for _, expr := range exprs {
+		condition, err := compileMemoryFilterExpression(expr)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+func compileMemoryFilterComparison(c filterexpr.Comparison) (songFilter, error) {
+	unsupported := errors.Errorf(
+		"unsupported comparison %s%s%v", c.Field, c.Operator, c.Value)
+
+	if c.Field == filterexpr.FieldText {
+		value, ok := c.Value.(string)
+		if !ok || c.Operator != filterexpr.OperatorContains {
+			return nil, unsupported
+		}
+		return func(song *domain.Song) bool {
+			return anyCoupletContains(song, value)
+		}, nil
+	}
+	if value, ok := c.Value.(time.Time); ok &&
+		c.Field == filterexpr.FieldReleaseDate {
+		return memoryReleaseDateComparison(c.Operator, value)
+	}
+	field, ok := memoryFilterExpressionFields[c.Field]
+	if !ok {
+		return nil, errors.Errorf("unknown field %s", c.Field)
+	}
+
+	switch value := c.Value.(type) {
+	case string:
+		switch c.Operator {
+		case filterexpr.OperatorEq:
+			return func(song *domain.Song) bool {
+				return field(song) == value
+			}, nil
+		case filterexpr.OperatorContains:
+			value = strings.ToLower(value)
+			return func(song *domain.Song) bool {
+				fieldValue, _ := field(song).(string)
+				return strings.Contains(strings.ToLower(fieldValue), value)
+			}, nil
+		}
+	case time.Time:
+		return func(song *domain.Song) bool {
+			fieldValue, _ := field(song).(time.Time)
+			// Timestamps are compared by UTC date if time of day is not given
+			if c.DateOnly {
+				fieldValue = fieldValue.UTC().Truncate(24 * time.Hour)
+			}
+			return operatorMatches(c.Operator, fieldValue.Compare(value))
+		}, nil
+	}
+
+	return nil, unsupported
+}
+
+// memoryReleaseDateComparison is the in-memory
+// counterpart of releaseDateComparison
+func memoryReleaseDateComparison(
+	operator filterexpr.Operator, value time.Time,
+) (songFilter, error) {
+	switch operator {
+	case filterexpr.OperatorEq:
+		timeRange := &domain.TimeRange{StartTime: &value, EndTime: &value}
+		return func(song *domain.Song) bool {
+			return releaseDateInRange(song.ReleaseDate, timeRange)
+		}, nil
+	case filterexpr.OperatorGt, filterexpr.OperatorGtOrEq:
+		return func(song *domain.Song) bool {
+			return operatorMatches(operator,
+				song.ReleaseDate.LastDay().Compare(value))
+		}, nil
+	case filterexpr.OperatorLt, filterexpr.OperatorLtOrEq:
+		return func(song *domain.Song) bool {
+			return operatorMatches(operator,
+				song.ReleaseDate.Date.Compare(value))
+		}, nil
+	default:
+		return nil, errors.Errorf("unsupported release date operator %s", operator)
+	}
+}
+
+// operatorMatches tells if comparison result of
+// field and value satisfies the operator
+func operatorMatches(operator filterexpr.Operator, comparison int) bool {
+	switch operator {
+	case filterexpr.OperatorEq:
+		return comparison == 0
+	case filterexpr.OperatorGt:
+		return comparison > 0
+	case filterexpr.OperatorGtOrEq:
+		return comparison >= 0
+	case filterexpr.OperatorLt:
+		return comparison < 0
+	case filterexpr.OperatorLtOrEq:
+		return comparison <= 0
+	default:
+		return false
+	}
+}
+
+var memorySongSortValues = map[domain.SongSortField]func(s *domain.Song) any{
+	domain.SongSortFieldName:        func(s *domain.Song) any { return s.Name },
+	domain.SongSortFieldGroupName:   func(s *domain.Song) any { return s.MusicGroup.Name },
+	domain.SongSortFieldReleaseDate: func(s *domain.Song) any { return s.ReleaseDate.Date },
+	domain.SongSortFieldCreatedAt:   func(s *domain.Song) any { return s.CreatedAt },
+	domain.SongSortFieldUpdatedAt:   func(s *domain.Song) any { return s.UpdatedAt },
+}
+
+// memorySongSortKeys returns sort keys having
+// the same values songSortKeys gives for cursors
+func memorySongSortKeys(
+	f *domain.SongFilters, sort []domain.SongSortOption,
+) ([]memorySortKey[*domain.Song], error) {
+	sortKeys := make([]memorySortKey[*domain.Song], 0, len(sort)+1)
+	for _, sortOption := range sort {
+		if sortOption.Field == domain.SongSortFieldRelevance {
+			sortKeys = append(sortKeys, memorySortKey[*domain.Song]{
+				descending: sortOption.Descending,
+				value:      func(s *domain.Song) any { return memorySongRelevance(f, s) },
+			})
+			continue
+		}
+
+		value, ok := memorySongSortValues[sortOption.Field]
+		if !ok {
+			return nil, errors.Errorf(
+				"unknown sort field %s", sortOption.Field)
+		}
+		sortKeys = append(sortKeys, memorySortKey[*domain.Song]{
+			descending: sortOption.Descending,
+			value:      value,
+		})
+	}
+
+	return append(sortKeys, memorySortKey[*domain.Song]{
+		value: func(s *domain.Song) any { return s.ID },
+	}), nil
+}
+
+func memorySongRelevance(f *domain.SongFilters, song *domain.Song) float64 {
+	var relevance float64
+	if f.SongName != nil {
+		relevance += trigram.Similarity(song.Name, *f.SongName)
+	}
+	if f.MusicGroupName != nil {
+		relevance += trigram.Similarity(song.MusicGroup.Name, *f.MusicGroupName)
+	}
+	return relevance
+}
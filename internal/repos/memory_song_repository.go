@@ -0,0 +1,585 @@
+package repos
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"song-lib/internal/domain"
+	"song-lib/internal/trigram"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+// MemorySongRepository keeps songs in memory with the same filter,
+// pagination and uniqueness semantics as SongRepository. It is meant
+// for tests and demos, as songs are lost when the process exits
+type MemorySongRepository struct {
+	mu                  sync.RWMutex
+	library             *memoryLibrary
+	similarityThreshold float64
+}
+
+// memoryLibrary is the state of in-memory repository. Songs are
+// never changed in place, so that library clones can share them
+type memoryLibrary struct {
+	songs map[ksuid.KSUID]*domain.Song
+	// songIDs are song IDs by music group ID and song name
+	songIDs map[memorySongKey]ksuid.KSUID
+	// musicGroups are music group IDs by name, groups are kept
+	// after their last song is deleted as they are in Postgres
+	musicGroups map[string]ksuid.KSUID
+}
+
+// memorySongKey is unique as the name of a song is unique in its group
+type memorySongKey struct {
+	musicGroupID ksuid.KSUID
+	songName     string
+}
+
+func newMemoryLibrary() *memoryLibrary {
+	return &memoryLibrary{
+		songs:       make(map[ksuid.KSUID]*domain.Song),
+		songIDs:     make(map[memorySongKey]ksuid.KSUID),
+		musicGroups: make(map[string]ksuid.KSUID),
+	}
+}
+
+func (l *memoryLibrary) clone() *memoryLibrary {
+	return &memoryLibrary{
+		songs:       maps.Clone(l.songs),
+		songIDs:     maps.Clone(l.songIDs),
+		musicGroups: maps.Clone(l.musicGroups),
+	}
+}
+
+func cloneSong(song *domain.Song) *domain.Song {
+	res := *song
+	res.Couplets = slices.Clone(song.Couplets)
+	return &res
+}
+
+// songWithFields returns song copy with the given fields,
+// the rest of them are left blank as selectSongs does
+func songWithFields(song *domain.Song, fields domain.SongFieldSet) domain.Song {
+	res := domain.Song{ID: song.ID, Version: song.Version}
+	if fields.Has(domain.SongFieldName) {
+		res.Name = song.Name
+	}
+	if fields.Has(domain.SongFieldGroup) {
+		res.MusicGroup = song.MusicGroup
+	}
+	if fields.Has(domain.SongFieldReleaseDate) {
+		res.ReleaseDate = song.ReleaseDate
+	}
+	if fields.Has(domain.SongFieldLink) {
+		res.Link = song.Link
+	}
+	if fields.Has(domain.SongFieldCouplets) {
+		res.Couplets = slices.Clone(song.Couplets)
+	}
+	if fields.Has(domain.SongFieldCreatedAt) {
+		res.CreatedAt = song.CreatedAt
+	}
+	if fields.Has(domain.SongFieldUpdatedAt) {
+		res.UpdatedAt = song.UpdatedAt
+	}
+	return res
+}
+
+func (l *memoryLibrary) findSong(songName, musicGroupName string) *domain.Song {
+	musicGroupID, ok := l.musicGroups[musicGroupName]
+	if !ok {
+		return nil
+	}
+	songID, ok := l.songIDs[memorySongKey{musicGroupID, songName}]
+	if !ok {
+		return nil
+	}
+	return l.songs[songID]
+}
+
+func (l *memoryLibrary) saveSong(song *domain.Song) (*domain.Song, error) {
+	if l.findSong(song.Name, song.MusicGroup.Name) != nil {
+		return nil, domain.ErrSongAlreadyExists
+	}
+
+	musicGroupID, ok := l.musicGroups[song.MusicGroup.Name]
+	if !ok {
+		musicGroupID = ksuid.New()
+		l.musicGroups[song.MusicGroup.Name] = musicGroupID
+	}
+	now := microsecondNow()
+	saved := cloneSong(song)
+	saved.ID = ksuid.New()
+	saved.MusicGroup.ID = musicGroupID
+	saved.CreatedAt = now
+	saved.UpdatedAt = now
+	saved.Version = 1
+	l.songs[saved.ID] = saved
+	l.songIDs[memorySongKey{musicGroupID, saved.Name}] = saved.ID
+
+	return cloneSong(saved), nil
+}
+
+// getSong fails with ErrVersionMismatch if
+// version is given and song has another one
+func (l *memoryLibrary) getSong(
+	songID ksuid.KSUID, version *int64,
+) (*domain.Song, error) {
+	song, ok := l.songs[songID]
+	switch {
+	case !ok:
+		return nil, domain.ErrSongNotFound
+	case version != nil && song.Version != *version:
+		return nil, domain.ErrVersionMismatch
+	}
+	return song, nil
+}
+
+func (l *memoryLibrary) updateSong(
+	songID ksuid.KSUID, version *int64, songUpdate *domain.SongUpdate,
+) (*domain.Song, error) {
+	song, err := l.getSong(songID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := cloneSong(song)
+	if songUpdate.Name != nil && *songUpdate.Name != song.Name {
+		if l.findSong(*songUpdate.Name, song.MusicGroup.Name) != nil {
+			return nil, domain.ErrSongAlreadyExists
+		}
+		updated.Name = *songUpdate.Name
+	}
+	if songUpdate.ReleaseDate != nil {
+		updated.ReleaseDate = *songUpdate.ReleaseDate
+	}
+	if songUpdate.Link != nil {
+		updated.Link = *songUpdate.Link
+	}
+	if songUpdate.Couplets != nil {
+		updated.Couplets = slices.Clone(*songUpdate.Couplets)
+	}
+	updated.UpdatedAt = microsecondNow()
+	updated.Version++
+	l.songs[songID] = updated
+	if updated.Name != song.Name {
+		delete(l.songIDs, memorySongKey{song.MusicGroup.ID, song.Name})
+		l.songIDs[memorySongKey{song.MusicGroup.ID, updated.Name}] = songID
+	}
+
+	return cloneSong(updated), nil
+}
+
+func (l *memoryLibrary) deleteSong(songID ksuid.KSUID, version *int64) error {
+	song, err := l.getSong(songID, version)
+	if err != nil {
+		return err
+	}
+	delete(l.songs, songID)
+	delete(l.songIDs, memorySongKey{song.MusicGroup.ID, song.Name})
+	return nil
+}
+
+func (r *MemorySongRepository) SaveSong(
+	_ context.Context, song *domain.Song,
+) (*domain.Song, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.library.saveSong(song)
+}
+
+func (r *MemorySongRepository) SongExistsByID(
+	_ context.Context, songID ksuid.KSUID,
+) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.library.songs[songID]
+	return ok, nil
+}
+
+func (r *MemorySongRepository) SongExistsByNameAndMusicGroupName(
+	_ context.Context, songName, musicGroupName string,
+) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.library.findSong(songName, musicGroupName) != nil, nil
+}
+
+func (r *MemorySongRepository) GetSongByID(
+	_ context.Context, songID ksuid.KSUID,
+) (*domain.Song, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	song, err := r.library.getSong(songID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cloneSong(song), nil
+}
+
+func (r *MemorySongRepository) GetSongByNameAndMusicGroupName(
+	_ context.Context, songName, musicGroupName string,
+) (*domain.Song, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	song := r.library.findSong(songName, musicGroupName)
+	if song == nil {
+		return nil, domain.ErrSongNotFound
+	}
+	return cloneSong(song), nil
+}
+
+// filteredSongs returns songs matching filters in no particular order.
+// Songs are shared with the library, so they must not be changed
+func (r *MemorySongRepository) filteredSongs(
+	f *domain.SongFilters,
+) ([]*domain.Song, error) {
+	filter, err := r.songFilter(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "filter songs")
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var songs []*domain.Song
+	for _, song := range r.library.songs {
+		if filter(song) {
+			songs = append(songs, song)
+		}
+	}
+	return songs, nil
+}
+
+func (r *MemorySongRepository) GetSongsFilteredPaginated(
+	_ context.Context, f *domain.SongFilters,
+	sort []domain.SongSortOption, pagination domain.Pagination,
+	fields domain.SongFieldSet,
+) (*domain.SongsPage, error) {
+	sortKeys, err := memorySongSortKeys(f, sort)
+	if err != nil {
+		return nil, errors.Wrap(err, "build sort keys")
+	}
+	songs, err := r.filteredSongs(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var songsPage domain.SongsPage
+	songs, songsPage.NextCursor, songsPage.PrevCursor = paginateMemoryRows(
+		songs, sortKeys, pagination)
+
+	songsPage.Songs = make([]domain.Song, 0, len(songs))
+	for _, song := range songs {
+		songsPage.Songs = append(songsPage.Songs, songWithFields(song, fields))
+	}
+
+	return &songsPage, nil
+}
+
+// ForEachSongFiltered calls fn for each filtered song ordered by ID.
+// Songs are taken from a single snapshot, fn errors are returned as is
+func (r *MemorySongRepository) ForEachSongFiltered(
+	_ context.Context, f *domain.SongFilters,
+	fn func(song *domain.Song) error,
+) error {
+	songs, err := r.filteredSongs(f)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(songs, func(a, b *domain.Song) int {
+		return ksuid.Compare(a.ID, b.ID)
+	})
+
+	for _, song := range songs {
+		if err := fn(cloneSong(song)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountSongsFiltered counts songs exactly even if estimated count is asked
+func (r *MemorySongRepository) CountSongsFiltered(
+	_ context.Context, f *domain.SongFilters, _ bool,
+) (int, error) {
+	songs, err := r.filteredSongs(f)
+	if err != nil {
+		return 0, err
+	}
+	return len(songs), nil
+}
+
+func (r *MemorySongRepository) GetSongSearchSuggestion(
+	_ context.Context, f *domain.SongFilters,
+) (*domain.SongSearchSuggestion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var suggestion domain.SongSearchSuggestion
+	if f.SongName != nil {
+		names := make([]string, 0, len(r.library.songs))
+		for _, song := range r.library.songs {
+			names = append(names, song.Name)
+		}
+		suggestion.SongName = r.mostSimilarName(names, *f.SongName)
+	}
+	if f.MusicGroupName != nil {
+		names := make([]string, 0, len(r.library.musicGroups))
+		for name := range r.library.musicGroups {
+			names = append(names, name)
+		}
+		suggestion.MusicGroupName = r.mostSimilarName(names, *f.MusicGroupName)
+	}
+	if suggestion.SongName == nil && suggestion.MusicGroupName == nil {
+		return nil, nil
+	}
+
+	return &suggestion, nil
+}
+
+func (r *MemorySongRepository) mostSimilarName(
+	names []string, name string,
+) *string {
+	var (
+		similarName       *string
+		highestSimilarity float64
+	)
+	for _, candidate := range names {
+		similarity := trigram.Similarity(candidate, name)
+		if similarity < r.similarityThreshold {
+			continue
+		}
+		if similarName == nil || similarity > highestSimilarity ||
+			similarity == highestSimilarity && candidate < *similarName {
+
+			similarName = &candidate
+			highestSimilarity = similarity
+		}
+	}
+	return similarName
+}
+
+func (r *MemorySongRepository) GetSongCoupletsPaginated(
+	_ context.Context, songID ksuid.KSUID,
+	pagination domain.Pagination,
+) (*domain.CoupletsPage, error) {
+	r.mu.RLock()
+	song := r.library.songs[songID]
+	r.mu.RUnlock()
+
+	var coupletModels []couplet
+	if song != nil {
+		coupletModels = make([]couplet, 0, len(song.Couplets))
+		for i, text := range song.Couplets {
+			coupletModels = append(coupletModels, couplet{Num: i + 1, Text: text})
+		}
+	}
+
+	var coupletsPage domain.CoupletsPage
+	coupletModels, coupletsPage.NextCursor, coupletsPage.PrevCursor = paginateMemoryRows(
+		coupletModels,
+		[]memorySortKey[couplet]{{value: func(c couplet) any { return c.Num }}},
+		pagination)
+
+	coupletsPage.Couplets = make([]string, 0, len(coupletModels))
+	for _, coupletModel := range coupletModels {
+		coupletsPage.Couplets = append(coupletsPage.Couplets, coupletModel.Text)
+	}
+
+	return &coupletsPage, nil
+}
+
+func (r *MemorySongRepository) CountSongCouplets(
+	_ context.Context, songID ksuid.KSUID,
+) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	song, ok := r.library.songs[songID]
+	if !ok {
+		return 0, nil
+	}
+	return len(song.Couplets), nil
+}
+
+// GetAutocompleteSuggestions returns names containing the query,
+// prefix matches go first, then ones with more popular music groups
+// (popularity is the number of songs in the music group)
+func (r *MemorySongRepository) GetAutocompleteSuggestions(
+	_ context.Context, query string,
+	kind domain.AutocompleteKind, limit int,
+) ([]domain.AutocompleteSuggestion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	popularity := make(map[ksuid.KSUID]int)
+	for _, song := range r.library.songs {
+		popularity[song.MusicGroup.ID]++
+	}
+
+	type candidate struct {
+		suggestion   domain.AutocompleteSuggestion
+		musicGroupID ksuid.KSUID
+	}
+	var candidates []candidate
+	switch kind {
+	case domain.AutocompleteKindSong:
+		for _, song := range r.library.songs {
+			candidates = append(candidates, candidate{
+				suggestion: domain.AutocompleteSuggestion{
+					ID:             song.ID,
+					Name:           song.Name,
+					MusicGroupName: song.MusicGroup.Name,
+				},
+				musicGroupID: song.MusicGroup.ID,
+			})
+		}
+	case domain.AutocompleteKindGroup:
+		for name, id := range r.library.musicGroups {
+			candidates = append(candidates, candidate{
+				suggestion:   domain.AutocompleteSuggestion{ID: id, Name: name},
+				musicGroupID: id,
+			})
+		}
+	default:
+		return nil, errors.Errorf("unknown autocomplete kind %s", kind)
+	}
+
+	lowerQuery := strings.ToLower(query)
+	isPrefix := func(c candidate) bool {
+		return strings.HasPrefix(strings.ToLower(c.suggestion.Name), lowerQuery)
+	}
+	candidates = slices.DeleteFunc(candidates, func(c candidate) bool {
+		if len([]rune(query)) < autocompleteMinInfixQueryLen {
+			return !isPrefix(c)
+		}
+		return !strings.Contains(strings.ToLower(c.suggestion.Name), lowerQuery)
+	})
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		aIsPrefix, bIsPrefix := isPrefix(a), isPrefix(b)
+		if aIsPrefix != bIsPrefix {
+			if aIsPrefix {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(
+			cmp.Compare(popularity[b.musicGroupID], popularity[a.musicGroupID]),
+			strings.Compare(a.suggestion.Name, b.suggestion.Name))
+	})
+
+	suggestions := make([]domain.AutocompleteSuggestion, 0, min(limit, len(candidates)))
+	for _, c := range candidates[:min(limit, len(candidates))] {
+		suggestions = append(suggestions, c.suggestion)
+	}
+
+	return suggestions, nil
+}
+
+func (r *MemorySongRepository) UpdateSong(
+	_ context.Context, songID ksuid.KSUID,
+	version *int64, songUpdate *domain.SongUpdate,
+) (*domain.Song, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.library.updateSong(songID, version, songUpdate)
+}
+
+// PatchSong calls patch holding the repository lock,
+// so that concurrent patches are applied one after another
+func (r *MemorySongRepository) PatchSong(
+	_ context.Context, songID ksuid.KSUID,
+	version *int64, patch domain.SongPatchFunc,
+) (*domain.Song, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	song, err := r.library.getSong(songID, version)
+	if err != nil {
+		return nil, err
+	}
+	// Patch errors are returned as is for caller to handle
+	songUpdate, err := patch(cloneSong(song))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.library.updateSong(songID, nil, songUpdate)
+}
+
+func (r *MemorySongRepository) DeleteSong(
+	_ context.Context, songID ksuid.KSUID,
+	version *int64,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.library.deleteSong(songID, version)
+}
+
+// ApplySongBatch applies atomic batch to a library
+// clone, which replaces the library if all writes succeed
+func (r *MemorySongRepository) ApplySongBatch(
+	_ context.Context, writes []domain.SongBatchWrite,
+	atomic bool,
+) ([]domain.SongBatchItemResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	library := r.library
+	if atomic {
+		library = library.clone()
+	}
+
+	results := make([]domain.SongBatchItemResult, len(writes))
+	for i := range writes {
+		results[i].Song, results[i].Err = library.applySongBatchWrite(&writes[i])
+		if results[i].Err != nil && atomic {
+			for j := range results {
+				if j != i {
+					results[j] = domain.SongBatchItemResult{Err: domain.ErrBatchAborted}
+				}
+			}
+			return results, nil
+		}
+	}
+	r.library = library
+
+	return results, nil
+}
+
+func (l *memoryLibrary) applySongBatchWrite(
+	write *domain.SongBatchWrite,
+) (*domain.Song, error) {
+	switch write.Kind {
+	case domain.SongBatchOperationCreate:
+		return l.saveSong(write.Song)
+	case domain.SongBatchOperationUpdate:
+		return l.updateSong(write.SongID, write.Version, write.Update)
+	case domain.SongBatchOperationDelete:
+		return nil, l.deleteSong(write.SongID, write.Version)
+	default:
+		return nil, errors.Errorf("unknown batch operation %s", write.Kind)
+	}
+}
+
+func NewMemorySongRepository(
+	similarityThreshold float64,
+) *MemorySongRepository {
+	return &MemorySongRepository{
+		library:             newMemoryLibrary(),
+		similarityThreshold: similarityThreshold,
+	}
+}
@@ -0,0 +1,34 @@
+package repos
+
+import (
+	"context"
+	"song-lib/internal/domain"
+	"sync"
+)
+
+// MemoryTxManager runs transactions one at a time. Memory
+// repositories can't roll changes back, so changes made
+// before a transaction fails are kept
+type MemoryTxManager struct {
+	mu sync.Mutex
+}
+
+type memoryTxKey struct{}
+
+func (m *MemoryTxManager) InTx(
+	ctx context.Context, _ domain.TxOptions,
+	fn func(ctx context.Context) error,
+) error {
+	if ctx.Value(memoryTxKey{}) != nil {
+		return fn(ctx)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return fn(context.WithValue(ctx, memoryTxKey{}, true))
+}
+
+func NewMemoryTxManager() *MemoryTxManager {
+	return &MemoryTxManager{}
+}
@@ -0,0 +1,130 @@
+package repos
+
+import (
+	"slices"
+	"song-lib/internal/domain"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type sortKey struct {
+	expr       sq.Sqlizer
+	descending bool
+	// value returns the key value of a row model
+	value func(model any) any
+}
+
+var coupletNumSortKey = sortKey{
+	expr:  sq.Expr("sc.couplet_num"),
+	value: func(c any) any { return c.(*couplet).Num },
+}
+
+func sortKeyValues(sortKeys []sortKey, model any) []any {
+	values := make([]any, 0, len(sortKeys))
+	for _, key := range sortKeys {
+		values = append(values, key.value(model))
+	}
+	return values
+}
+
+// orderBySortKeys adds ORDER BY for sort keys,
+// reversing their directions if backward is set
+func orderBySortKeys(
+	builder sq.SelectBuilder, sortKeys []sortKey, backward bool,
+) sq.SelectBuilder {
+	for _, key := range sortKeys {
+		direction := "ASC"
+		if key.descending != backward {
+			direction = "DESC"
+		}
+		builder = builder.OrderByClause(sq.Expr("? "+direction, key.expr))
+	}
+	return builder
+}
+
+// paginateByCursor makes query return up to limit+1 rows following
+// the cursor, the extra row tells if there are more of them
+func paginateByCursor(
+	builder sq.SelectBuilder, sortKeys []sortKey,
+	cursor *domain.Cursor, limit int,
+) sq.SelectBuilder {
+	if len(cursor.Keys) > 0 {
+		builder = builder.Where(
+			keysetCondition(sortKeys, cursor.Keys, cursor.Backward))
+	}
+
+	return orderBySortKeys(builder, sortKeys, cursor.Backward).
+		Limit(uint64(limit) + 1)
+}
+
+// keysetCondition matches rows going after the row with given
+// key values (or before it if backward is set) in sort keys order:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+func keysetCondition(
+	sortKeys []sortKey, values []any, backward bool,
+) sq.Sqlizer {
+	condition := make(sq.Or, 0, len(sortKeys))
+	for i, key := range sortKeys {
+		keyCondition := make(sq.And, 0, i+1)
+		for j := 0; j < i; j++ {
+			keyCondition = append(keyCondition,
+				sq.Expr("? = ?", sortKeys[j].expr, values[j]))
+		}
+
+		operator := ">"
+		if key.descending != backward {
+			operator = "<"
+		}
+		keyCondition = append(keyCondition,
+			sq.Expr("? "+operator+" ?", key.expr, values[i]))
+
+		condition = append(condition, keyCondition)
+	}
+
+	return condition
+}
+
+// cursorPage trims the extra row fetched by paginateByCursor,
+// restores order of rows fetched backward and returns
+// cursors of the adjacent pages
+func cursorPage[T any](
+	rows []T, limit int, cursor *domain.Cursor,
+	keyValues func(row T) []any,
+) (page []T, next, prev *domain.Cursor) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if cursor.Backward {
+		slices.Reverse(rows)
+	}
+
+	cameFromPage := len(cursor.Keys) > 0
+	if len(rows) == 0 {
+		if !cameFromPage {
+			return rows, nil, nil
+		}
+		// Lead back to the page the cursor came from
+		back := &domain.Cursor{
+			Keys:     cursor.Keys,
+			Backward: !cursor.Backward,
+		}
+		if cursor.Backward {
+			return rows, back, nil
+		}
+		return rows, nil, back
+	}
+
+	hasNext, hasPrev := hasMore, cameFromPage
+	if cursor.Backward {
+		hasNext, hasPrev = cameFromPage, hasMore
+	}
+	if hasNext {
+		next = &domain.Cursor{Keys: keyValues(rows[len(rows)-1])}
+	}
+	if hasPrev {
+		prev = &domain.Cursor{Keys: keyValues(rows[0]), Backward: true}
+	}
+
+	return rows, next, prev
+}
@@ -0,0 +1,508 @@
+// Package repostest has conformance tests every
+// domain.SongRepository implementation must pass
+package repostest
+
+import (
+	"context"
+	"song-lib/internal/domain"
+	"song-lib/internal/filterexpr"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+)
+
+// NewSongRepository returns empty repository with
+// similarity threshold of fuzzy search set to 0.3
+type NewSongRepository func(t *testing.T) domain.SongRepository
+
+// TestSongRepository runs conformance tests,
+// each of them gets a new empty repository
+func TestSongRepository(t *testing.T, newRepository NewSongRepository) {
+	tests := map[string]func(t *testing.T, repo domain.SongRepository){
+		"SaveAndGet":    testSaveAndGet,
+		"Uniqueness":    testUniqueness,
+		"Filters":       testFilters,
+		"Pagination":    testPagination,
+		"Versions":      testVersions,
+		"Couplets":      testCouplets,
+		"Autocomplete":  testAutocomplete,
+		"Suggestion":    testSuggestion,
+		"Batch":         testBatch,
+		"ForEachSorted": testForEachSorted,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepository(t))
+		})
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func saveSong(
+	t *testing.T, repo domain.SongRepository, group, name string,
+	releaseDate domain.PartialDate, couplets ...string,
+) *domain.Song {
+	t.Helper()
+	song, err := repo.SaveSong(context.Background(), &domain.Song{
+		Name:        name,
+		MusicGroup:  domain.MusicGroup{Name: group},
+		Couplets:    couplets,
+		ReleaseDate: releaseDate,
+		Link:        "https://example.com/" + name,
+	})
+	require.NoError(t, err)
+	return song
+}
+
+func dayDate(year int, month time.Month, day int) domain.PartialDate {
+	return domain.PartialDate{
+		Date: date(year, month, day), Precision: domain.DatePrecisionDay}
+}
+
+func songNames(songs []domain.Song) []string {
+	names := make([]string, 0, len(songs))
+	for _, song := range songs {
+		names = append(names, song.Name)
+	}
+	return names
+}
+
+func testSaveAndGet(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	releaseDate := domain.PartialDate{
+		Date: date(2009, 9, 1), Precision: domain.DatePrecisionMonth}
+	saved := saveSong(t, repo, "Muse", "Uprising", releaseDate,
+		"Paranoia is in bloom", "They will not force us")
+	require.NotEqual(t, ksuid.Nil, saved.ID)
+	require.NotEqual(t, ksuid.Nil, saved.MusicGroup.ID)
+	require.Equal(t, int64(1), saved.Version)
+	require.False(t, saved.CreatedAt.IsZero())
+	require.Equal(t, saved.CreatedAt, saved.UpdatedAt)
+
+	song, err := repo.GetSongByID(ctx, saved.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Uprising", song.Name)
+	require.Equal(t, "Muse", song.MusicGroup.Name)
+	require.Equal(t, []string{"Paranoia is in bloom", "They will not force us"},
+		song.Couplets)
+	require.True(t, releaseDate.Date.Equal(song.ReleaseDate.Date))
+	require.Equal(t, domain.DatePrecisionMonth, song.ReleaseDate.Precision)
+	require.Equal(t, "https://example.com/Uprising", song.Link)
+
+	song, err = repo.GetSongByNameAndMusicGroupName(ctx, "Uprising", "Muse")
+	require.NoError(t, err)
+	require.Equal(t, saved.ID, song.ID)
+
+	exists, err := repo.SongExistsByID(ctx, saved.ID)
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = repo.SongExistsByNameAndMusicGroupName(ctx, "Uprising", "Muse")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	other := saveSong(t, repo, "Muse", "Resistance", releaseDate, "Is our secret safe")
+	require.Equal(t, saved.MusicGroup.ID, other.MusicGroup.ID)
+
+	_, err = repo.GetSongByID(ctx, ksuid.New())
+	require.ErrorIs(t, err, domain.ErrSongNotFound)
+	_, err = repo.GetSongByNameAndMusicGroupName(ctx, "Uprising", "Queen")
+	require.ErrorIs(t, err, domain.ErrSongNotFound)
+	exists, err = repo.SongExistsByID(ctx, ksuid.New())
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func testUniqueness(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	releaseDate := dayDate(2009, 9, 7)
+	uprising := saveSong(t, repo, "Muse", "Uprising", releaseDate, "a")
+
+	_, err := repo.SaveSong(ctx, &domain.Song{
+		Name:        "Uprising",
+		MusicGroup:  domain.MusicGroup{Name: "Muse"},
+		Couplets:    []string{"b"},
+		ReleaseDate: releaseDate,
+	})
+	require.Error(t, err)
+
+	// The same name is fine for another group
+	saveSong(t, repo, "Queen", "Uprising", releaseDate, "c")
+
+	resistance := saveSong(t, repo, "Muse", "Resistance", releaseDate, "d")
+	name := "Uprising"
+	_, err = repo.UpdateSong(ctx, resistance.ID, nil,
+		&domain.SongUpdate{Name: &name})
+	require.Error(t, err)
+
+	// Names of renamed and deleted songs are free again
+	name = "Exogenesis"
+	_, err = repo.UpdateSong(ctx, resistance.ID, nil,
+		&domain.SongUpdate{Name: &name})
+	require.NoError(t, err)
+	exists, err := repo.SongExistsByNameAndMusicGroupName(ctx, "Exogenesis", "Muse")
+	require.NoError(t, err)
+	require.True(t, exists)
+	saveSong(t, repo, "Muse", "Resistance", releaseDate, "e")
+	require.NoError(t, repo.DeleteSong(ctx, resistance.ID, nil))
+	saveSong(t, repo, "Muse", "Exogenesis", releaseDate, "f")
+
+	song, err := repo.GetSongByID(ctx, uprising.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, song.Couplets)
+}
+
+func testFilters(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	saveSong(t, repo, "Muse", "Uprising", domain.PartialDate{
+		Date: date(2009, 1, 1), Precision: domain.DatePrecisionYear},
+		"Paranoia is in Bloom")
+	saveSong(t, repo, "Muse", "Resistance", dayDate(2010, 2, 22),
+		"Is our secret safe tonight")
+	saveSong(t, repo, "Queen", "Bohemian Rhapsody", dayDate(1975, 10, 31),
+		"Is this the real life")
+
+	filter := func(filters domain.SongFilters) []string {
+		t.Helper()
+		page, err := repo.GetSongsFilteredPaginated(ctx, &filters,
+			[]domain.SongSortOption{{Field: domain.SongSortFieldName}},
+			domain.Pagination{PerPage: 10}, nil)
+		require.NoError(t, err)
+		count, err := repo.CountSongsFiltered(ctx, &filters, false)
+		require.NoError(t, err)
+		require.Equal(t, len(page.Songs), count)
+		return songNames(page.Songs)
+	}
+	str := func(s string) *string { return &s }
+	parse := func(input string) filterexpr.Expr {
+		t.Helper()
+		expr, err := filterexpr.Parse(input)
+		require.NoError(t, err)
+		return expr
+	}
+
+	require.Equal(t, []string{"Bohemian Rhapsody", "Resistance", "Uprising"},
+		filter(domain.SongFilters{}))
+	require.Equal(t, []string{"Resistance", "Uprising"},
+		filter(domain.SongFilters{MusicGroupName: str("Muse")}))
+	require.Empty(t, filter(domain.SongFilters{MusicGroupName: str("muse")}))
+	require.Equal(t, []string{"Resistance", "Uprising"},
+		filter(domain.SongFilters{
+			MusicGroupName: str("mu"), NameMatchMode: domain.MatchModePrefix}))
+	require.Equal(t, []string{"Bohemian Rhapsody"},
+		filter(domain.SongFilters{
+			SongName: str("Bohemian Rapsody"), NameMatchMode: domain.MatchModeFuzzy}))
+	require.Equal(t, []string{"Uprising"},
+		filter(domain.SongFilters{SongLink: str("https://example.com/Uprising")}))
+	require.Equal(t, []string{"Uprising"},
+		filter(domain.SongFilters{SongCoupletContains: str("in bloom")}))
+
+	// Year precision release date overlaps any range within the year
+	require.Equal(t, []string{"Uprising"},
+		filter(domain.SongFilters{SongReleaseDateRange: &domain.TimeRange{
+			StartTime: ptr(date(2009, 6, 1)), EndTime: ptr(date(2009, 6, 30))}}))
+	require.Equal(t, []string{"Resistance"},
+		filter(domain.SongFilters{SongReleaseDateRange: &domain.TimeRange{
+			StartTime:          ptr(date(2009, 12, 31)),
+			StartTimeExclusive: true}}))
+
+	require.Equal(t, []string{"Bohemian Rhapsody", "Resistance"},
+		filter(domain.SongFilters{Expression: parse(
+			`text ~ "is " AND NOT (song:Uprising)`)}))
+	require.Equal(t, []string{"Bohemian Rhapsody", "Uprising"},
+		filter(domain.SongFilters{Expression: parse(
+			`releaseDate < 2000-01-01 OR releaseDate : 2009-03-15`)}))
+	require.Equal(t, []string{"Resistance", "Uprising"},
+		filter(domain.SongFilters{Expression: parse(
+			`group ~ US AND releaseDate >= 2009-12-31`)}))
+	require.Len(t, filter(domain.SongFilters{Expression: parse(
+		"createdAt >= 2000-01-01")}), 3)
+
+	// Songs without link match empty one and negated link conditions
+	songs, err := repo.GetSongsFilteredPaginated(ctx, &domain.SongFilters{
+		SongName: str("Bohemian Rhapsody")}, nil, domain.Pagination{PerPage: 1}, nil)
+	require.NoError(t, err)
+	_, err = repo.UpdateSong(ctx, songs.Songs[0].ID, nil,
+		&domain.SongUpdate{Link: str("")})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Bohemian Rhapsody"},
+		filter(domain.SongFilters{SongLink: str("")}))
+	require.Equal(t, []string{"Bohemian Rhapsody"},
+		filter(domain.SongFilters{Expression: parse(`link:""`)}))
+	require.Equal(t, []string{"Resistance", "Uprising"},
+		filter(domain.SongFilters{Expression: parse(`NOT link:""`)}))
+	require.Equal(t, []string{"Bohemian Rhapsody", "Resistance"},
+		filter(domain.SongFilters{Expression: parse(
+			`NOT link:"https://example.com/Uprising"`)}))
+	require.Equal(t, []string{"Bohemian Rhapsody", "Resistance"},
+		filter(domain.SongFilters{Expression: parse(`NOT link ~ uprising`)}))
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func testPagination(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	names := []string{"A", "B", "C", "D", "E"}
+	for i, name := range names {
+		saveSong(t, repo, "Muse", name, dayDate(2000+i%2, 1, 1), "x")
+	}
+	sort := []domain.SongSortOption{
+		{Field: domain.SongSortFieldReleaseDate, Descending: true},
+		{Field: domain.SongSortFieldName},
+	}
+	// Release years are 2000, 2001, 2000, 2001, 2000
+	sorted := []string{"B", "D", "A", "C", "E"}
+
+	page, err := repo.GetSongsFilteredPaginated(ctx, &domain.SongFilters{},
+		sort, domain.Pagination{Page: 1, PerPage: 2}, nil)
+	require.NoError(t, err)
+	require.Equal(t, sorted[2:4], songNames(page.Songs))
+	require.Nil(t, page.NextCursor)
+
+	fields := domain.SongFieldSet{domain.SongFieldName: true}
+	getPage := func(cursor *domain.Cursor) *domain.SongsPage {
+		t.Helper()
+		page, err := repo.GetSongsFilteredPaginated(ctx, &domain.SongFilters{},
+			sort, domain.Pagination{Cursor: cursor, PerPage: 2}, fields)
+		require.NoError(t, err)
+		return page
+	}
+
+	var visited []string
+	page = getPage(&domain.Cursor{})
+	require.Nil(t, page.PrevCursor)
+	for {
+		visited = append(visited, songNames(page.Songs)...)
+		if page.NextCursor == nil {
+			break
+		}
+		page = getPage(page.NextCursor)
+	}
+	require.Equal(t, sorted, visited)
+	require.Equal(t, []string{"E"}, songNames(page.Songs))
+	require.Empty(t, page.Songs[0].Link)
+
+	page = getPage(page.PrevCursor)
+	require.Equal(t, sorted[2:4], songNames(page.Songs))
+	page = getPage(page.PrevCursor)
+	require.Equal(t, sorted[:2], songNames(page.Songs))
+	require.Nil(t, page.PrevCursor)
+
+	page = getPage(&domain.Cursor{Backward: true})
+	require.Equal(t, sorted[3:], songNames(page.Songs))
+	require.Nil(t, page.NextCursor)
+}
+
+func testVersions(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	song := saveSong(t, repo, "Muse", "Uprising", dayDate(2009, 9, 7), "a")
+	staleVersion, missingID := song.Version, ksuid.New()
+
+	name, link := "Resistance", "https://example.com/resistance"
+	updated, err := repo.UpdateSong(ctx, song.ID, &song.Version,
+		&domain.SongUpdate{Name: &name, Couplets: &[]string{"b", "c"}})
+	require.NoError(t, err)
+	require.Equal(t, "Resistance", updated.Name)
+	require.Equal(t, []string{"b", "c"}, updated.Couplets)
+	require.Equal(t, song.Link, updated.Link)
+	require.Equal(t, int64(2), updated.Version)
+	require.False(t, updated.UpdatedAt.Before(song.UpdatedAt))
+
+	_, err = repo.UpdateSong(ctx, song.ID, &staleVersion,
+		&domain.SongUpdate{Link: &link})
+	require.ErrorIs(t, err, domain.ErrVersionMismatch)
+	_, err = repo.UpdateSong(ctx, missingID, nil, &domain.SongUpdate{Link: &link})
+	require.ErrorIs(t, err, domain.ErrSongNotFound)
+
+	patched, err := repo.PatchSong(ctx, song.ID, nil,
+		func(song *domain.Song) (*domain.SongUpdate, error) {
+			require.Equal(t, "Resistance", song.Name)
+			return &domain.SongUpdate{Link: &link}, nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, link, patched.Link)
+	require.Equal(t, int64(3), patched.Version)
+
+	_, err = repo.PatchSong(ctx, song.ID, nil,
+		func(*domain.Song) (*domain.SongUpdate, error) {
+			return nil, domain.ErrPatchConflict
+		})
+	require.ErrorIs(t, err, domain.ErrPatchConflict)
+	_, err = repo.PatchSong(ctx, song.ID, &staleVersion,
+		func(*domain.Song) (*domain.SongUpdate, error) {
+			return &domain.SongUpdate{}, nil
+		})
+	require.ErrorIs(t, err, domain.ErrVersionMismatch)
+
+	require.ErrorIs(t, repo.DeleteSong(ctx, song.ID, &staleVersion),
+		domain.ErrVersionMismatch)
+	require.NoError(t, repo.DeleteSong(ctx, song.ID, &patched.Version))
+	require.ErrorIs(t, repo.DeleteSong(ctx, song.ID, nil),
+		domain.ErrSongNotFound)
+}
+
+func testCouplets(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	song := saveSong(t, repo, "Muse", "Uprising", dayDate(2009, 9, 7),
+		"1", "2", "3", "4", "5")
+
+	count, err := repo.CountSongCouplets(ctx, song.ID)
+	require.NoError(t, err)
+	require.Equal(t, 5, count)
+
+	page, err := repo.GetSongCoupletsPaginated(ctx, song.ID,
+		domain.Pagination{Page: 2, PerPage: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"5"}, page.Couplets)
+
+	page, err = repo.GetSongCoupletsPaginated(ctx, song.ID,
+		domain.Pagination{Cursor: &domain.Cursor{}, PerPage: 3})
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "2", "3"}, page.Couplets)
+	require.NotNil(t, page.NextCursor)
+
+	page, err = repo.GetSongCoupletsPaginated(ctx, song.ID,
+		domain.Pagination{Cursor: page.NextCursor, PerPage: 3})
+	require.NoError(t, err)
+	require.Equal(t, []string{"4", "5"}, page.Couplets)
+	require.Nil(t, page.NextCursor)
+	require.NotNil(t, page.PrevCursor)
+}
+
+func testAutocomplete(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	releaseDate := dayDate(2009, 9, 7)
+	saveSong(t, repo, "Muse", "Uprising", releaseDate, "a")
+	saveSong(t, repo, "Muse", "Resistance", releaseDate, "a")
+	saveSong(t, repo, "Queen", "Rising Sun", releaseDate, "a")
+	saveSong(t, repo, "Queen", "Risen", releaseDate, "a")
+	saveSong(t, repo, "Queen", "Sunrise", releaseDate, "a")
+	saveSong(t, repo, "Muse", "Supremacy", releaseDate, "a")
+	saveSong(t, repo, "Muse", "Survival", releaseDate, "a")
+
+	suggestions, err := repo.GetAutocompleteSuggestions(
+		ctx, "ris", domain.AutocompleteKindSong, 10)
+	require.NoError(t, err)
+	names := make([]string, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		names = append(names, suggestion.Name)
+	}
+	// Prefix matches go first, then ones of Muse having more songs
+	require.Equal(t,
+		[]string{"Risen", "Rising Sun", "Uprising", "Sunrise"}, names)
+	require.Equal(t, "Muse", suggestions[2].MusicGroupName)
+
+	suggestions, err = repo.GetAutocompleteSuggestions(
+		ctx, "q", domain.AutocompleteKindGroup, 10)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, "Queen", suggestions[0].Name)
+
+	// Short queries are matched by prefix only
+	suggestions, err = repo.GetAutocompleteSuggestions(
+		ctx, "ri", domain.AutocompleteKindSong, 1)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, "Risen", suggestions[0].Name)
+}
+
+func testSuggestion(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	saveSong(t, repo, "Muse", "Uprising", dayDate(2009, 9, 7), "a")
+
+	songName, groupName := "Uprisin", "Mus"
+	suggestion, err := repo.GetSongSearchSuggestion(ctx, &domain.SongFilters{
+		SongName: &songName, MusicGroupName: &groupName})
+	require.NoError(t, err)
+	require.Equal(t, "Uprising", *suggestion.SongName)
+	require.Equal(t, "Muse", *suggestion.MusicGroupName)
+
+	songName = "Bohemian Rhapsody"
+	suggestion, err = repo.GetSongSearchSuggestion(ctx,
+		&domain.SongFilters{SongName: &songName})
+	require.NoError(t, err)
+	require.Nil(t, suggestion)
+}
+
+func testBatch(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	releaseDate := dayDate(2009, 9, 7)
+	uprising := saveSong(t, repo, "Muse", "Uprising", releaseDate, "a")
+	newSong := func(name string) *domain.Song {
+		return &domain.Song{
+			Name:        name,
+			MusicGroup:  domain.MusicGroup{Name: "Muse"},
+			Couplets:    []string{"a"},
+			ReleaseDate: releaseDate,
+		}
+	}
+	staleVersion := int64(5)
+
+	writes := []domain.SongBatchWrite{
+		{Kind: domain.SongBatchOperationCreate, Song: newSong("Resistance")},
+		{Kind: domain.SongBatchOperationCreate, Song: newSong("Uprising")},
+		{Kind: domain.SongBatchOperationDelete,
+			SongID: uprising.ID, Version: &staleVersion},
+	}
+	results, err := repo.ApplySongBatch(ctx, writes, true)
+	require.NoError(t, err)
+	require.ErrorIs(t, results[0].Err, domain.ErrBatchAborted)
+	require.ErrorIs(t, results[1].Err, domain.ErrSongAlreadyExists)
+	require.ErrorIs(t, results[2].Err, domain.ErrBatchAborted)
+	exists, err := repo.SongExistsByNameAndMusicGroupName(ctx, "Resistance", "Muse")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	results, err = repo.ApplySongBatch(ctx, writes, false)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, "Resistance", results[0].Song.Name)
+	require.ErrorIs(t, results[1].Err, domain.ErrSongAlreadyExists)
+	require.ErrorIs(t, results[2].Err, domain.ErrVersionMismatch)
+	exists, err = repo.SongExistsByNameAndMusicGroupName(ctx, "Resistance", "Muse")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	results, err = repo.ApplySongBatch(ctx, []domain.SongBatchWrite{
+		{Kind: domain.SongBatchOperationUpdate, SongID: uprising.ID,
+			Version: &uprising.Version,
+			Update:  &domain.SongUpdate{Couplets: &[]string{"b"}}},
+		{Kind: domain.SongBatchOperationDelete, SongID: results[0].Song.ID},
+	}, true)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, []string{"b"}, results[0].Song.Couplets)
+	require.NoError(t, results[1].Err)
+	require.Nil(t, results[1].Song)
+}
+
+func testForEachSorted(t *testing.T, repo domain.SongRepository) {
+	ctx := context.Background()
+	releaseDate := dayDate(2009, 9, 7)
+	for _, name := range []string{"C", "A", "B"} {
+		saveSong(t, repo, "Muse", name, releaseDate, "a")
+	}
+
+	var ids []ksuid.KSUID
+	err := repo.ForEachSongFiltered(ctx, &domain.SongFilters{},
+		func(song *domain.Song) error {
+			require.Equal(t, []string{"a"}, song.Couplets)
+			ids = append(ids, song.ID)
+			return nil
+		})
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+	require.IsIncreasing(t, []string{
+		ids[0].String(), ids[1].String(), ids[2].String()})
+
+	errStop := context.Canceled
+	err = repo.ForEachSongFiltered(ctx, &domain.SongFilters{},
+		func(*domain.Song) error { return errStop })
+	require.ErrorIs(t, err, errStop)
+}
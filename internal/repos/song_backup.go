@@ -0,0 +1,213 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"song-lib/internal/domain"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+// Backup writes music groups and songs ordered by ID from
+// a single snapshot, sink errors are returned as is
+func (r *SongRepository) Backup(
+	ctx context.Context, sink domain.BackupSink,
+) error {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	query, args, err := sq.
+		Select("mg.id", "mg.name").
+		From("music_groups mg").
+		OrderBy("mg.id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "music groups: build query")
+	}
+	err = forEachCursorRow(ctx, tx, "music_groups_backup", query, args,
+		func(groupModel *musicGroup) error {
+			return sink.WriteMusicGroup(&domain.MusicGroup{
+				ID:   groupModel.ID,
+				Name: groupModel.Name,
+			})
+		})
+	if err != nil {
+		return err
+	}
+
+	query, args, err = selectSongs(nil).
+		OrderBy("s.id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "songs: build query")
+	}
+	return forEachCursorRow(ctx, tx, "songs_backup", query, args,
+		func(songModel *song) error {
+			return sink.WriteSong(songModel.toEntity())
+		})
+}
+
+// Restore saves music groups and songs keeping their IDs in a single
+// transaction, which is rolled back if any of them can't be saved.
+// Conflicts fail with ErrRestoreConflict if policy says so
+func (r *SongRepository) Restore(
+	ctx context.Context, source domain.RestoreSource,
+	policy domain.RestoreConflictPolicy,
+) (*domain.RestoreReport, error) {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	var report domain.RestoreReport
+	for {
+		group, err := source.NextMusicGroup()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read music group")
+		}
+
+		_, created, err := restoreMusicGroup(ctx, tx, group)
+		if err != nil {
+			return nil, errors.Wrapf(err, "restore music group %s", group.ID)
+		}
+		if created {
+			report.MusicGroups++
+		}
+	}
+
+	for {
+		song, err := source.NextSong()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read song")
+		}
+
+		err = restoreSong(ctx, tx, song, policy, &report)
+		if err != nil {
+			return nil, errors.Wrapf(err, "restore song %s", song.ID)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "commit")
+	}
+
+	return &report, nil
+}
+
+// restoreMusicGroup returns ID of the group with the same name if
+// there is one. Group gets new ID if its ID is taken by another group
+func restoreMusicGroup(
+	ctx context.Context, tx *sqlx.Tx, group *domain.MusicGroup,
+) (ksuid.KSUID, bool, error) {
+	query := `
+	WITH
+	insert_music_group AS (
+		INSERT INTO music_groups (id, name)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	)
+	SELECT id, true AS created FROM insert_music_group
+	UNION ALL
+	SELECT id, false AS created FROM music_groups WHERE name = $2`
+
+	var res struct {
+		ID      ksuid.KSUID `db:"id"`
+		Created bool        `db:"created"`
+	}
+	err := tx.GetContext(ctx, &res, query, group.ID, group.Name)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return res.ID, res.Created, errors.Wrap(err, "insert with ID")
+	}
+
+	query = `INSERT INTO music_groups (name) VALUES ($1) RETURNING id`
+	err = tx.GetContext(ctx, &res.ID, query, group.Name)
+	if err != nil {
+		return ksuid.Nil, false, errors.Wrap(err, "insert with new ID")
+	}
+
+	return res.ID, true, nil
+}
+
+func restoreSong(
+	ctx context.Context, tx *sqlx.Tx, song *domain.Song,
+	policy domain.RestoreConflictPolicy, report *domain.RestoreReport,
+) error {
+	groupID, _, err := restoreMusicGroup(ctx, tx, &song.MusicGroup)
+	if err != nil {
+		return errors.Wrap(err, "restore music group")
+	}
+
+	var conflictingIDs pq.StringArray
+	query := `
+	SELECT COALESCE(ARRAY_AGG(id), '{}') FROM songs
+	WHERE id = $1 OR (music_group_id = $2 AND name = $3)`
+	err = tx.GetContext(ctx, &conflictingIDs, query, song.ID, groupID, song.Name)
+	if err != nil {
+		return errors.Wrap(err, "find conflicting songs")
+	}
+	if len(conflictingIDs) > 0 {
+		switch policy {
+		case domain.RestoreConflictSkip:
+			report.Skipped++
+			return nil
+		case domain.RestoreConflictOverwrite:
+			_, err = tx.ExecContext(ctx,
+				`DELETE FROM songs WHERE id = ANY($1::text[])`, conflictingIDs)
+			if err != nil {
+				return errors.Wrap(err, "delete conflicting songs")
+			}
+			report.Overwritten++
+		default:
+			return domain.ErrRestoreConflict
+		}
+	}
+
+	query, args, err := sq.
+		Insert("songs").
+		Columns(
+			"id", "music_group_id", "name",
+			"release_date", "release_date_precision", "link",
+			"created_at", "updated_at", "version").
+		Values(
+			song.ID, groupID, song.Name,
+			song.ReleaseDate.Date, song.ReleaseDate.Precision, nullLink(song.Link),
+			song.CreatedAt, song.UpdatedAt, song.Version).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "insert song: build query")
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "insert song: execute query")
+	}
+
+	err = insertCouplets(ctx, tx, song.ID, song.Couplets)
+	if err != nil {
+		return errors.Wrap(err, "insert couplets")
+	}
+	report.Songs++
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"song-lib/internal/domain"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const pqUniqueViolationCode = "23505"
+
+func (r *SongRepository) ApplySongBatch(
+	ctx context.Context, writes []domain.SongBatchWrite,
+	atomic bool,
+) ([]domain.SongBatchItemResult, error) {
+	var results []domain.SongBatchItemResult
+	err := inTx(ctx, r.db, &sql.TxOptions{Isolation: sql.LevelReadCommitted},
+		func(tx *sqlx.Tx) error {
+			var err error
+			results, err = applySongBatch(ctx, tx, writes, atomic,
+				func(write *domain.SongBatchWrite) (*domain.Song, error) {
+					return applySongBatchWrite(ctx, tx, write)
+				})
+			return err
+		})
+	if err != nil && !errors.Is(err, errAtomicBatchFailed) {
+		return nil, err
+	}
+	r.recordWrite()
+
+	return results, nil
+}
+
+// errAtomicBatchFailed is returned along with results
+// of failed atomic batch, so that its transaction is rolled back
+var errAtomicBatchFailed = errors.New("atomic batch failed")
+
+// applySongBatch applies writes with apply in the transaction
+func applySongBatch(
+	ctx context.Context, tx *sqlx.Tx, writes []domain.SongBatchWrite,
+	atomic bool, apply func(write *domain.SongBatchWrite) (*domain.Song, error),
+) ([]domain.SongBatchItemResult, error) {
+	results := make([]domain.SongBatchItemResult, len(writes))
+	for i := range writes {
+		// Failed write is rolled back to the savepoint,
+		// so that it doesn't abort the whole transaction
+		if !atomic {
+			_, err := tx.ExecContext(ctx, "SAVEPOINT batch_write")
+			if err != nil {
+				return nil, errors.Wrap(err, "create savepoint")
+			}
+		}
+
+		var err error
+		results[i].Song, results[i].Err = apply(&writes[i])
+		switch {
+		case results[i].Err != nil && atomic:
+			for j := range results {
+				if j != i {
+					results[j] = domain.SongBatchItemResult{Err: domain.ErrBatchAborted}
+				}
+			}
+			return results, errAtomicBatchFailed
+		case results[i].Err != nil:
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_write")
+		case !atomic:
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_write")
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "finish savepoint")
+		}
+	}
+
+	return results, nil
+}
+
+func applySongBatchWrite(
+	ctx context.Context, tx *sqlx.Tx, write *domain.SongBatchWrite,
+) (*domain.Song, error) {
+	songID := write.SongID
+	switch write.Kind {
+	case domain.SongBatchOperationCreate:
+		var err error
+		songID, err = saveSong(ctx, tx, write.Song)
+		if isUniqueViolation(err) {
+			return nil, domain.ErrSongAlreadyExists
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "save song")
+		}
+	case domain.SongBatchOperationUpdate:
+		updated, err := updateSong(ctx, tx, songID, write.Version, write.Update)
+		if isUniqueViolation(err) {
+			return nil, domain.ErrSongAlreadyExists
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !updated {
+			return nil, versionMismatchOrNotFound(ctx, tx, songID)
+		}
+	case domain.SongBatchOperationDelete:
+		deleted, err := deleteSong(ctx, tx, songID, write.Version)
+		if err != nil {
+			return nil, errors.Wrap(err, "delete song")
+		}
+		if !deleted {
+			return nil, versionMismatchOrNotFound(ctx, tx, songID)
+		}
+		return nil, nil
+	default:
+		return nil, errors.Errorf("unknown batch operation %s", write.Kind)
+	}
+
+	song, err := getSongByID(ctx, tx, songID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get written song")
+	}
+
+	return song, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolationCode
+}
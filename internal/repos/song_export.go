@@ -0,0 +1,46 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"song-lib/internal/domain"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ForEachSongFiltered calls fn for each filtered song ordered by ID.
+// Songs are read with server-side cursor from a single snapshot,
+// fn errors are returned as is
+func (r *SongRepository) ForEachSongFiltered(
+	ctx context.Context, f *domain.SongFilters,
+	fn func(song *domain.Song) error,
+) error {
+	builder, err := r.filterSongs(selectSongs(nil), f)
+	if err != nil {
+		return errors.Wrap(err, "filter songs")
+	}
+	query, args, err := builder.
+		OrderBy("s.id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	return inTx(ctx, r.db, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead, ReadOnly: true},
+		func(tx *sqlx.Tx) error {
+			if hasFuzzyNameMatch(f) {
+				err := r.setSimilarityThreshold(ctx, tx)
+				if err != nil {
+					return err
+				}
+			}
+			return forEachCursorRow(ctx, tx, "songs_export", query, args,
+				func(songModel *song) error {
+					return fn(songModel.toEntity())
+				})
+		})
+}
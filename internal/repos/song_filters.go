@@ -0,0 +1,174 @@
+package repos
+
+import (
+	"song-lib/internal/domain"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+)
+
+func (r *SongRepository) filterSongs(
+	builder sq.SelectBuilder, f *domain.SongFilters,
+) (sq.SelectBuilder, error) {
+	if f.SongName != nil {
+		builder = builder.Where(r.nameMatchCondition(
+			"s.name", *f.SongName, f.NameMatchMode))
+	}
+	if f.SongLink != nil {
+		builder = builder.Where(linkEqCondition(*f.SongLink))
+	}
+	if f.MusicGroupName != nil {
+		builder = builder.Where(r.nameMatchCondition(
+			"mg.name", *f.MusicGroupName, f.NameMatchMode))
+	}
+	if f.SongReleaseDateRange != nil {
+		builder = builder.Where(timeRangeCondition(
+			"s.release_date", "s.release_date_last_day",
+			f.SongReleaseDateRange))
+	}
+	if f.SongCoupletContains != nil {
+		songWithTextIDsSubquery := sq.
+			Select("sc.song_id").
+			From("song_couplets sc").
+			Where(sq.Expr(
+				"sc.text ILIKE '%' || escape_like_string(?) || '%'",
+				*f.SongCoupletContains))
+
+		builder = builder.Where(inConditionWithSubquery(
+			"s.id", songWithTextIDsSubquery,
+		))
+	}
+	if f.Expression != nil {
+		condition, err := compileFilterExpression(f.Expression, postgresDialect{})
+		if err != nil {
+			return builder, errors.Wrap(err, "compile filter expression")
+		}
+		builder = builder.Where(condition)
+	}
+
+	return builder, nil
+}
+
+// hasFuzzyNameMatch tells whether filters match names fuzzily
+func hasFuzzyNameMatch(f *domain.SongFilters) bool {
+	return f.NameMatchMode == domain.MatchModeFuzzy &&
+		(f.SongName != nil || f.MusicGroupName != nil)
+}
+
+// timeRangeCondition matches periods [startColumn; endColumn]
+// overlapping the time range
+func timeRangeCondition(
+	startColumn, endColumn string, r *domain.TimeRange,
+) sq.Sqlizer {
+	condition := sq.And{}
+	switch {
+	case r.StartTime == nil:
+	case r.StartTimeExclusive:
+		condition = append(condition, sq.Gt{endColumn: *r.StartTime})
+	default:
+		condition = append(condition, sq.GtOrEq{endColumn: *r.StartTime})
+	}
+	switch {
+	case r.EndTime == nil:
+	case r.EndTimeExclusive:
+		condition = append(condition, sq.Lt{startColumn: *r.EndTime})
+	default:
+		condition = append(condition, sq.LtOrEq{startColumn: *r.EndTime})
+	}
+
+	return condition
+}
+
+// nameMatchCondition matches names fuzzily with pg_trgm % operator,
+// as unlike similarity function it can use trigram indexes. Its
+// threshold is to be set with setSimilarityThreshold
+func (r *SongRepository) nameMatchCondition(
+	column, name string, matchMode domain.MatchMode,
+) sq.Sqlizer {
+	switch matchMode {
+	case domain.MatchModePrefix:
+		return sq.Expr(
+			column+" ILIKE escape_like_string(?) || '%'", name)
+	case domain.MatchModeFuzzy:
+		return sq.Expr(column+" % ?", name)
+	default:
+		return sq.Eq{column: name}
+	}
+}
+
+var songSortColumns = map[domain.SongSortField]struct {
+	column string
+	value  func(s *song) any
+}{
+	domain.SongSortFieldName: {
+		"s.name", func(s *song) any { return s.Name }},
+	domain.SongSortFieldGroupName: {
+		"mg.name", func(s *song) any { return s.MusicGroup.Name }},
+	domain.SongSortFieldReleaseDate: {
+		"s.release_date", func(s *song) any { return s.ReleaseDate }},
+	domain.SongSortFieldCreatedAt: {
+		"s.created_at", func(s *song) any { return s.CreatedAt }},
+	domain.SongSortFieldUpdatedAt: {
+		"s.updated_at", func(s *song) any { return s.UpdatedAt }},
+}
+
+// songSortKeys returns sort keys for the sort options in the
+// given order, song ID is always the last key to make order stable.
+// Relevance is the expression songs are sorted by relevance with
+func songSortKeys(
+	sort []domain.SongSortOption, relevance sq.Sqlizer,
+) ([]sortKey, error) {
+	sortKeys := make([]sortKey, 0, len(sort)+1)
+	for _, sortOption := range sort {
+		if sortOption.Field == domain.SongSortFieldRelevance {
+			sortKeys = append(sortKeys, sortKey{
+				expr:       relevance,
+				descending: sortOption.Descending,
+				value:      func(s any) any { return *s.(*song).Relevance },
+			})
+			continue
+		}
+
+		sortColumn, ok := songSortColumns[sortOption.Field]
+		if !ok {
+			return nil, errors.Errorf(
+				"unknown sort field %s", sortOption.Field)
+		}
+		sortKeys = append(sortKeys, sortKey{
+			expr:       sq.Expr(sortColumn.column),
+			descending: sortOption.Descending,
+			value:      func(s any) any { return sortColumn.value(s.(*song)) },
+		})
+	}
+
+	return append(sortKeys, sortKey{
+		expr:  sq.Expr("s.id"),
+		value: func(s any) any { return s.(*song).ID },
+	}), nil
+}
+
+func hasRelevanceSortKey(sort []domain.SongSortOption) bool {
+	for _, sortOption := range sort {
+		if sortOption.Field == domain.SongSortFieldRelevance {
+			return true
+		}
+	}
+	return false
+}
+
+// songRelevance is the sum of similarities of song
+// and music group names to the ones from filters.
+// It is float8 so that its values survive cursor round trip
+func songRelevance(f *domain.SongFilters) sq.Sqlizer {
+	relevance := sq.Expr("0")
+	if f.SongName != nil {
+		relevance = sq.Expr("? + similarity(s.name, ?)",
+			relevance, *f.SongName)
+	}
+	if f.MusicGroupName != nil {
+		relevance = sq.Expr("? + similarity(mg.name, ?)",
+			relevance, *f.MusicGroupName)
+	}
+
+	return sq.Expr("(?)::float8", relevance)
+}
@@ -3,7 +3,9 @@ package repos
 import (
 	"context"
 	"database/sql"
+	"maps"
 	"song-lib/internal/domain"
+	"strconv"
 	"time"
 
 	"github.com/Masterminds/squirrel"
@@ -16,15 +18,42 @@ import (
 
 type SongRepository struct {
 	db *sqlx.DB
+	// reads is nil if reads are not routed
+	reads               ReadRouter
+	similarityThreshold float64
+}
+
+// ReadRouter picks connections for reads tolerating replication lag
+type ReadRouter interface {
+	// Reader returns a replica or the primary
+	Reader() *sqlx.DB
+	// RecordWrite makes reads go to the primary for a while
+	RecordWrite()
+	// Fallback tells whether read failed with
+	// err on db is to be made on the primary
+	Fallback(db *sqlx.DB, err error) bool
 }
 
 type song struct {
-	ID          ksuid.KSUID    `db:"id"`
-	Name        string         `db:"name"`
-	MusicGroup  musicGroup     `db:"music_group"`
-	Couplets    pq.StringArray `db:"couplets"`
-	ReleaseDate time.Time      `db:"release_date"`
-	Link        string         `db:"link"`
+	ID          ksuid.KSUID `db:"id"`
+	Name        string      `db:"name"`
+	MusicGroup  musicGroup  `db:"music_group"`
+	Couplets    stringArray `db:"couplets"`
+	ReleaseDate time.Time   `db:"release_date"`
+	// ReleaseDatePrecision is domain.DatePrecision
+	ReleaseDatePrecision string `db:"release_date_precision"`
+	// Link is NULL in Postgres and empty in SQLite if there is none
+	Link      sql.NullString `db:"link"`
+	CreatedAt time.Time      `db:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at"`
+	Version   int64          `db:"version"`
+	// Relevance is selected only when songs are sorted by it
+	Relevance *float64 `db:"relevance"`
+}
+
+type couplet struct {
+	Num  int    `db:"couplet_num"`
+	Text string `db:"text"`
 }
 
 type musicGroup struct {
@@ -32,9 +61,39 @@ type musicGroup struct {
 	Name string      `db:"name"`
 }
 
+type autocompleteSuggestion struct {
+	ID             ksuid.KSUID `db:"id"`
+	Name           string      `db:"name"`
+	MusicGroupName string      `db:"music_group_name"`
+}
+
+// Trigram index is of no use for shorter queries,
+// so they are matched by prefix only
+const autocompleteMinInfixQueryLen = 3
+
 func (r *SongRepository) SaveSong(
 	ctx context.Context, song *domain.Song,
 ) (*domain.Song, error) {
+	var resSong *domain.Song
+	err := inTx(ctx, r.db, &sql.TxOptions{Isolation: sql.LevelReadCommitted},
+		func(tx *sqlx.Tx) error {
+			songID, err := saveSong(ctx, tx, song)
+			if err != nil {
+				return err
+			}
+			resSong, err = getSongByID(ctx, tx, songID)
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+	r.recordWrite()
+	return resSong, nil
+}
+
+func saveSong(
+	ctx context.Context, q sqlx.QueryerContext, song *domain.Song,
+) (ksuid.KSUID, error) {
 	query := `
 	WITH 
 	upsert_music_group AS (
@@ -49,8 +108,12 @@ func (r *SongRepository) SaveSong(
 		SELECT id FROM music_groups WHERE name = $1
 	),
 	insert_song AS (
-		INSERT INTO songs (id, music_group_id, name, release_date, link)
-		VALUES (DEFAULT, (SELECT id FROM music_group_id), $2, $3, $4)
+		INSERT INTO songs (
+			id, music_group_id, name,
+			release_date, release_date_precision, link)
+		VALUES (
+			DEFAULT, (SELECT id FROM music_group_id), $2,
+			$3, $4, $5)
 		RETURNING id
 	)
 	INSERT INTO 
@@ -60,29 +123,38 @@ func (r *SongRepository) SaveSong(
 		ROW_NUMBER() OVER () AS couplet_num,
 		text
 	FROM 
-		UNNEST($5::text[]) AS t(text)
+		UNNEST($6::text[]) AS t(text)
 	RETURNING 
 		song_id`
 
 	var songID ksuid.KSUID
-	err := r.db.QueryRowxContext(
+	err := q.QueryRowxContext(
 		ctx,
 		query, song.MusicGroup.Name, song.Name,
-		song.ReleaseDate, song.Link, pq.Array(song.Couplets),
+		song.ReleaseDate.Date, song.ReleaseDate.Precision,
+		nullLink(song.Link), pq.Array(song.Couplets),
 	).Scan(&songID)
 	if err != nil {
-		return nil, errors.Wrap(err, "execute query")
+		return ksuid.Nil, errors.Wrap(err, "execute query")
 	}
 
-	resSong, err := r.GetSongByID(ctx, songID)
-	if err != nil {
-		return nil, err
-	}
-	return resSong, nil
+	return songID, nil
 }
 
 func (r *SongRepository) SongExistsByID(
 	ctx context.Context, songID ksuid.KSUID,
+) (bool, error) {
+	var exists bool
+	err := r.readReplica(ctx, func(q sqlx.ExtContext) error {
+		var err error
+		exists, err = songExistsByID(ctx, q, songID)
+		return err
+	})
+	return exists, err
+}
+
+func songExistsByID(
+	ctx context.Context, q sqlx.QueryerContext, songID ksuid.KSUID,
 ) (bool, error) {
 	query, args, err := sq.
 		Select("1").
@@ -97,7 +169,7 @@ func (r *SongRepository) SongExistsByID(
 	}
 
 	var exists bool
-	err = r.db.GetContext(ctx, &exists, query, args...)
+	err = sqlx.GetContext(ctx, q, &exists, query, args...)
 	if err != nil {
 		return false, errors.Wrap(err, "execute query")
 	}
@@ -125,7 +197,9 @@ func (r *SongRepository) SongExistsByNameAndMusicGroupName(
 	}
 
 	var exists bool
-	err = r.db.GetContext(ctx, &exists, query, args...)
+	err = r.readReplica(ctx, func(q sqlx.ExtContext) error {
+		return sqlx.GetContext(ctx, q, &exists, query, args...)
+	})
 	if err != nil {
 		return false, errors.Wrap(err, "execute query")
 	}
@@ -136,23 +210,13 @@ func (r *SongRepository) SongExistsByNameAndMusicGroupName(
 func (r *SongRepository) GetSongByID(
 	ctx context.Context, songID ksuid.KSUID,
 ) (*domain.Song, error) {
-	coupletsSubquery := sq.
-		Select("ARRAY_AGG(sc.text ORDER BY sc.couplet_num)").
-		From("song_couplets sc").
-		Where("sc.song_id = s.id")
+	return getSongByID(ctx, conn(ctx, r.db), songID)
+}
 
-	query, args, err := sq.
-		Select(
-			"s.id",
-			"s.name",
-			"s.release_date",
-			"s.link",
-			`mg.id AS "music_group.id"`,
-			`mg.name AS "music_group.name"`,
-		).
-		Column(sq.Alias(coupletsSubquery, "couplets")).
-		From("songs s").
-		LeftJoin("music_groups mg ON s.music_group_id = mg.id").
+func getSongByID(
+	ctx context.Context, q sqlx.QueryerContext, songID ksuid.KSUID,
+) (*domain.Song, error) {
+	query, args, err := selectSongs(nil).
 		Where(squirrel.Eq{"s.id": songID}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
@@ -161,8 +225,37 @@ func (r *SongRepository) GetSongByID(
 	}
 
 	var songModel song
-	err = r.db.GetContext(ctx, &songModel, query, args...)
+	err = sqlx.GetContext(ctx, q, &songModel, query, args...)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, domain.ErrSongNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return songModel.toEntity(), nil
+}
+
+func (r *SongRepository) GetSongByNameAndMusicGroupName(
+	ctx context.Context, songName, musicGroupName string,
+) (*domain.Song, error) {
+	query, args, err := selectSongs(nil).
+		Where(sq.And{
+			sq.Eq{"s.name": songName},
+			sq.Eq{"mg.name": musicGroupName},
+		}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
 	if err != nil {
+		return nil, errors.Wrap(err, "build query")
+	}
+
+	var songModel song
+	err = sqlx.GetContext(ctx, conn(ctx, r.db), &songModel, query, args...)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, domain.ErrSongNotFound
+	case err != nil:
 		return nil, errors.Wrap(err, "execute query")
 	}
 
@@ -171,55 +264,193 @@ func (r *SongRepository) GetSongByID(
 
 func (r *SongRepository) GetSongsFilteredPaginated(
 	ctx context.Context, f *domain.SongFilters,
-	pagination domain.Pagination,
-) ([]domain.Song, error) {
-	coupletsSubquery := sq.
-		Select("ARRAY_AGG(sc.text ORDER BY sc.couplet_num)").
-		From("song_couplets sc").
-		Where("sc.song_id = s.id")
+	sort []domain.SongSortOption, pagination domain.Pagination,
+	fields domain.SongFieldSet,
+) (*domain.SongsPage, error) {
+	sortKeys, err := songSortKeys(sort, songRelevance(f))
+	if err != nil {
+		return nil, errors.Wrap(err, "build sort keys")
+	}
 
-	builder := sq.
-		Select(
-			"s.id",
-			"s.name",
-			"s.release_date",
-			"s.link",
-			`mg.id AS "music_group.id"`,
-			`mg.name AS "music_group.name"`,
-		).
-		Column(sq.Alias(coupletsSubquery, "couplets")).
-		From("songs s").
-		LeftJoin("music_groups mg ON s.music_group_id = mg.id").
-		OrderBy("s.id").
-		Limit(uint64(pagination.PerPage)).
-		Offset(uint64(pagination.Page * pagination.PerPage))
+	// Sort key values are needed for cursors
+	// even if they are not requested
+	if fields != nil && pagination.Cursor != nil {
+		fields = maps.Clone(fields)
+		for _, sortOption := range sort {
+			if field, ok := sortOption.Field.SongField(); ok {
+				fields[field] = true
+			}
+		}
+	}
+	builder, err := r.filterSongs(selectSongs(fields), f)
+	if err != nil {
+		return nil, errors.Wrap(err, "filter songs")
+	}
+	if pagination.Cursor == nil {
+		builder = orderBySortKeys(builder, sortKeys, false).
+			Limit(uint64(pagination.PerPage)).
+			Offset(uint64(pagination.Page * pagination.PerPage))
+	} else {
+		builder = paginateByCursor(
+			builder, sortKeys, pagination.Cursor, pagination.PerPage)
+		if hasRelevanceSortKey(sort) {
+			builder = builder.Column(sq.Alias(songRelevance(f), "relevance"))
+		}
+	}
 
-	if f.SongName != nil {
-		builder = builder.Where(sq.Eq{"s.name": *f.SongName})
+	query, args, err := builder.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "build query")
+	}
+
+	var songModels []song
+	err = r.readMatchingNames(ctx, hasFuzzyNameMatch(f), func(q sqlx.ExtContext) error {
+		songModels = nil
+		return sqlx.SelectContext(ctx, q, &songModels, query, args...)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
 	}
-	if f.SongLink != nil {
-		builder = builder.Where(sq.Eq{"s.link": *f.SongLink})
+
+	var songsPage domain.SongsPage
+	if pagination.Cursor != nil {
+		songModels, songsPage.NextCursor, songsPage.PrevCursor = cursorPage(
+			songModels, pagination.PerPage, pagination.Cursor,
+			func(songModel song) []any {
+				return sortKeyValues(sortKeys, &songModel)
+			})
 	}
-	if f.MusicGroupName != nil {
-		builder = builder.Where(sq.Eq{"mg.name": *f.MusicGroupName})
+
+	songsPage.Songs = make([]domain.Song, 0, len(songModels))
+	for _, songModel := range songModels {
+		songsPage.Songs = append(songsPage.Songs, *songModel.toEntity())
+	}
+
+	return &songsPage, nil
+}
+
+func (r *SongRepository) CountSongsFiltered(
+	ctx context.Context, f *domain.SongFilters, estimated bool,
+) (int, error) {
+	builder, err := r.filterSongs(
+		sq.Select("COUNT(*)").
+			From("songs s").
+			LeftJoin("music_groups mg ON s.music_group_id = mg.id"),
+		f)
+	if err != nil {
+		return 0, errors.Wrap(err, "filter songs")
+	}
+	if estimated {
+		builder = builder.Prefix("EXPLAIN (FORMAT JSON)")
 	}
-	if f.SongReleaseDateRange != nil {
-		builder = builder.Where(sq.And{
-			sq.GtOrEq{"s.release_date": f.SongReleaseDateRange.StartTime},
-			sq.LtOrEq{"s.release_date": f.SongReleaseDateRange.EndTime},
+
+	query, args, err := builder.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "build query")
+	}
+
+	fuzzy := hasFuzzyNameMatch(f)
+	if !estimated {
+		var count int
+		err = r.readMatchingNames(ctx, fuzzy, func(q sqlx.ExtContext) error {
+			return sqlx.GetContext(ctx, q, &count, query, args...)
 		})
+		if err != nil {
+			return 0, errors.Wrap(err, "execute query")
+		}
+		return count, nil
+	}
+
+	var plan []byte
+	err = r.readMatchingNames(ctx, fuzzy, func(q sqlx.ExtContext) error {
+		return sqlx.GetContext(ctx, q, &plan, query, args...)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "execute query")
+	}
+	count, err := estimatedRowsCount(plan)
+	if err != nil {
+		return 0, errors.Wrap(err, "get estimated rows count")
+	}
+
+	return count, nil
+}
+
+func (r *SongRepository) GetSongSearchSuggestion(
+	ctx context.Context, f *domain.SongFilters,
+) (*domain.SongSearchSuggestion, error) {
+	var (
+		suggestion domain.SongSearchSuggestion
+		err        error
+	)
+	if f.SongName != nil {
+		suggestion.SongName, err = r.getMostSimilarName(
+			ctx, "songs", *f.SongName)
+		if err != nil {
+			return nil, errors.Wrap(err, "get most similar song name")
+		}
+	}
+	if f.MusicGroupName != nil {
+		suggestion.MusicGroupName, err = r.getMostSimilarName(
+			ctx, "music_groups", *f.MusicGroupName)
+		if err != nil {
+			return nil, errors.Wrap(err, "get most similar music group name")
+		}
+	}
+	if suggestion.SongName == nil && suggestion.MusicGroupName == nil {
+		return nil, nil
+	}
+
+	return &suggestion, nil
+}
+
+func (r *SongRepository) getMostSimilarName(
+	ctx context.Context, table, name string,
+) (*string, error) {
+	query, args, err := sq.
+		Select("t.name").
+		From(table+" t").
+		Where(sq.Expr("t.name % ?", name)).
+		OrderByClause("t.name <-> ?", name).
+		OrderBy("t.name").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "build query")
 	}
-	if f.SongCoupletContains != nil {
-		songWithTextIDsSubquery := sq.
-			Select("sc.song_id").
-			From("song_couplets sc").
-			Where(sq.Expr(
-				"sc.text ILIKE '%' || escape_like_string(?) || '%'",
-				*f.SongCoupletContains))
 
-		builder = builder.Where(inConditionWithSubquery(
-			"s.id", songWithTextIDsSubquery,
-		))
+	var similarName string
+	err = r.readMatchingNames(ctx, true, func(q sqlx.ExtContext) error {
+		return sqlx.GetContext(ctx, q, &similarName, query, args...)
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return &similarName, nil
+}
+
+func (r *SongRepository) GetSongCoupletsPaginated(
+	ctx context.Context, songID ksuid.KSUID,
+	pagination domain.Pagination,
+) (*domain.CoupletsPage, error) {
+	builder := sq.
+		Select("sc.couplet_num", "sc.text").
+		From("song_couplets sc").
+		Where(sq.Eq{"sc.song_id": songID})
+
+	sortKeys := []sortKey{coupletNumSortKey}
+	if pagination.Cursor == nil {
+		builder = orderBySortKeys(builder, sortKeys, false).
+			Limit(uint64(pagination.PerPage)).
+			Offset(uint64(pagination.Page * pagination.PerPage))
+	} else {
+		builder = paginateByCursor(
+			builder, sortKeys, pagination.Cursor, pagination.PerPage)
 	}
 
 	query, args, err := builder.PlaceholderFormat(sq.Dollar).ToSql()
@@ -227,78 +458,257 @@ func (r *SongRepository) GetSongsFilteredPaginated(
 		return nil, errors.Wrap(err, "build query")
 	}
 
-	var songModels []song
-	err = r.db.SelectContext(ctx, &songModels, query, args...)
+	var coupletModels []couplet
+	err = r.readReplica(ctx, func(q sqlx.ExtContext) error {
+		coupletModels = nil
+		return sqlx.SelectContext(ctx, q, &coupletModels, query, args...)
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "execute query")
 	}
 
-	songs := make([]domain.Song, 0, len(songModels))
-	for _, songModel := range songModels {
-		songs = append(songs, *songModel.toEntity())
+	var coupletsPage domain.CoupletsPage
+	if pagination.Cursor != nil {
+		coupletModels, coupletsPage.NextCursor, coupletsPage.PrevCursor = cursorPage(
+			coupletModels, pagination.PerPage, pagination.Cursor,
+			func(coupletModel couplet) []any {
+				return sortKeyValues(sortKeys, &coupletModel)
+			})
+	}
+
+	coupletsPage.Couplets = make([]string, 0, len(coupletModels))
+	for _, coupletModel := range coupletModels {
+		coupletsPage.Couplets = append(coupletsPage.Couplets, coupletModel.Text)
 	}
 
-	return songs, nil
+	return &coupletsPage, nil
 }
 
-func (r *SongRepository) GetSongCoupletsPaginated(
+func (r *SongRepository) CountSongCouplets(
 	ctx context.Context, songID ksuid.KSUID,
-	pagination domain.Pagination,
-) ([]string, error) {
+) (int, error) {
 	query, args, err := sq.
-		Select("sc.text").
+		Select("COUNT(*)").
 		From("song_couplets sc").
 		Where(sq.Eq{"sc.song_id": songID}).
-		OrderBy("sc.couplet_num").
-		Limit(uint64(pagination.PerPage)).
-		Offset(uint64(pagination.Page * pagination.PerPage)).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "build query")
+	}
+
+	var count int
+	err = r.readReplica(ctx, func(q sqlx.ExtContext) error {
+		return sqlx.GetContext(ctx, q, &count, query, args...)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "execute query")
+	}
+
+	return count, nil
+}
+
+// GetAutocompleteSuggestions returns names containing the query,
+// prefix matches go first, then ones with more popular music groups
+// (popularity is the number of songs in the music group)
+func (r *SongRepository) GetAutocompleteSuggestions(
+	ctx context.Context, query string,
+	kind domain.AutocompleteKind, limit int,
+) ([]domain.AutocompleteSuggestion, error) {
+	sqlQuery, args, err := autocompleteQuery(query, kind, limit)
 	if err != nil {
 		return nil, errors.Wrap(err, "build query")
 	}
 
-	var couplets []string
-	err = r.db.SelectContext(ctx, &couplets, query, args...)
+	var suggestionModels []autocompleteSuggestion
+	err = sqlx.SelectContext(ctx, conn(ctx, r.db), &suggestionModels, sqlQuery, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "execute query")
 	}
 
-	return couplets, nil
+	suggestions := make([]domain.AutocompleteSuggestion, 0, len(suggestionModels))
+	for _, suggestionModel := range suggestionModels {
+		suggestions = append(suggestions, domain.AutocompleteSuggestion(suggestionModel))
+	}
+
+	return suggestions, nil
+}
+
+// autocompleteQuery matches names by prefix with lower(name) index
+// or by infix with trigram index, popularity is read from song_count
+// music groups keep, so candidates cost no song counting
+func autocompleteQuery(
+	query string, kind domain.AutocompleteKind, limit int,
+) (string, []any, error) {
+	var popularity string
+	builder := sq.Select("t.id", "t.name")
+	switch kind {
+	case domain.AutocompleteKindSong:
+		builder = builder.
+			Column("mg.name AS music_group_name").
+			From("songs t").
+			Join("music_groups mg ON t.music_group_id = mg.id")
+		popularity = "mg.song_count"
+	case domain.AutocompleteKindGroup:
+		builder = builder.
+			Column("'' AS music_group_name").
+			From("music_groups t")
+		popularity = "t.song_count"
+	default:
+		return "", nil, errors.Errorf("unknown autocomplete kind %s", kind)
+	}
+
+	if len([]rune(query)) < autocompleteMinInfixQueryLen {
+		builder = builder.Where(sq.Expr(
+			"lower(t.name) LIKE lower(escape_like_string(?)) || '%'", query))
+	} else {
+		builder = builder.Where(sq.Expr(
+			"t.name ILIKE '%' || escape_like_string(?) || '%'", query))
+	}
+
+	return builder.
+		OrderByClause(
+			"(lower(t.name) LIKE lower(escape_like_string(?)) || '%') DESC",
+			query).
+		OrderBy(popularity+" DESC", "t.name").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
 }
 
 func (r *SongRepository) UpdateSong(
 	ctx context.Context, songID ksuid.KSUID,
-	songUpdate *domain.SongUpdate,
+	version *int64, songUpdate *domain.SongUpdate,
 ) (*domain.Song, error) {
+	var song *domain.Song
+	err := inTx(ctx, r.db, &sql.TxOptions{Isolation: sql.LevelReadCommitted},
+		func(tx *sqlx.Tx) error {
+			updated, err := updateSong(ctx, tx, songID, version, songUpdate)
+			if err != nil {
+				return err
+			}
+			if !updated {
+				return versionMismatchOrNotFound(ctx, tx, songID)
+			}
+
+			song, err = getSongByID(ctx, tx, songID)
+			return errors.Wrap(err, "get updated song")
+		})
+	if err != nil {
+		return nil, err
+	}
+	r.recordWrite()
+
+	return song, nil
+}
+
+func (r *SongRepository) PatchSong(
+	ctx context.Context, songID ksuid.KSUID,
+	version *int64, patch domain.SongPatchFunc,
+) (*domain.Song, error) {
+	var song *domain.Song
+	err := inTx(ctx, r.db, &sql.TxOptions{Isolation: sql.LevelReadCommitted},
+		func(tx *sqlx.Tx) error {
+			var err error
+			song, err = patchSong(ctx, tx, songID, version, patch)
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+	r.recordWrite()
+
+	return song, nil
+}
+
+func patchSong(
+	ctx context.Context, tx *sqlx.Tx, songID ksuid.KSUID,
+	version *int64, patch domain.SongPatchFunc,
+) (*domain.Song, error) {
+	// Song is locked till commit, so that concurrent
+	// patches are applied one after another
+	query, args, err := selectSongs(nil).
+		Where(sq.Eq{"s.id": songID}).
+		Suffix("FOR UPDATE OF s").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "select song: build query")
+	}
+
+	var songModel song
+	err = tx.GetContext(ctx, &songModel, query, args...)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, domain.ErrSongNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "select song: execute query")
+	}
+	if version != nil && songModel.Version != *version {
+		return nil, domain.ErrVersionMismatch
+	}
+
+	// Patch errors are returned as is for caller to handle
+	songUpdate, err := patch(songModel.toEntity())
+	if err != nil {
+		return nil, err
+	}
+
+	// Song is locked, so it can't be changed since select
+	_, err = updateSong(ctx, tx, songID, nil, songUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	song, err := getSongByID(ctx, tx, songID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get patched song")
+	}
+
+	return song, nil
+}
+
+// updateSong returns false if there is no song with the
+// given ID and version, nil version matches any
+func updateSong(
+	ctx context.Context, e sqlx.ExecerContext, songID ksuid.KSUID,
+	version *int64, songUpdate *domain.SongUpdate,
+) (bool, error) {
 	builder := sq.
 		Update("songs").
+		Set("updated_at", sq.Expr("now()")).
+		Set("version", sq.Expr("version + 1")).
 		Where(sq.Eq{"id": songID})
+	if version != nil {
+		builder = builder.Where(sq.Eq{"version": *version})
+	}
 	if songUpdate.Name != nil {
 		builder = builder.Set("name", *songUpdate.Name)
 	}
 	if songUpdate.ReleaseDate != nil {
-		builder = builder.Set("release_date", *songUpdate.ReleaseDate)
+		builder = builder.
+			Set("release_date", songUpdate.ReleaseDate.Date).
+			Set("release_date_precision", songUpdate.ReleaseDate.Precision)
 	}
 	if songUpdate.Link != nil {
-		builder = builder.Set("link", *songUpdate.Link)
+		builder = builder.Set("link", nullLink(*songUpdate.Link))
 	}
 
 	query, args, err := builder.PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
-		return nil, errors.Wrap(err, "update songs table: build query")
+		return false, errors.Wrap(err, "update songs table: build query")
 	}
 
-	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelReadCommitted})
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-	_, err = tx.ExecContext(ctx, query, args...)
+	res, err := e.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, errors.Wrap(err, "update songs table: execute query")
+		return false, errors.Wrap(err, "update songs table: execute query")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "update songs table: get rows affected")
+	}
+	if rowsAffected == 0 {
+		return false, nil
 	}
 
 	if songUpdate.Couplets != nil {
@@ -307,63 +717,178 @@ func (r *SongRepository) UpdateSong(
 			Where(sq.Eq{"song_id": songID}).
 			PlaceholderFormat(sq.Dollar).ToSql()
 		if err != nil {
-			return nil, errors.Wrap(err,
+			return false, errors.Wrap(err,
 				"delete old couplets from song_couplets table: build query")
 		}
 
-		_, err = tx.ExecContext(ctx, query, args...)
+		_, err = e.ExecContext(ctx, query, args...)
 		if err != nil {
-			return nil, errors.Wrap(err,
+			return false, errors.Wrap(err,
 				"delete old couplets from song_couplets table: execute query")
 		}
 
-		query = `
-		INSERT INTO 
-			song_couplets (song_id, couplet_num, text)
-		SELECT
-			$1 AS song_id,
-			ROW_NUMBER() OVER () AS couplet_num,
-			text
-		FROM 
-			UNNEST($2::text[]) AS t(text)`
-
-		_, err = tx.ExecContext(ctx, query, songID, pq.Array(*(songUpdate.Couplets)))
+		err = insertCouplets(ctx, e, songID, *songUpdate.Couplets)
 		if err != nil {
-			return nil, errors.Wrap(err,
-				"create new couplets in couplets table: execute query")
+			return false, errors.Wrap(err,
+				"create new couplets in couplets table")
 		}
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return nil, errors.Wrap(err, "commit")
-	}
+	return true, nil
+}
+
+func insertCouplets(
+	ctx context.Context, e sqlx.ExecerContext,
+	songID ksuid.KSUID, couplets []string,
+) error {
+	query := `
+	INSERT INTO 
+		song_couplets (song_id, couplet_num, text)
+	SELECT
+		$1 AS song_id,
+		ROW_NUMBER() OVER () AS couplet_num,
+		text
+	FROM 
+		UNNEST($2::text[]) AS t(text)`
 
-	song, err := r.GetSongByID(ctx, songID)
+	_, err := e.ExecContext(ctx, query, songID, pq.Array(couplets))
 	if err != nil {
-		return nil, errors.Wrap(err, "get updated song")
+		return errors.Wrap(err, "execute query")
 	}
 
-	return song, nil
+	return nil
 }
 
 func (r *SongRepository) DeleteSong(
 	ctx context.Context, songID ksuid.KSUID,
+	version *int64,
 ) error {
-	query, args, err := sq.
+	deleted, err := deleteSong(ctx, conn(ctx, r.db), songID, version)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return versionMismatchOrNotFound(ctx, conn(ctx, r.db), songID)
+	}
+	r.recordWrite()
+
+	return nil
+}
+
+// readReplica makes read tolerating replication lag on the connection
+// the router picks, the primary is used if the replica fails.
+// Transaction of the context is used if there is one
+func (r *SongRepository) readReplica(
+	ctx context.Context, read func(q sqlx.ExtContext) error,
+) error {
+	if r.reads == nil || txFromContext(ctx) != nil {
+		return read(conn(ctx, r.db))
+	}
+
+	db := r.reads.Reader()
+	err := read(db)
+	if ctx.Err() == nil && r.reads.Fallback(db, err) {
+		return read(r.db)
+	}
+	return err
+}
+
+// readMatchingNames is readReplica for queries which match names
+// fuzzily if fuzzy is set. Such queries are made in a transaction,
+// so that the similarity threshold they need is set for them only
+func (r *SongRepository) readMatchingNames(
+	ctx context.Context, fuzzy bool, read func(q sqlx.ExtContext) error,
+) error {
+	if !fuzzy {
+		return r.readReplica(ctx, read)
+	}
+
+	return r.readReplica(ctx, func(q sqlx.ExtContext) error {
+		readInTx := func(tx *sqlx.Tx) error {
+			err := r.setSimilarityThreshold(ctx, tx)
+			if err != nil {
+				return err
+			}
+			return read(tx)
+		}
+
+		switch q := q.(type) {
+		case *sqlx.Tx:
+			return readInTx(q)
+		case *sqlx.DB:
+			return inTx(ctx, q, &sql.TxOptions{ReadOnly: true}, readInTx)
+		default:
+			return errors.Errorf("unexpected connection type %T", q)
+		}
+	})
+}
+
+// setSimilarityThreshold sets threshold of
+// pg_trgm % operator till the end of transaction
+func (r *SongRepository) setSimilarityThreshold(
+	ctx context.Context, e sqlx.ExecerContext,
+) error {
+	_, err := e.ExecContext(ctx,
+		"SELECT set_config('pg_trgm.similarity_threshold', $1, true)",
+		strconv.FormatFloat(r.similarityThreshold, 'f', -1, 64))
+	return errors.Wrap(err, "set similarity threshold")
+}
+
+// recordWrite makes reads see the write
+func (r *SongRepository) recordWrite() {
+	if r.reads != nil {
+		r.reads.RecordWrite()
+	}
+}
+
+// deleteSong returns false if there is no song with the
+// given ID and version, nil version matches any
+func deleteSong(
+	ctx context.Context, e sqlx.ExecerContext,
+	songID ksuid.KSUID, version *int64,
+) (bool, error) {
+	builder := sq.
 		Delete("songs").
-		Where(sq.Eq{"id": songID}).
-		PlaceholderFormat(sq.Dollar).ToSql()
+		Where(sq.Eq{"id": songID})
+	if version != nil {
+		builder = builder.Where(sq.Eq{"version": *version})
+	}
+	query, args, err := builder.PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
-		return errors.Wrap(err, "build query")
+		return false, errors.Wrap(err, "build query")
 	}
 
-	_, err = r.db.ExecContext(ctx, query, args...)
+	res, err := e.ExecContext(ctx, query, args...)
 	if err != nil {
-		return errors.Wrap(err, "execute query")
+		return false, errors.Wrap(err, "execute query")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "get rows affected")
 	}
 
-	return nil
+	return rowsAffected > 0, nil
+}
+
+// versionMismatchOrNotFound tells why song with
+// the expected version was not changed
+func versionMismatchOrNotFound(
+	ctx context.Context, q sqlx.QueryerContext, songID ksuid.KSUID,
+) error {
+	exists, err := songExistsByID(ctx, q, songID)
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "check song exists")
+	case exists:
+		return domain.ErrVersionMismatch
+	default:
+		return domain.ErrSongNotFound
+	}
+}
+
+// nullLink is the link to store, songs without link have NULL one
+func nullLink(link string) sql.NullString {
+	return sql.NullString{String: link, Valid: link != ""}
 }
 
 func (s *song) toEntity() *domain.Song {
@@ -374,12 +899,77 @@ func (s *song) toEntity() *domain.Song {
 			ID:   s.MusicGroup.ID,
 			Name: s.MusicGroup.Name,
 		},
-		Couplets:    s.Couplets,
-		ReleaseDate: s.ReleaseDate,
-		Link:        s.Link,
+		Couplets: s.Couplets,
+		ReleaseDate: domain.PartialDate{
+			Date:      s.ReleaseDate,
+			Precision: domain.DatePrecision(s.ReleaseDatePrecision),
+		},
+		Link:      s.Link.String,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Version:   s.Version,
 	}
 }
 
-func NewSongRepository(tx *sqlx.DB) *SongRepository {
-	return &SongRepository{db: tx}
+// selectSongs selects songs with the given fields besides ID and
+// version, the rest of song model fields are left blank. Music groups
+// are joined anyway as filters and sort may need them
+func selectSongs(fields domain.SongFieldSet) sq.SelectBuilder {
+	return selectSongsAggregatingCouplets(
+		fields, "ARRAY_AGG(sc.text ORDER BY sc.couplet_num)")
+}
+
+// selectSongsAggregatingCouplets is selectSongs aggregating
+// couplets of a song with the given aggregate function call
+func selectSongsAggregatingCouplets(
+	fields domain.SongFieldSet, coupletsAggregate string,
+) sq.SelectBuilder {
+	builder := sq.
+		Select("s.id", "s.version").
+		From("songs s").
+		LeftJoin("music_groups mg ON s.music_group_id = mg.id")
+
+	if fields.Has(domain.SongFieldName) {
+		builder = builder.Column("s.name")
+	}
+	if fields.Has(domain.SongFieldReleaseDate) {
+		builder = builder.Columns("s.release_date", "s.release_date_precision")
+	}
+	if fields.Has(domain.SongFieldLink) {
+		builder = builder.Column("s.link")
+	}
+	if fields.Has(domain.SongFieldCreatedAt) {
+		builder = builder.Column("s.created_at")
+	}
+	if fields.Has(domain.SongFieldUpdatedAt) {
+		builder = builder.Column("s.updated_at")
+	}
+	if fields.Has(domain.SongFieldGroup) {
+		builder = builder.Columns(
+			`mg.id AS "music_group.id"`,
+			`mg.name AS "music_group.name"`,
+		)
+	}
+	if fields.Has(domain.SongFieldCouplets) {
+		coupletsSubquery := sq.
+			Select(coupletsAggregate).
+			From("song_couplets sc").
+			Where("sc.song_id = s.id")
+		builder = builder.Column(sq.Alias(coupletsSubquery, "couplets"))
+	}
+
+	return builder
+}
+
+// NewSongRepository creates repository reading from replicas reads
+// routes to, reads go to db if reads is nil. Names are similar
+// in fuzzy search if their similarity reaches similarityThreshold
+func NewSongRepository(
+	tx *sqlx.DB, reads ReadRouter, similarityThreshold float64,
+) *SongRepository {
+	return &SongRepository{
+		db:                  tx,
+		reads:               reads,
+		similarityThreshold: similarityThreshold,
+	}
 }
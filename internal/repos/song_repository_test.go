@@ -0,0 +1,100 @@
+package repos
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"song-lib/internal/config"
+	"song-lib/internal/db/postgres"
+	"song-lib/internal/db/sqlite"
+	"song-lib/internal/domain"
+	"song-lib/internal/repos/repostest"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSongRepository runs against the database
+// TEST_POSTGRES_DSN points to, its songs are deleted
+func TestSongRepository(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, postgres.MigrateUp(context.Background(), db))
+
+	repostest.TestSongRepository(t, func(t *testing.T) domain.SongRepository {
+		_, err := db.Exec(`TRUNCATE song_couplets, songs, music_groups`)
+		require.NoError(t, err)
+		return NewSongRepository(db, nil, 0.3)
+	})
+}
+
+// TestAutocompleteQueryPlan checks that autocomplete candidates are
+// found with name indexes, it runs against the database
+// TEST_POSTGRES_DSN points to. Sequential scans are disabled,
+// as the planner prefers them for tables this small
+func TestAutocompleteQueryPlan(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, postgres.MigrateUp(context.Background(), db))
+
+	tests := []struct {
+		query string
+		kind  domain.AutocompleteKind
+		index string
+	}{
+		{"be", domain.AutocompleteKindSong, "idx_songs_name_lower_prefix"},
+		{"beat", domain.AutocompleteKindSong, "idx_songs_name_trgm"},
+		{"be", domain.AutocompleteKindGroup, "idx_music_groups_name_lower_prefix"},
+		{"beat", domain.AutocompleteKindGroup, "idx_music_groups_name_trgm"},
+	}
+	for _, test := range tests {
+		query, args, err := autocompleteQuery(test.query, test.kind, 10)
+		require.NoError(t, err)
+
+		tx, err := db.Beginx()
+		require.NoError(t, err)
+		_, err = tx.Exec("SET LOCAL enable_seqscan = off")
+		require.NoError(t, err)
+		var plan []string
+		err = tx.Select(&plan, "EXPLAIN "+query, args...)
+		require.NoError(t, tx.Rollback())
+		require.NoError(t, err)
+
+		require.Contains(t, strings.Join(plan, "\n"), test.index,
+			"query %q of kind %s", test.query, test.kind)
+	}
+}
+
+func TestMemorySongRepository(t *testing.T) {
+	repostest.TestSongRepository(t, func(*testing.T) domain.SongRepository {
+		return NewMemorySongRepository(0.3)
+	})
+}
+
+func TestSQLiteSongRepository(t *testing.T) {
+	repostest.TestSongRepository(t, func(t *testing.T) domain.SongRepository {
+		db, err := sqlite.NewClient(config.SQLiteConfig{
+			Path: filepath.Join(t.TempDir(), "song-lib.db")})
+		if errors.Is(err, sqlite.ErrFTS5Unavailable) {
+			t.Skip(err)
+		}
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		require.NoError(t, sqlite.MigrateUp(db))
+
+		return NewSQLiteSongRepository(db, 0.3)
+	})
+}
@@ -0,0 +1,181 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"song-lib/internal/domain"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type SQLiteIdempotencyRepository struct {
+	db *sqlx.DB
+}
+
+// ClaimIdempotencyKey behaves as IdempotencyRepository.ClaimIdempotencyKey,
+// key is claimed in a single write transaction
+func (r *SQLiteIdempotencyRepository) ClaimIdempotencyKey(
+	ctx context.Context, key, fingerprint, token string, ttl, lease time.Duration,
+) (*domain.IdempotencyRecord, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	now := microsecondNow()
+	query, args, err := sqliteQuery(sq.
+		Select("key", "fingerprint", "response_status",
+			"response_headers", "response_body",
+			"created_at", "locked_until").
+		From("idempotency_keys").
+		Where(sq.Eq{"key": key}))
+	if err != nil {
+		return nil, errors.Wrap(err, "select key: build query")
+	}
+	var recordModel sqliteIdempotencyRecord
+	err = tx.GetContext(ctx, &recordModel, query, args...)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		query, args, err = sqliteQuery(sq.
+			Insert("idempotency_keys").
+			Columns("key", "fingerprint", "claim_token",
+				"created_at", "locked_until").
+			Values(key, fingerprint, token, now, now.Add(lease)))
+	case err != nil:
+		return nil, errors.Wrap(err, "select key: execute query")
+	case recordModel.expired(now, ttl, fingerprint):
+		query, args, err = sqliteQuery(sq.
+			Update("idempotency_keys").
+			Set("fingerprint", fingerprint).
+			Set("response_status", nil).
+			Set("response_headers", nil).
+			Set("response_body", nil).
+			Set("created_at", now).
+			Set("claim_token", token).
+			Set("locked_until", now.Add(lease)).
+			Where(sq.Eq{"key": key}))
+	default:
+		return recordModel.toEntity()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "claim key: build query")
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "claim key: execute query")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "commit")
+	}
+
+	return nil, nil
+}
+
+// sqliteIdempotencyRecord has timestamps to tell
+// whether the record can be replaced
+type sqliteIdempotencyRecord struct {
+	idempotencyRecord
+	CreatedAt   time.Time  `db:"created_at"`
+	LockedUntil *time.Time `db:"locked_until"`
+}
+
+// expired tells whether the record is older than ttl or is the one
+// of the request with the given fingerprint whose lease has expired
+// without response. Records claimed before leases have no lease
+func (r *sqliteIdempotencyRecord) expired(
+	now time.Time, ttl time.Duration, fingerprint string,
+) bool {
+	if r.CreatedAt.Before(now.Add(-ttl)) {
+		return true
+	}
+	return r.ResponseStatus == nil && r.Fingerprint == fingerprint &&
+		(r.LockedUntil == nil || r.LockedUntil.Before(now))
+}
+
+// DeleteExpiredIdempotencyKeys behaves as
+// IdempotencyRepository.DeleteExpiredIdempotencyKeys
+func (r *SQLiteIdempotencyRepository) DeleteExpiredIdempotencyKeys(
+	ctx context.Context, ttl time.Duration,
+) (int64, error) {
+	query, args, err := sqliteQuery(sq.
+		Delete("idempotency_keys").
+		Where(sq.Lt{"created_at": microsecondNow().Add(-ttl)}))
+	if err != nil {
+		return 0, errors.Wrap(err, "build query")
+	}
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "execute query")
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "get rows affected")
+	}
+
+	return deleted, nil
+}
+
+// RenewIdempotencyKey behaves as IdempotencyRepository.RenewIdempotencyKey
+func (r *SQLiteIdempotencyRepository) RenewIdempotencyKey(
+	ctx context.Context, key, token string, lease time.Duration,
+) error {
+	query, args, err := sqliteQuery(sq.
+		Update("idempotency_keys").
+		Set("locked_until", microsecondNow().Add(lease)).
+		Where(sq.Eq{"key": key, "claim_token": token, "response_status": nil}))
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	return execOwnedKeyQuery(ctx, r.db, query, args)
+}
+
+// SaveIdempotentResponse behaves as
+// IdempotencyRepository.SaveIdempotentResponse
+func (r *SQLiteIdempotencyRepository) SaveIdempotentResponse(
+	ctx context.Context, key, token string, response *domain.IdempotentResponse,
+) error {
+	headers, err := json.Marshal(response.Headers)
+	if err != nil {
+		return errors.Wrap(err, "marshal headers")
+	}
+
+	query, args, err := sqliteQuery(sq.
+		Update("idempotency_keys").
+		Set("response_status", response.StatusCode).
+		Set("response_headers", headers).
+		Set("response_body", response.Body).
+		Where(sq.Eq{"key": key, "claim_token": token}))
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	return execOwnedKeyQuery(ctx, r.db, query, args)
+}
+
+// ReleaseIdempotencyKey behaves as
+// IdempotencyRepository.ReleaseIdempotencyKey
+func (r *SQLiteIdempotencyRepository) ReleaseIdempotencyKey(
+	ctx context.Context, key, token string,
+) error {
+	query, args, err := sqliteQuery(sq.
+		Delete("idempotency_keys").
+		Where(sq.Eq{"key": key, "claim_token": token}))
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	return execOwnedKeyQuery(ctx, r.db, query, args)
+}
+
+func NewSQLiteIdempotencyRepository(db *sqlx.DB) *SQLiteIdempotencyRepository {
+	return &SQLiteIdempotencyRepository{db: db}
+}
@@ -0,0 +1,64 @@
+package repos
+
+import (
+	"context"
+	"song-lib/internal/domain"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func (r *SQLiteSongRepository) ApplySongBatch(
+	ctx context.Context, writes []domain.SongBatchWrite,
+	atomic bool,
+) ([]domain.SongBatchItemResult, error) {
+	var results []domain.SongBatchItemResult
+	err := inTx(ctx, r.db, nil, func(tx *sqlx.Tx) error {
+		var err error
+		results, err = applySongBatch(ctx, tx, writes, atomic,
+			func(write *domain.SongBatchWrite) (*domain.Song, error) {
+				return applySQLiteSongBatchWrite(ctx, tx, write)
+			})
+		return err
+	})
+	if err != nil && !errors.Is(err, errAtomicBatchFailed) {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func applySQLiteSongBatchWrite(
+	ctx context.Context, tx *sqlx.Tx, write *domain.SongBatchWrite,
+) (*domain.Song, error) {
+	songID := write.SongID
+	switch write.Kind {
+	case domain.SongBatchOperationCreate:
+		var err error
+		songID, err = sqliteSaveSong(ctx, tx, write.Song)
+		if err != nil {
+			return nil, err
+		}
+	case domain.SongBatchOperationUpdate:
+		err := sqliteUpdateSong(ctx, tx, songID, write.Version, write.Update)
+		if err != nil {
+			return nil, err
+		}
+	case domain.SongBatchOperationDelete:
+		err := sqliteDeleteSong(ctx, tx, songID, write.Version)
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	default:
+		return nil, errors.Errorf("unknown batch operation %s", write.Kind)
+	}
+
+	song, err := sqliteGetSong(ctx, tx, sq.Eq{"s.id": songID})
+	if err != nil {
+		return nil, errors.Wrap(err, "get written song")
+	}
+
+	return song, nil
+}
@@ -0,0 +1,121 @@
+package repos
+
+import (
+	"song-lib/internal/domain"
+	"strings"
+	"unicode/utf8"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+)
+
+// sqliteFTSTables are FTS5 trigram indexes of columns
+var sqliteFTSTables = map[string]string{
+	"s.name":  "songs_fts",
+	"mg.name": "music_groups_fts",
+	"sc.text": "song_couplets_fts",
+}
+
+// ftsTrigramLen is the length of texts trigram index
+// finds, shorter ones have no trigrams to look up
+const ftsTrigramLen = 3
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) containsCondition(column, text string) sq.Sqlizer {
+	return sqliteContainsCondition(column, sqliteFTSTables[column], text)
+}
+
+func (sqliteDialect) utcDate(column string) string {
+	return "(date(" + column + ") || ' 00:00:00.000000')"
+}
+
+// sqliteContainsCondition matches column values containing text in any
+// letter case. Text is looked up in FTS5 trigram index of the column
+// if there is one and text is long enough, column is scanned otherwise
+func sqliteContainsCondition(column, ftsTable, text string) sq.Sqlizer {
+	if ftsTable == "" || utf8.RuneCountInString(text) < ftsTrigramLen {
+		return sq.Expr("casefold("+column+") LIKE "+
+			`'%' || casefold(escape_like_string(?)) || '%' ESCAPE '\'`, text)
+	}
+
+	// Quoted phrase matches text as is, trigram
+	// tokenizer makes it match any substring
+	phrase := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+	alias, _, _ := strings.Cut(column, ".")
+	return sq.Expr(alias+".rowid IN (SELECT rowid FROM "+ftsTable+
+		" WHERE "+ftsTable+" MATCH ?)", phrase)
+}
+
+func (r *SQLiteSongRepository) filterSongs(
+	builder sq.SelectBuilder, f *domain.SongFilters,
+) (sq.SelectBuilder, error) {
+	if f.SongName != nil {
+		builder = builder.Where(r.nameMatchCondition(
+			"s.name", *f.SongName, f.NameMatchMode))
+	}
+	if f.SongLink != nil {
+		builder = builder.Where(linkEqCondition(*f.SongLink))
+	}
+	if f.MusicGroupName != nil {
+		builder = builder.Where(r.nameMatchCondition(
+			"mg.name", *f.MusicGroupName, f.NameMatchMode))
+	}
+	if f.SongReleaseDateRange != nil {
+		builder = builder.Where(timeRangeCondition(
+			"s.release_date", "s.release_date_last_day",
+			f.SongReleaseDateRange))
+	}
+	if f.SongCoupletContains != nil {
+		songWithTextIDsSubquery := sq.
+			Select("sc.song_id").
+			From("song_couplets sc").
+			Where(sqliteDialect{}.containsCondition(
+				"sc.text", *f.SongCoupletContains))
+
+		builder = builder.Where(inConditionWithSubquery(
+			"s.id", songWithTextIDsSubquery,
+		))
+	}
+	if f.Expression != nil {
+		condition, err := compileFilterExpression(f.Expression, sqliteDialect{})
+		if err != nil {
+			return builder, errors.Wrap(err, "compile filter expression")
+		}
+		builder = builder.Where(condition)
+	}
+
+	return builder, nil
+}
+
+func (r *SQLiteSongRepository) nameMatchCondition(
+	column, name string, matchMode domain.MatchMode,
+) sq.Sqlizer {
+	switch matchMode {
+	case domain.MatchModePrefix:
+		return sq.Expr("casefold("+column+") LIKE "+
+			`casefold(escape_like_string(?)) || '%' ESCAPE '\'`, name)
+	case domain.MatchModeFuzzy:
+		return sq.Expr(
+			"similarity("+column+", ?) >= ?",
+			name, r.similarityThreshold)
+	default:
+		return sq.Eq{column: name}
+	}
+}
+
+// sqliteSongRelevance is songRelevance using
+// similarity function the client registers
+func sqliteSongRelevance(f *domain.SongFilters) sq.Sqlizer {
+	relevance := sq.Expr("0")
+	if f.SongName != nil {
+		relevance = sq.Expr("? + similarity(s.name, ?)",
+			relevance, *f.SongName)
+	}
+	if f.MusicGroupName != nil {
+		relevance = sq.Expr("? + similarity(mg.name, ?)",
+			relevance, *f.MusicGroupName)
+	}
+
+	return sq.Expr("CAST((?) AS REAL)", relevance)
+}
@@ -0,0 +1,768 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"song-lib/internal/domain"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/segmentio/ksuid"
+)
+
+// SQLiteSongRepository keeps songs in SQLite database opened with
+// sqlite.NewClient. Couplets are aggregated with json_group_array and
+// inserted with json_each in place of ARRAY_AGG and UNNEST, substring
+// search uses FTS5 trigram indexes in place of pg_trgm ones. Unlike
+// Postgres, names are sorted by code points rather than by collation
+type SQLiteSongRepository struct {
+	db                  *sqlx.DB
+	similarityThreshold float64
+}
+
+// sqliteTimeLayout is the layout dates and timestamps are stored in,
+// fractional seconds have fixed width so that texts sort as times
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000"
+
+const sqliteCoupletsAggregate = "json_group_array(sc.text ORDER BY sc.couplet_num)"
+
+// sqliteQuery builds query with times among
+// its arguments formatted with sqliteTimeLayout
+func sqliteQuery(builder sq.Sqlizer) (string, []any, error) {
+	query, args, err := builder.ToSql()
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = t.UTC().Format(sqliteTimeLayout)
+		}
+	}
+	return query, args, err
+}
+
+func (r *SQLiteSongRepository) SaveSong(
+	ctx context.Context, song *domain.Song,
+) (*domain.Song, error) {
+	var resSong *domain.Song
+	err := inTx(ctx, r.db, nil, func(tx *sqlx.Tx) error {
+		songID, err := sqliteSaveSong(ctx, tx, song)
+		if err != nil {
+			return err
+		}
+		resSong, err = sqliteGetSong(ctx, tx, sq.Eq{"s.id": songID})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resSong, nil
+}
+
+// sqliteSaveSong fails with ErrSongAlreadyExists if the music group has
+// song with the same name. Checking it before insert is safe as
+// transactions hold write lock since they begin
+func sqliteSaveSong(
+	ctx context.Context, tx *sqlx.Tx, song *domain.Song,
+) (ksuid.KSUID, error) {
+	query, args, err := sqliteQuery(sq.
+		Insert("music_groups").
+		Columns("id", "name").
+		Values(ksuid.New(), song.MusicGroup.Name).
+		Suffix("ON CONFLICT (name) DO NOTHING"))
+	if err != nil {
+		return ksuid.Nil, errors.Wrap(err, "insert music group: build query")
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return ksuid.Nil, errors.Wrap(err, "insert music group: execute query")
+	}
+
+	query, args, err = sqliteQuery(sq.
+		Select("mg.id").
+		From("music_groups mg").
+		Where(sq.Eq{"mg.name": song.MusicGroup.Name}))
+	if err != nil {
+		return ksuid.Nil, errors.Wrap(err, "select music group: build query")
+	}
+	var musicGroupID ksuid.KSUID
+	err = tx.GetContext(ctx, &musicGroupID, query, args...)
+	if err != nil {
+		return ksuid.Nil, errors.Wrap(err, "select music group: execute query")
+	}
+
+	exists, err := sqliteSongExists(ctx, tx, sq.Eq{
+		"s.music_group_id": musicGroupID,
+		"s.name":           song.Name,
+	})
+	if err != nil {
+		return ksuid.Nil, errors.Wrap(err, "check song exists")
+	}
+	if exists {
+		return ksuid.Nil, domain.ErrSongAlreadyExists
+	}
+
+	songID, now := ksuid.New(), microsecondNow()
+	query, args, err = sqliteQuery(sq.
+		Insert("songs").
+		Columns(
+			"id", "music_group_id", "name",
+			"release_date", "release_date_precision", "link",
+			"created_at", "updated_at").
+		Values(
+			songID, musicGroupID, song.Name,
+			song.ReleaseDate.Date, song.ReleaseDate.Precision, song.Link,
+			now, now))
+	if err != nil {
+		return ksuid.Nil, errors.Wrap(err, "insert song: build query")
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return ksuid.Nil, errors.Wrap(err, "insert song: execute query")
+	}
+
+	err = insertSQLiteCouplets(ctx, tx, songID, song.Couplets)
+	if err != nil {
+		return ksuid.Nil, errors.Wrap(err, "insert couplets")
+	}
+
+	return songID, nil
+}
+
+func insertSQLiteCouplets(
+	ctx context.Context, e sqlx.ExecerContext,
+	songID ksuid.KSUID, couplets []string,
+) error {
+	coupletsJSON, err := json.Marshal(couplets)
+	if err != nil {
+		return errors.Wrap(err, "marshal couplets")
+	}
+
+	query := `
+	INSERT INTO
+		song_couplets (song_id, couplet_num, text)
+	SELECT
+		? AS song_id,
+		key + 1 AS couplet_num,
+		value AS text
+	FROM
+		json_each(?)`
+
+	_, err = e.ExecContext(ctx, query, songID, string(coupletsJSON))
+	if err != nil {
+		return errors.Wrap(err, "execute query")
+	}
+
+	return nil
+}
+
+// sqliteSongExists tells if there is a song matching
+// the condition on songs aliased as s
+func sqliteSongExists(
+	ctx context.Context, q sqlx.QueryerContext, condition sq.Sqlizer,
+) (bool, error) {
+	query, args, err := sqliteQuery(sq.
+		Select("1").
+		From("songs s").
+		Join("music_groups mg ON s.music_group_id = mg.id").
+		Where(condition).
+		Prefix("SELECT EXISTS (").
+		Suffix(")"))
+	if err != nil {
+		return false, errors.Wrap(err, "build query")
+	}
+
+	var exists bool
+	err = sqlx.GetContext(ctx, q, &exists, query, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "execute query")
+	}
+
+	return exists, nil
+}
+
+func (r *SQLiteSongRepository) SongExistsByID(
+	ctx context.Context, songID ksuid.KSUID,
+) (bool, error) {
+	return sqliteSongExists(ctx, conn(ctx, r.db), sq.Eq{"s.id": songID})
+}
+
+func (r *SQLiteSongRepository) SongExistsByNameAndMusicGroupName(
+	ctx context.Context, songName, musicGroupName string,
+) (bool, error) {
+	return sqliteSongExists(ctx, conn(ctx, r.db), sq.Eq{
+		"s.name":  songName,
+		"mg.name": musicGroupName,
+	})
+}
+
+func (r *SQLiteSongRepository) GetSongByID(
+	ctx context.Context, songID ksuid.KSUID,
+) (*domain.Song, error) {
+	return sqliteGetSong(ctx, conn(ctx, r.db), sq.Eq{"s.id": songID})
+}
+
+func (r *SQLiteSongRepository) GetSongByNameAndMusicGroupName(
+	ctx context.Context, songName, musicGroupName string,
+) (*domain.Song, error) {
+	return sqliteGetSong(ctx, conn(ctx, r.db), sq.Eq{
+		"s.name":  songName,
+		"mg.name": musicGroupName,
+	})
+}
+
+// sqliteGetSong fails with ErrSongNotFound
+// if no song matches the condition
+func sqliteGetSong(
+	ctx context.Context, q sqlx.QueryerContext, condition sq.Sqlizer,
+) (*domain.Song, error) {
+	query, args, err := sqliteQuery(selectSQLiteSongs(nil).Where(condition))
+	if err != nil {
+		return nil, errors.Wrap(err, "build query")
+	}
+
+	var songModel song
+	err = sqlx.GetContext(ctx, q, &songModel, query, args...)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, domain.ErrSongNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return songModel.toEntity(), nil
+}
+
+func (r *SQLiteSongRepository) GetSongsFilteredPaginated(
+	ctx context.Context, f *domain.SongFilters,
+	sort []domain.SongSortOption, pagination domain.Pagination,
+	fields domain.SongFieldSet,
+) (*domain.SongsPage, error) {
+	sortKeys, err := songSortKeys(sort, sqliteSongRelevance(f))
+	if err != nil {
+		return nil, errors.Wrap(err, "build sort keys")
+	}
+
+	// Sort key values are needed for cursors
+	// even if they are not requested
+	if fields != nil && pagination.Cursor != nil {
+		fields = maps.Clone(fields)
+		for _, sortOption := range sort {
+			if field, ok := sortOption.Field.SongField(); ok {
+				fields[field] = true
+			}
+		}
+	}
+	builder, err := r.filterSongs(selectSQLiteSongs(fields), f)
+	if err != nil {
+		return nil, errors.Wrap(err, "filter songs")
+	}
+	if pagination.Cursor == nil {
+		builder = orderBySortKeys(builder, sortKeys, false).
+			Limit(uint64(pagination.PerPage)).
+			Offset(uint64(pagination.Page * pagination.PerPage))
+	} else {
+		builder = paginateByCursor(
+			builder, sortKeys, pagination.Cursor, pagination.PerPage)
+		if hasRelevanceSortKey(sort) {
+			builder = builder.Column(sq.Alias(sqliteSongRelevance(f), "relevance"))
+		}
+	}
+
+	query, args, err := sqliteQuery(builder)
+	if err != nil {
+		return nil, errors.Wrap(err, "build query")
+	}
+
+	var songModels []song
+	err = sqlx.SelectContext(ctx, conn(ctx, r.db), &songModels, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	var songsPage domain.SongsPage
+	if pagination.Cursor != nil {
+		songModels, songsPage.NextCursor, songsPage.PrevCursor = cursorPage(
+			songModels, pagination.PerPage, pagination.Cursor,
+			func(songModel song) []any {
+				return sortKeyValues(sortKeys, &songModel)
+			})
+	}
+
+	songsPage.Songs = make([]domain.Song, 0, len(songModels))
+	for _, songModel := range songModels {
+		songsPage.Songs = append(songsPage.Songs, *songModel.toEntity())
+	}
+
+	return &songsPage, nil
+}
+
+// ForEachSongFiltered calls fn for each filtered song ordered by ID.
+// Songs are read with a single query seeing a single snapshot,
+// fn errors are returned as is
+func (r *SQLiteSongRepository) ForEachSongFiltered(
+	ctx context.Context, f *domain.SongFilters,
+	fn func(song *domain.Song) error,
+) error {
+	builder, err := r.filterSongs(selectSQLiteSongs(nil), f)
+	if err != nil {
+		return errors.Wrap(err, "filter songs")
+	}
+	query, args, err := sqliteQuery(builder.OrderBy("s.id"))
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	rows, err := conn(ctx, r.db).QueryxContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "execute query")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var songModel song
+		if err := rows.StructScan(&songModel); err != nil {
+			return errors.Wrap(err, "scan row")
+		}
+		if err := fn(songModel.toEntity()); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(rows.Err(), "read rows")
+}
+
+// CountSongsFiltered counts songs exactly even if estimated
+// count is requested, as SQLite doesn't estimate rows
+func (r *SQLiteSongRepository) CountSongsFiltered(
+	ctx context.Context, f *domain.SongFilters, _ bool,
+) (int, error) {
+	builder, err := r.filterSongs(
+		sq.Select("COUNT(*)").
+			From("songs s").
+			LeftJoin("music_groups mg ON s.music_group_id = mg.id"),
+		f)
+	if err != nil {
+		return 0, errors.Wrap(err, "filter songs")
+	}
+
+	query, args, err := sqliteQuery(builder)
+	if err != nil {
+		return 0, errors.Wrap(err, "build query")
+	}
+
+	var count int
+	err = sqlx.GetContext(ctx, conn(ctx, r.db), &count, query, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "execute query")
+	}
+
+	return count, nil
+}
+
+func (r *SQLiteSongRepository) GetSongSearchSuggestion(
+	ctx context.Context, f *domain.SongFilters,
+) (*domain.SongSearchSuggestion, error) {
+	var (
+		suggestion domain.SongSearchSuggestion
+		err        error
+	)
+	if f.SongName != nil {
+		suggestion.SongName, err = r.getMostSimilarName(
+			ctx, "songs", *f.SongName)
+		if err != nil {
+			return nil, errors.Wrap(err, "get most similar song name")
+		}
+	}
+	if f.MusicGroupName != nil {
+		suggestion.MusicGroupName, err = r.getMostSimilarName(
+			ctx, "music_groups", *f.MusicGroupName)
+		if err != nil {
+			return nil, errors.Wrap(err, "get most similar music group name")
+		}
+	}
+	if suggestion.SongName == nil && suggestion.MusicGroupName == nil {
+		return nil, nil
+	}
+
+	return &suggestion, nil
+}
+
+func (r *SQLiteSongRepository) getMostSimilarName(
+	ctx context.Context, table, name string,
+) (*string, error) {
+	query, args, err := sqliteQuery(sq.
+		Select("t.name").
+		From(table+" t").
+		Where(sq.Expr("similarity(t.name, ?) >= ?",
+			name, r.similarityThreshold)).
+		OrderByClause("similarity(t.name, ?) DESC", name).
+		OrderBy("t.name").
+		Limit(1))
+	if err != nil {
+		return nil, errors.Wrap(err, "build query")
+	}
+
+	var similarName string
+	err = sqlx.GetContext(ctx, conn(ctx, r.db), &similarName, query, args...)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return &similarName, nil
+}
+
+func (r *SQLiteSongRepository) GetSongCoupletsPaginated(
+	ctx context.Context, songID ksuid.KSUID,
+	pagination domain.Pagination,
+) (*domain.CoupletsPage, error) {
+	builder := sq.
+		Select("sc.couplet_num", "sc.text").
+		From("song_couplets sc").
+		Where(sq.Eq{"sc.song_id": songID})
+
+	sortKeys := []sortKey{coupletNumSortKey}
+	if pagination.Cursor == nil {
+		builder = orderBySortKeys(builder, sortKeys, false).
+			Limit(uint64(pagination.PerPage)).
+			Offset(uint64(pagination.Page * pagination.PerPage))
+	} else {
+		builder = paginateByCursor(
+			builder, sortKeys, pagination.Cursor, pagination.PerPage)
+	}
+
+	query, args, err := sqliteQuery(builder)
+	if err != nil {
+		return nil, errors.Wrap(err, "build query")
+	}
+
+	var coupletModels []couplet
+	err = sqlx.SelectContext(ctx, conn(ctx, r.db), &coupletModels, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	var coupletsPage domain.CoupletsPage
+	if pagination.Cursor != nil {
+		coupletModels, coupletsPage.NextCursor, coupletsPage.PrevCursor = cursorPage(
+			coupletModels, pagination.PerPage, pagination.Cursor,
+			func(coupletModel couplet) []any {
+				return sortKeyValues(sortKeys, &coupletModel)
+			})
+	}
+
+	coupletsPage.Couplets = make([]string, 0, len(coupletModels))
+	for _, coupletModel := range coupletModels {
+		coupletsPage.Couplets = append(coupletsPage.Couplets, coupletModel.Text)
+	}
+
+	return &coupletsPage, nil
+}
+
+func (r *SQLiteSongRepository) CountSongCouplets(
+	ctx context.Context, songID ksuid.KSUID,
+) (int, error) {
+	query, args, err := sqliteQuery(sq.
+		Select("COUNT(*)").
+		From("song_couplets sc").
+		Where(sq.Eq{"sc.song_id": songID}))
+	if err != nil {
+		return 0, errors.Wrap(err, "build query")
+	}
+
+	var count int
+	err = sqlx.GetContext(ctx, conn(ctx, r.db), &count, query, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "execute query")
+	}
+
+	return count, nil
+}
+
+// GetAutocompleteSuggestions returns names containing the query,
+// prefix matches go first, then ones with more popular music groups
+// (popularity is the number of songs in the music group)
+func (r *SQLiteSongRepository) GetAutocompleteSuggestions(
+	ctx context.Context, query string,
+	kind domain.AutocompleteKind, limit int,
+) ([]domain.AutocompleteSuggestion, error) {
+	var popularity, ftsTable string
+	builder := sq.Select("t.id", "t.name")
+	switch kind {
+	case domain.AutocompleteKindSong:
+		builder = builder.
+			Column("mg.name AS music_group_name").
+			From("songs t").
+			Join("music_groups mg ON t.music_group_id = mg.id")
+		popularity, ftsTable = "t.music_group_id", "songs_fts"
+	case domain.AutocompleteKindGroup:
+		builder = builder.
+			Column("'' AS music_group_name").
+			From("music_groups t")
+		popularity, ftsTable = "t.id", "music_groups_fts"
+	default:
+		return nil, errors.Errorf("unknown autocomplete kind %s", kind)
+	}
+
+	prefixCondition := sq.Expr(
+		`casefold(t.name) LIKE casefold(escape_like_string(?)) || '%' ESCAPE '\'`,
+		query)
+	if len([]rune(query)) < autocompleteMinInfixQueryLen {
+		builder = builder.Where(prefixCondition)
+	} else {
+		builder = builder.Where(
+			sqliteContainsCondition("t.name", ftsTable, query))
+	}
+
+	sqlQuery, args, err := sqliteQuery(builder.
+		OrderByClause(sq.Expr("(?) DESC", prefixCondition)).
+		OrderBy(
+			"(SELECT COUNT(*) FROM songs s WHERE s.music_group_id = "+
+				popularity+") DESC",
+			"t.name").
+		Limit(uint64(limit)))
+	if err != nil {
+		return nil, errors.Wrap(err, "build query")
+	}
+
+	var suggestionModels []autocompleteSuggestion
+	err = sqlx.SelectContext(ctx, conn(ctx, r.db), &suggestionModels, sqlQuery, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	suggestions := make([]domain.AutocompleteSuggestion, 0, len(suggestionModels))
+	for _, suggestionModel := range suggestionModels {
+		suggestions = append(suggestions, domain.AutocompleteSuggestion(suggestionModel))
+	}
+
+	return suggestions, nil
+}
+
+func (r *SQLiteSongRepository) UpdateSong(
+	ctx context.Context, songID ksuid.KSUID,
+	version *int64, songUpdate *domain.SongUpdate,
+) (*domain.Song, error) {
+	var song *domain.Song
+	err := inTx(ctx, r.db, nil, func(tx *sqlx.Tx) error {
+		err := sqliteUpdateSong(ctx, tx, songID, version, songUpdate)
+		if err != nil {
+			return err
+		}
+
+		song, err = sqliteGetSong(ctx, tx, sq.Eq{"s.id": songID})
+		return errors.Wrap(err, "get updated song")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return song, nil
+}
+
+// PatchSong runs patch in write transaction,
+// so that concurrent patches are applied one after another
+func (r *SQLiteSongRepository) PatchSong(
+	ctx context.Context, songID ksuid.KSUID,
+	version *int64, patch domain.SongPatchFunc,
+) (*domain.Song, error) {
+	var song *domain.Song
+	err := inTx(ctx, r.db, nil, func(tx *sqlx.Tx) error {
+		var err error
+		song, err = sqlitePatchSong(ctx, tx, songID, version, patch)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return song, nil
+}
+
+func sqlitePatchSong(
+	ctx context.Context, tx *sqlx.Tx, songID ksuid.KSUID,
+	version *int64, patch domain.SongPatchFunc,
+) (*domain.Song, error) {
+	song, err := sqliteGetSong(ctx, tx, sq.Eq{"s.id": songID})
+	if err != nil {
+		return nil, err
+	}
+	if version != nil && song.Version != *version {
+		return nil, domain.ErrVersionMismatch
+	}
+
+	// Patch errors are returned as is for caller to handle
+	songUpdate, err := patch(song)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sqliteUpdateSong(ctx, tx, songID, nil, songUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	song, err = sqliteGetSong(ctx, tx, sq.Eq{"s.id": songID})
+	if err != nil {
+		return nil, errors.Wrap(err, "get patched song")
+	}
+
+	return song, nil
+}
+
+// sqliteUpdateSong fails with ErrSongNotFound or ErrVersionMismatch
+// if there is no song with the given ID and version, nil version
+// matches any. It fails with ErrSongAlreadyExists if the name is taken
+func sqliteUpdateSong(
+	ctx context.Context, tx *sqlx.Tx, songID ksuid.KSUID,
+	version *int64, songUpdate *domain.SongUpdate,
+) error {
+	builder := sq.
+		Update("songs").
+		Set("updated_at", microsecondNow()).
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": songID})
+	if version != nil {
+		builder = builder.Where(sq.Eq{"version": *version})
+	}
+	if songUpdate.Name != nil {
+		builder = builder.Set("name", *songUpdate.Name)
+	}
+	if songUpdate.ReleaseDate != nil {
+		builder = builder.
+			Set("release_date", songUpdate.ReleaseDate.Date).
+			Set("release_date_precision", songUpdate.ReleaseDate.Precision)
+	}
+	if songUpdate.Link != nil {
+		builder = builder.Set("link", *songUpdate.Link)
+	}
+
+	query, args, err := sqliteQuery(builder)
+	if err != nil {
+		return errors.Wrap(err, "update songs table: build query")
+	}
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil && songUpdate.Name != nil {
+		// Failed statement is rolled back alone,
+		// so the transaction can tell why it failed
+		taken, checkErr := sqliteSongExists(ctx, tx, sq.And{
+			sq.Expr("s.music_group_id = "+
+				"(SELECT music_group_id FROM songs WHERE id = ?)", songID),
+			sq.Eq{"s.name": *songUpdate.Name},
+			sq.NotEq{"s.id": songID},
+		})
+		if checkErr != nil {
+			return errors.Wrap(checkErr, "check song name is taken")
+		}
+		if taken {
+			return domain.ErrSongAlreadyExists
+		}
+	}
+	if err != nil {
+		return errors.Wrap(err, "update songs table: execute query")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "update songs table: get rows affected")
+	}
+	if rowsAffected == 0 {
+		return sqliteVersionMismatchOrNotFound(ctx, tx, songID)
+	}
+
+	if songUpdate.Couplets != nil {
+		query, args, err := sqliteQuery(sq.
+			Delete("song_couplets").
+			Where(sq.Eq{"song_id": songID}))
+		if err != nil {
+			return errors.Wrap(err,
+				"delete old couplets from song_couplets table: build query")
+		}
+
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return errors.Wrap(err,
+				"delete old couplets from song_couplets table: execute query")
+		}
+
+		err = insertSQLiteCouplets(ctx, tx, songID, *songUpdate.Couplets)
+		if err != nil {
+			return errors.Wrap(err,
+				"create new couplets in couplets table")
+		}
+	}
+
+	return nil
+}
+
+func (r *SQLiteSongRepository) DeleteSong(
+	ctx context.Context, songID ksuid.KSUID,
+	version *int64,
+) error {
+	return sqliteDeleteSong(ctx, conn(ctx, r.db), songID, version)
+}
+
+// sqliteDeleteSong fails with ErrSongNotFound or ErrVersionMismatch
+// if there is no song with the given ID and version, nil version
+// matches any. Couplets are deleted by foreign key cascade
+func sqliteDeleteSong(
+	ctx context.Context, q sqlx.ExtContext,
+	songID ksuid.KSUID, version *int64,
+) error {
+	builder := sq.
+		Delete("songs").
+		Where(sq.Eq{"id": songID})
+	if version != nil {
+		builder = builder.Where(sq.Eq{"version": *version})
+	}
+	query, args, err := sqliteQuery(builder)
+	if err != nil {
+		return errors.Wrap(err, "build query")
+	}
+
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "execute query")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "get rows affected")
+	}
+	if rowsAffected == 0 {
+		return sqliteVersionMismatchOrNotFound(ctx, q, songID)
+	}
+
+	return nil
+}
+
+// sqliteVersionMismatchOrNotFound tells why song
+// with the expected version was not changed
+func sqliteVersionMismatchOrNotFound(
+	ctx context.Context, q sqlx.QueryerContext, songID ksuid.KSUID,
+) error {
+	exists, err := sqliteSongExists(ctx, q, sq.Eq{"s.id": songID})
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "check song exists")
+	case exists:
+		return domain.ErrVersionMismatch
+	default:
+		return domain.ErrSongNotFound
+	}
+}
+
+func selectSQLiteSongs(fields domain.SongFieldSet) sq.SelectBuilder {
+	return selectSongsAggregatingCouplets(fields, sqliteCoupletsAggregate)
+}
+
+func NewSQLiteSongRepository(
+	db *sqlx.DB, similarityThreshold float64,
+) *SQLiteSongRepository {
+	return &SQLiteSongRepository{
+		db:                  db,
+		similarityThreshold: similarityThreshold,
+	}
+}
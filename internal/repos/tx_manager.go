@@ -0,0 +1,191 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"song-lib/internal/domain"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Transactions failing with these codes
+// may succeed if they are run again
+const (
+	pqSerializationFailureCode = "40001"
+	pqDeadlockDetectedCode     = "40P01"
+)
+
+var txIsolations = map[domain.TxIsolation]sql.IsolationLevel{
+	domain.TxIsolationReadCommitted:  sql.LevelReadCommitted,
+	domain.TxIsolationRepeatableRead: sql.LevelRepeatableRead,
+	domain.TxIsolationSerializable:   sql.LevelSerializable,
+}
+
+// TxManager runs functions in transactions of the database, song
+// repositories of the database join the transaction of the context.
+// SQLite transactions are serializable whatever the isolation is.
+// Only Postgres transactions are retried, SQLite ones take the write
+// lock on begin waiting for it for busy timeout, so they don't fail
+// on serialization, and a busy database is not retried
+type TxManager struct {
+	db *sqlx.DB
+	// reads routes read only transactions, it is nil
+	// if they are run on db as the rest of them
+	reads ReadRouter
+	// isolation is used unless transaction asks for another one
+	isolation  domain.TxIsolation
+	maxRetries int
+	retryDelay time.Duration
+}
+
+type txKey struct{}
+
+// InTx behaves as domain.TxManager.InTx. Transactions failing on
+// serialization are run again after a delay growing with each retry.
+// Read only transactions are run on replicas, unless they are
+// serializable, as replicas don't support serializable ones
+func (m *TxManager) InTx(
+	ctx context.Context, opts domain.TxOptions,
+	fn func(ctx context.Context) error,
+) error {
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	isolation := opts.Isolation
+	if isolation == domain.TxIsolationDefault {
+		isolation = m.isolation
+	}
+	txOpts := &sql.TxOptions{
+		Isolation: txIsolations[isolation],
+		ReadOnly:  opts.ReadOnly,
+	}
+
+	db := m.db
+	if m.reads != nil && opts.ReadOnly &&
+		isolation != domain.TxIsolationSerializable {
+		db = m.reads.Reader()
+	}
+
+	run := func(db *sqlx.DB) error {
+		return inTx(ctx, db, txOpts, func(tx *sqlx.Tx) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		})
+	}
+	for retry := 0; ; retry++ {
+		err := run(db)
+		if db != m.db && ctx.Err() == nil && m.reads.Fallback(db, err) {
+			db = m.db
+			err = run(db)
+		}
+		if err == nil && m.reads != nil && !opts.ReadOnly {
+			m.reads.RecordWrite()
+		}
+		if !isSerializationFailure(err) || retry == m.maxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(err, "wait to retry")
+		case <-time.After(time.Duration(retry+1) * m.retryDelay):
+		}
+	}
+}
+
+func txFromContext(ctx context.Context) *sqlx.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sqlx.Tx)
+	return tx
+}
+
+// conn returns the transaction of the context if there is one, so
+// that queries made with the result are part of the transaction
+func conn(ctx context.Context, db *sqlx.DB) sqlx.ExtContext {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+	return db
+}
+
+// inTx runs fn in the transaction of the context or, if there is none,
+// in a new one with the given options, which is committed unless fn
+// fails. If fn joined the transaction of the context and failed, changes
+// of fn are rolled back to a savepoint, so that the caller can go on
+func inTx(
+	ctx context.Context, db *sqlx.DB, opts *sql.TxOptions,
+	fn func(tx *sqlx.Tx) error,
+) error {
+	if tx := txFromContext(ctx); tx != nil {
+		return inSavepoint(ctx, tx, fn)
+	}
+
+	tx, err := db.BeginTxx(ctx, opts)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "commit")
+	}
+
+	return nil
+}
+
+func inSavepoint(
+	ctx context.Context, tx *sqlx.Tx, fn func(tx *sqlx.Tx) error,
+) error {
+	_, err := tx.ExecContext(ctx, "SAVEPOINT nested_tx")
+	if err != nil {
+		return errors.Wrap(err, "create savepoint")
+	}
+
+	err = fn(tx)
+	if err != nil {
+		_, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT nested_tx")
+		if rollbackErr != nil {
+			return errors.Wrap(rollbackErr, "roll back to savepoint")
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT nested_tx")
+	if err != nil {
+		return errors.Wrap(err, "release savepoint")
+	}
+
+	return nil
+}
+
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) &&
+		(pqErr.Code == pqSerializationFailureCode ||
+			pqErr.Code == pqDeadlockDetectedCode)
+}
+
+// NewTxManager creates manager running read only transactions on
+// replicas reads routes if it is not nil. Transactions failed on
+// serialization are retried up to maxRetries times, the delay
+// before each retry grows by retryDelay
+func NewTxManager(
+	db *sqlx.DB, reads ReadRouter, isolation domain.TxIsolation,
+	maxRetries int, retryDelay time.Duration,
+) *TxManager {
+	return &TxManager{
+		db:         db,
+		reads:      reads,
+		isolation:  isolation,
+		maxRetries: maxRetries,
+		retryDelay: retryDelay,
+	}
+}
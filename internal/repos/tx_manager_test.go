@@ -0,0 +1,137 @@
+package repos
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"song-lib/internal/config"
+	"song-lib/internal/db/postgres"
+	"song-lib/internal/db/sqlite"
+	"song-lib/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxManager(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, postgres.MigrateUp(context.Background(), db))
+	_, err = db.Exec(`TRUNCATE song_couplets, songs, music_groups`)
+	require.NoError(t, err)
+
+	testTxManager(t,
+		NewSongRepository(db, nil, 0.3),
+		NewTxManager(db, nil, domain.TxIsolationReadCommitted, 3, 0))
+}
+
+func TestSQLiteTxManager(t *testing.T) {
+	db, err := sqlite.NewClient(config.SQLiteConfig{
+		Path: filepath.Join(t.TempDir(), "song-lib.db")})
+	if errors.Is(err, sqlite.ErrFTS5Unavailable) {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, sqlite.MigrateUp(db))
+
+	testTxManager(t,
+		NewSQLiteSongRepository(db, 0.3),
+		NewTxManager(db, nil, domain.TxIsolationReadCommitted, 3, 0))
+}
+
+func TestTxManagerRetries(t *testing.T) {
+	db, err := sqlite.NewClient(config.SQLiteConfig{
+		Path: filepath.Join(t.TempDir(), "song-lib.db")})
+	if errors.Is(err, sqlite.ErrFTS5Unavailable) {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	const maxRetries = 2
+	txManager := NewTxManager(
+		db, nil, domain.TxIsolationDefault, maxRetries, time.Millisecond)
+	serializationFailure := &pq.Error{Code: pqSerializationFailureCode}
+	// failing fails the first failures calls with err
+	failing := func(failures int, err error) (fn func(context.Context) error, calls *int) {
+		calls = new(int)
+		return func(context.Context) error {
+			*calls++
+			if *calls <= failures {
+				return err
+			}
+			return nil
+		}, calls
+	}
+	ctx := context.Background()
+
+	fn, calls := failing(maxRetries, serializationFailure)
+	require.NoError(t, txManager.InTx(ctx, domain.TxOptions{}, fn))
+	require.Equal(t, maxRetries+1, *calls)
+
+	fn, calls = failing(maxRetries+1, serializationFailure)
+	require.ErrorIs(t, txManager.InTx(ctx, domain.TxOptions{}, fn), serializationFailure)
+	require.Equal(t, maxRetries+1, *calls)
+
+	errFailed := errors.New("failed")
+	fn, calls = failing(1, errFailed)
+	require.ErrorIs(t, txManager.InTx(ctx, domain.TxOptions{}, fn), errFailed)
+	require.Equal(t, 1, *calls)
+}
+
+func testTxManager(
+	t *testing.T, repo domain.SongRepository, txManager domain.TxManager,
+) {
+	ctx := context.Background()
+	newSong := func(name string) *domain.Song {
+		return &domain.Song{
+			Name:       name,
+			MusicGroup: domain.MusicGroup{Name: "Muse"},
+			Couplets:   []string{"first"},
+			ReleaseDate: domain.PartialDate{
+				Date:      time.Date(2009, 9, 14, 0, 0, 0, 0, time.UTC),
+				Precision: domain.DatePrecisionDay,
+			},
+			Link: "https://example.com/" + name,
+		}
+	}
+	exists := func(name string) bool {
+		exists, err := repo.SongExistsByNameAndMusicGroupName(ctx, name, "Muse")
+		require.NoError(t, err)
+		return exists
+	}
+
+	// Failed transaction is rolled back
+	errFailed := errors.New("failed")
+	err := txManager.InTx(ctx, domain.TxOptions{},
+		func(ctx context.Context) error {
+			_, err := repo.SaveSong(ctx, newSong("Uprising"))
+			require.NoError(t, err)
+			return errFailed
+		})
+	require.ErrorIs(t, err, errFailed)
+	require.False(t, exists("Uprising"))
+
+	// Failed repository call doesn't abort the transaction
+	_, err = repo.SaveSong(ctx, newSong("Resistance"))
+	require.NoError(t, err)
+	err = txManager.InTx(ctx, domain.TxOptions{},
+		func(ctx context.Context) error {
+			_, err := repo.SaveSong(ctx, newSong("Resistance"))
+			require.Error(t, err)
+			_, err = repo.SaveSong(ctx, newSong("Uprising"))
+			return err
+		})
+	require.NoError(t, err)
+	require.True(t, exists("Uprising"))
+}
@@ -1,13 +1,106 @@
 package repos
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 )
 
+// cursorFetchSize is the number of rows fetched from cursor at once,
+// memory used by cursor reads doesn't grow with the number of rows
+const cursorFetchSize = 100
+
+// forEachCursorRow reads query rows with server-side cursor of the given
+// name and calls fn for each of them, fn errors are returned as is
+func forEachCursorRow[T any](
+	ctx context.Context, tx *sqlx.Tx, cursor string,
+	query string, args []any, fn func(row *T) error,
+) error {
+	_, err := tx.ExecContext(ctx,
+		"DECLARE "+cursor+" NO SCROLL CURSOR FOR "+query, args...)
+	if err != nil {
+		return errors.Wrap(err, "declare cursor")
+	}
+
+	fetchQuery := fmt.Sprintf("FETCH %d FROM %s", cursorFetchSize, cursor)
+	for {
+		var rows []T
+		err = tx.SelectContext(ctx, &rows, fetchQuery)
+		if err != nil {
+			return errors.Wrap(err, "fetch rows")
+		}
+		for i := range rows {
+			if err := fn(&rows[i]); err != nil {
+				return err
+			}
+		}
+		if len(rows) < cursorFetchSize {
+			break
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "CLOSE "+cursor)
+	return errors.Wrap(err, "close cursor")
+}
+
+// microsecondNow is truncated to microseconds like Postgres timestamps
+func microsecondNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// stringArray is scanned from Postgres arrays
+// and JSON arrays SQLite aggregates into
+type stringArray []string
+
+func (a *stringArray) Scan(src any) error {
+	var data []byte
+	switch src := src.(type) {
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	}
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, (*[]string)(a))
+	}
+	return (*pq.StringArray)(a).Scan(src)
+}
+
 func inConditionWithSubquery(property string, query sq.SelectBuilder) sq.Sqlizer {
 	sql, args, _ := query.ToSql()
 	subQuery := fmt.Sprintf("%s IN (%s)", property, sql)
 	return sq.Expr(subQuery, args...)
 }
+
+// estimatedRowsCount gets the number of rows
+// the planner expects from EXPLAIN (FORMAT JSON) output.
+// For COUNT(*) queries it is taken from the aggregate input
+func estimatedRowsCount(explainOutput []byte) (int, error) {
+	type plan struct {
+		PlanRows float64 `json:"Plan Rows"`
+		NodeType string  `json:"Node Type"`
+		Plans    []plan  `json:"Plans"`
+	}
+	var explained []struct {
+		Plan plan `json:"Plan"`
+	}
+	if err := json.Unmarshal(explainOutput, &explained); err != nil {
+		return 0, errors.Wrap(err, "parse plan")
+	}
+	if len(explained) == 0 {
+		return 0, errors.New("plan is empty")
+	}
+
+	p := explained[0].Plan
+	if p.NodeType == "Aggregate" && len(p.Plans) > 0 {
+		p = p.Plans[0]
+	}
+
+	return int(p.PlanRows), nil
+}
@@ -0,0 +1,21 @@
+package repos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimatedRowsCount(t *testing.T) {
+	explainOutput := []byte(`[{"Plan": {
+		"Node Type": "Aggregate", "Plan Rows": 1,
+		"Plans": [{"Node Type": "Hash Join", "Plan Rows": 1520}]
+	}}]`)
+
+	count, err := estimatedRowsCount(explainOutput)
+	require.NoError(t, err)
+	require.Equal(t, 1520, count)
+
+	_, err = estimatedRowsCount([]byte(`[]`))
+	require.Error(t, err)
+}
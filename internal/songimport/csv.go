@@ -0,0 +1,93 @@
+package songimport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"song-lib/internal/domain"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	csvColumnSong        = "song"
+	csvColumnGroup       = "group"
+	csvColumnText        = "text"
+	csvColumnReleaseDate = "releasedate"
+	csvColumnLink        = "link"
+)
+
+// csvSource reads CSV with header naming the columns,
+// column names are case insensitive
+type csvSource struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVSource(r io.Reader) (*csvSource, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "read header")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case csvColumnSong, csvColumnGroup, csvColumnText,
+			csvColumnReleaseDate, csvColumnLink:
+		default:
+			return nil, fmt.Errorf("column \"%s\" is unknown", header[i])
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column \"%s\" is repeated", header[i])
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{csvColumnSong, csvColumnGroup} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column \"%s\" is required", name)
+		}
+	}
+
+	return &csvSource{reader: reader, columns: columns}, nil
+}
+
+func (s *csvSource) Next() (*domain.SongImportRow, error) {
+	record, err := s.reader.Read()
+	var parseErr *csv.ParseError
+	switch {
+	case errors.As(err, &parseErr):
+		// Reader resumes from the next record after parse error
+		return &domain.SongImportRow{Line: parseErr.StartLine, Err: parseErr.Err}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	row := domain.SongImportRow{
+		Line:           line,
+		SongName:       *s.field(record, csvColumnSong),
+		MusicGroupName: *s.field(record, csvColumnGroup),
+	}
+	fields := rowFields{
+		Text:        nonEmpty(s.field(record, csvColumnText)),
+		ReleaseDate: nonEmpty(s.field(record, csvColumnReleaseDate)),
+		Link:        nonEmpty(s.field(record, csvColumnLink)),
+	}
+	fields.fill(&row)
+
+	return &row, nil
+}
+
+// field returns nil if there is no such column,
+// so that required columns are never nil
+func (s *csvSource) field(record []string, column string) *string {
+	i, ok := s.columns[column]
+	if !ok {
+		return nil
+	}
+	return &record[i]
+}
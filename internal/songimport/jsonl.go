@@ -0,0 +1,80 @@
+package songimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"song-lib/internal/domain"
+
+	"github.com/pkg/errors"
+)
+
+// maxJSONLLineBytes limits a line, lyrics make lines long,
+// but the whole line has to be held in memory to be parsed
+const maxJSONLLineBytes = 1 << 20
+
+type jsonlSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+type jsonlRow struct {
+	Song        string  `json:"song"`
+	Group       string  `json:"group"`
+	Text        *string `json:"text"`
+	ReleaseDate *string `json:"releaseDate"`
+	Link        *string `json:"link"`
+}
+
+func newJSONLSource(r io.Reader) *jsonlSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxJSONLLineBytes)
+	return &jsonlSource{scanner: scanner}
+}
+
+func (s *jsonlSource) Next() (*domain.SongImportRow, error) {
+	for s.scanner.Scan() {
+		s.line++
+		data := bytes.TrimSpace(s.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		return s.parseRow(data), nil
+	}
+
+	err := s.scanner.Err()
+	switch {
+	case errors.Is(err, bufio.ErrTooLong):
+		return nil, fmt.Errorf("line %d is longer than %d bytes",
+			s.line+1, maxJSONLLineBytes)
+	case err != nil:
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (s *jsonlSource) parseRow(data []byte) *domain.SongImportRow {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var jsonRow jsonlRow
+	if err := decoder.Decode(&jsonRow); err != nil {
+		return &domain.SongImportRow{
+			Line: s.line, Err: errors.Wrap(err, "parse JSON")}
+	}
+
+	row := domain.SongImportRow{
+		Line:           s.line,
+		SongName:       jsonRow.Song,
+		MusicGroupName: jsonRow.Group,
+	}
+	fields := rowFields{
+		Text:        nonEmpty(jsonRow.Text),
+		ReleaseDate: nonEmpty(jsonRow.ReleaseDate),
+		Link:        nonEmpty(jsonRow.Link),
+	}
+	fields.fill(&row)
+
+	return &row
+}
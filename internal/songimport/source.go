@@ -0,0 +1,64 @@
+// Package songimport reads songs to import from CSV and JSON Lines.
+// Both formats have song and group fields and optional text, releaseDate
+// and link ones. Couplets of text are separated by blank lines,
+// release date is YYYY, YYYY-MM or YYYY-MM-DD
+package songimport
+
+import (
+	"fmt"
+	"io"
+	"song-lib/internal/domain"
+)
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+// NewSource reads rows from r as they are requested,
+// so that the whole source is never held in memory
+func NewSource(format Format, r io.Reader) (domain.SongImportSource, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVSource(r)
+	case FormatJSONL:
+		return newJSONLSource(r), nil
+	default:
+		return nil, fmt.Errorf("import format \"%s\" is unknown", format)
+	}
+}
+
+// rowFields are optional fields of a row, nil if missing.
+// Empty values are treated as missing in both formats
+type rowFields struct {
+	Text        *string
+	ReleaseDate *string
+	Link        *string
+}
+
+func (f *rowFields) fill(row *domain.SongImportRow) {
+	row.Text = f.Text
+	row.Link = f.Link
+	if f.ReleaseDate == nil {
+		return
+	}
+
+	releaseDate, err := domain.ReleaseDateLayouts.Parse(*f.ReleaseDate)
+	if err != nil {
+		var validationErr domain.ValidationError
+		validationErr.Add(domain.SongFieldReleaseDate,
+			"should be YYYY, YYYY-MM or YYYY-MM-DD")
+		row.Err = &validationErr
+		return
+	}
+	row.ReleaseDate = &releaseDate
+}
+
+func nonEmpty(value *string) *string {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package songimport
+
+import (
+	"io"
+	"song-lib/internal/domain"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readRows(t *testing.T, source domain.SongImportSource) []*domain.SongImportRow {
+	var rows []*domain.SongImportRow
+	for {
+		row, err := source.Next()
+		if err == io.EOF {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestCSVSource(t *testing.T) {
+	source, err := NewSource(FormatCSV, strings.NewReader(
+		"Group,Song,releaseDate,text\n"+
+			"Muse,Uprising,2009-09,\"first\n\nsecond\"\n"+
+			"Muse,Resistance,,\n"+
+			"Muse,Exogenesis,09.2009,\n"+
+			"Muse\n"))
+	require.NoError(t, err)
+
+	rows := readRows(t, source)
+	require.Len(t, rows, 4)
+	require.Equal(t, 2, rows[0].Line)
+	require.Equal(t, "Uprising", rows[0].SongName)
+	require.Equal(t, "Muse", rows[0].MusicGroupName)
+	require.Equal(t, domain.DatePrecisionMonth, rows[0].ReleaseDate.Precision)
+	require.Equal(t, "first\n\nsecond", *rows[0].Text)
+	require.Nil(t, rows[0].Link)
+
+	require.Equal(t, 5, rows[1].Line)
+	require.Nil(t, rows[1].Text)
+	require.Nil(t, rows[1].ReleaseDate)
+	require.NoError(t, rows[1].Err)
+
+	var validationErr *domain.ValidationError
+	require.ErrorAs(t, rows[2].Err, &validationErr)
+	require.Error(t, rows[3].Err)
+
+	_, err = NewSource(FormatCSV, strings.NewReader("song,album\n"))
+	require.Error(t, err)
+	_, err = NewSource(FormatCSV, strings.NewReader("song,text\n"))
+	require.Error(t, err)
+}
+
+func TestJSONLSource(t *testing.T) {
+	source, err := NewSource(FormatJSONL, strings.NewReader(
+		`{"song": "Uprising", "group": "Muse", "releaseDate": "2009", "link": ""}`+"\n"+
+			"\n"+
+			`{"song": "Resistance", "group": "Muse", "album": "The Resistance"}`+"\n"+
+			`{"song": "Exogenesis"`))
+	require.NoError(t, err)
+
+	rows := readRows(t, source)
+	require.Len(t, rows, 3)
+	require.Equal(t, 1, rows[0].Line)
+	require.Equal(t, domain.DatePrecisionYear, rows[0].ReleaseDate.Precision)
+	require.Nil(t, rows[0].Link)
+	require.NoError(t, rows[0].Err)
+
+	require.Equal(t, 3, rows[1].Line)
+	require.Error(t, rows[1].Err)
+	require.Equal(t, 4, rows[2].Line)
+	require.Error(t, rows[2].Err)
+}
+
+func TestJSONLSourceLongLine(t *testing.T) {
+	source, err := NewSource(FormatJSONL, strings.NewReader(
+		`{"song": "Uprising", "group": "Muse"}`+"\n"+
+			strings.Repeat(" ", maxJSONLLineBytes+1)+"\n"))
+	require.NoError(t, err)
+
+	row, err := source.Next()
+	require.NoError(t, err)
+	require.Equal(t, "Uprising", row.SongName)
+	_, err = source.Next()
+	require.ErrorContains(t, err, "line 2")
+}
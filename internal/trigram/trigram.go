@@ -0,0 +1,40 @@
+// Package trigram has pg_trgm similarity for storages lacking it
+package trigram
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Similarity mirrors pg_trgm similarity: the number of shared
+// trigrams divided by the number of distinct trigrams of both strings.
+// Words are lower cased alphanumeric runs padded with two spaces
+// in front and one at the end
+func Similarity(a, b string) float64 {
+	aTrigrams, bTrigrams := trigrams(a), trigrams(b)
+	if len(aTrigrams) == 0 || len(bTrigrams) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range aTrigrams {
+		if bTrigrams[trigram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(aTrigrams)+len(bTrigrams)-shared)
+}
+
+func trigrams(s string) map[string]bool {
+	res := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			res[string(padded[i:i+3])] = true
+		}
+	}
+	return res
+}